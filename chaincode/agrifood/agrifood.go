@@ -1,7 +1,9 @@
 package main
 
 import (
+	"crypto/sha256"
 	"encoding/base64"
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"github.com/hyperledger/fabric/core/chaincode/shim"
@@ -9,19 +11,43 @@ import (
 	"encoding/json"
 	"time"
 	"strconv"
+	"sort"
+	"strings"
+	"regexp"
 )
 
 var myLogger = shim.NewLogger("Agrifood")
 
+// canonical RFC4122 UUID, e.g. 123e4567-e89b-42d3-a456-426655440000
+var uuidPattern = regexp.MustCompile("^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[1-5][0-9a-fA-F]{3}-[89abAB][0-9a-fA-F]{3}-[0-9a-fA-F]{12}$")
+
 type CallerRole struct {
 	Admin bool
 	Role string
 }
 
 type Party struct {
-	ID    string   // identifier of party
-	Role  string   // role of the party
-	Certs []string // encoded certificates
+	ID          string   // identifier of party
+	Role        string   // role of the party
+	Certs       []string // encoded certificates
+	Deactivated bool     // deactivated parties can no longer take part
+	Name        string   // display name
+	Contact     string   // contact details
+}
+
+// party as returned by queries, without certificates
+type PublicParty struct {
+	ID          string
+	Role        string
+	Deactivated bool
+	Name        string
+	Contact     string
+}
+
+// contact information of a party to update, nil fields are left unchanged
+type PartyInfo struct {
+	Name    *string
+	Contact *string
 }
 
 // party authorized to use a certain accreditation
@@ -32,6 +58,7 @@ type SigningAuthorization struct {
 	Expires             time.Time
 	Revoked             bool
 	RevocationTimestamp time.Time
+	RevocationReason    string
 }
 
 // accreditation to issue
@@ -44,6 +71,12 @@ type SigningAccreditation struct {
 	Expires			time.Time
 	Revoked			bool
 	RevocationTimestamp	time.Time
+	RevocationReason	string
+	Scope			[]string // crop types the accreditation applies to (empty: all)
+	MaxQuantity		int // maximum amount certifiable under the accreditation (0: unlimited)
+	CertifiedQuantity	int // amount certified under the accreditation
+	Supersedes		string // accreditation this one renews
+	SupersededBy		string // accreditation renewing this one
 }
 
 // signature to attach to assets
@@ -59,47 +92,403 @@ type AccreditationSignature struct {
 type OwnershipEntry struct {
 	PartyID		string
 	Timestamp	time.Time
+	Fraudulent	bool   // transfer flagged as fraudulent by an auditor
+	FraudReason	string
+	Compensating	bool   // entry restoring ownership after a fraudulent transfer
+	TransferredBy	string // party that initiated the transfer, empty for the producer's entry
+	Latitude	*float64 // location of the custody change, nil when not supplied
+	Longitude	*float64
+}
+
+// share of a co-owned grapes asset
+type OwnershipShare struct {
+	PartyID    string
+	Percentage int
+	Acquired   time.Time
 }
 
-// Grapes asset
-type GrapesUnit struct {
+// produce asset, grapes unless another crop type is given
+type ProduceUnit struct {
 	Producer                string
 	Created                 time.Time
 	UUID                    string
 	Amount			int
+	Unit			string // unit of Amount, e.g. kg
+	CropType		string // e.g. grapes, olives
+	Status			string // active, consumed or destroyed
+	Retired			time.Time
+	Owners			[]OwnershipShare // co-owners, empty when owned by the latest ownership entry only
+	ParentUUIDs		[]string // units these grapes were split or merged from
+	Recalled		bool // flagged as recalled, e.g. after contamination was found
+	RecallReason		string
+	RecalledBy		string
+	RecallTimestamp		time.Time
+	RecallClearedBy		string // auditor that lifted the latest recall
+	RecallCleared		time.Time
 	AccreditationSignatures []AccreditationSignature
 	Ownership               []OwnershipEntry
 }
 
+// validity of an accreditation at query time
+type Validity struct {
+	Valid  bool
+	Reason string // why it is not valid
+}
+
+// summary of the signatures on grapes
+type CertificationSummary struct {
+	TotalSignatures  int
+	ActiveSignatures int       // signatures that are not revoked
+	LatestIssued     time.Time // zero when there are no signatures
+	LatestIssuer     string
+}
+
+// outcome of a certification dry run
+type CertifyEligibility struct {
+	Eligible bool
+	Reason   string // why certifying would fail
+}
+
+// current validity of a signature on grapes
+type SignatureValidity struct {
+	AccreditationID string
+	Issuer          string
+	Issued          time.Time
+	Valid           bool
+	Reason          string // why the signature is not valid
+}
+
+// ownership trail of grapes with their recall state
+type OwnershipTrail struct {
+	UUID         string
+	Ownership    []OwnershipEntry
+	Recalled     bool
+	RecallReason string
+}
+
+// current certification status of grapes
+type GrapesVerification struct {
+	UUID         string
+	Certified    bool // enough distinct valid accreditations, at least one
+	Details      []SignatureValidity
+	Recalled     bool
+	RecallReason string
+}
+
+// integrity of the ownership chain of grapes
+type ProvenanceVerification struct {
+	UUID     string
+	Valid    bool
+	Problems []string
+}
+
+// signing authorization with its validity at query time
+type SignerAuthorization struct {
+	SigningAuthorization
+	Valid bool // not revoked and not expired
+}
+
+// Wine asset, made from grape units
+type WineBatch struct {
+	BatchID          string
+	Producer         string
+	SourceGrapeUUIDs []string
+	Created          time.Time
+	Ownership        []OwnershipEntry
+}
+
+// node in the trust path of a signature
+type TrustPathNode struct {
+	Type   string // AccreditationBody, SigningAccreditation, CertificationBody, SigningAuthorization or Farm
+	ID     string
+	Valid  bool
+	Reason string // why the node is not valid
+}
+
+// compliance of grapes with the minimum signature policy
+type Compliance struct {
+	UUID               string
+	Compliant          bool
+	ValidSignatures    int // number of distinct valid accreditations signed
+	RequiredSignatures int
+}
+
+// accreditations and authorizations expiring on a given day
+type ExpiringEntries struct {
+	Accreditations []SigningAccreditation
+	Authorizations []SigningAuthorization
+}
+
+// event in the timeline of grapes
+type TimelineEvent struct {
+	Type            string // created, certified, signature_revoked, transferred or retired
+	Timestamp       time.Time
+	PartyID         string
+	AccreditationID string
+}
+
+// timeline events sortable by timestamp
+type timelineEvents []TimelineEvent
+
+func (e timelineEvents) Len() int           { return len(e) }
+func (e timelineEvents) Swap(i, j int)      { e[i], e[j] = e[j], e[i] }
+func (e timelineEvents) Less(i, j int) bool { return e[i].Timestamp.Before(e[j].Timestamp) }
+
+// ownership entries sortable by timestamp
+type ownershipEntries []OwnershipEntry
+
+func (e ownershipEntries) Len() int           { return len(e) }
+func (e ownershipEntries) Swap(i, j int)      { e[i], e[j] = e[j], e[i] }
+func (e ownershipEntries) Less(i, j int) bool { return e[i].Timestamp.Before(e[j].Timestamp) }
+
+// history entries sortable by timestamp
+type historyEntries []GrapesHistoryEntry
+
+func (e historyEntries) Len() int           { return len(e) }
+func (e historyEntries) Swap(i, j int)      { e[i], e[j] = e[j], e[i] }
+func (e historyEntries) Less(i, j int) bool { return e[i].Timestamp.Before(e[j].Timestamp) }
+
+// result of a batch operation for a single party
+type BatchResult struct {
+	PartyID string
+	Success bool
+	Message string
+}
+
+// child unit to split grapes into
+type GrapesSplitEntry struct {
+	UUID   string
+	Amount int
+}
+
+// grape unit to create in a batch
+type GrapesBatchEntry struct {
+	UUID        string
+	Created     time.Time
+	Amount      int
+	Unit        string
+	CropType    string
+	Latitude    *float64 // location of the harvest, nil when not supplied
+	Longitude   *float64
+}
+
+// difference between the states of grapes at two points in time
+type GrapesDiff struct {
+	UUID              string
+	From              time.Time
+	To                time.Time
+	OwnerBefore       string // empty if grapes did not exist yet
+	OwnerAfter        string
+	Transfers         []OwnershipEntry
+	SignaturesAdded   []AccreditationSignature
+	SignaturesRevoked []AccreditationSignature
+}
+
+// statistics of the time between creation and first transfer of grapes
+type LeadTimeStats struct {
+	Count      int // number of transferred grapes
+	MinSeconds int64
+	AvgSeconds int64
+	MaxSeconds int64
+}
+
+// signing authorization with consistency problems
+type AuthorizationProblem struct {
+	Authorization SigningAuthorization
+	Problems      []string
+}
+
+// size of a collection in world-state
+type StateSize struct {
+	Key   string
+	Bytes int
+	Count int
+}
+
+// signature on grapes whose signing authorization was revoked
+type RevokedAuthoritySignature struct {
+	UUID          string
+	Signature     AccreditationSignature
+	Authorization SigningAuthorization
+}
+
+// payload of chaincode event on accreditation revocation
+type AccreditationRevokedEvent struct {
+	AccreditationID     string
+	RevocationTimestamp time.Time
+	RevocationReason    string
+}
+
+// payload of chaincode events on grapes
+type GrapesEvent struct {
+	UUID      string
+	Producer  string
+	Timestamp time.Time
+}
+
+// payload of chaincode event on lifting a grapes recall
+type RecallClearedEvent struct {
+	UUID      string
+	Producer  string
+	ClearedBy string
+	Timestamp time.Time
+}
+
+// payload of chaincode event on grapes recall
+type GrapesRecalledEvent struct {
+	UUID       string
+	Producer   string
+	RecalledBy string
+	Reason     string
+	Timestamp  time.Time
+}
+
+// state of a grape unit as written by a transaction
+type GrapesHistoryEntry struct {
+	TxID      string
+	Timestamp time.Time
+	Value     ProduceUnit
+}
+
+// page of grape units with the key to continue from
+type GrapesPage struct {
+	Grapes   []ProduceUnit
+	Bookmark string
+}
+
+// crop type of units created without an explicit type
+const defaultCropType = "grapes"
+
+// unit of the amount of grape units created without an explicit unit
+const defaultUnit = "kg"
+
+// tolerance for clock differences between clients and peers in expiry checks
+const clockSkew = 2 * time.Minute
+
+// maximum difference between caller-supplied event timestamps and the transaction time
+const timestampWindow = 5 * time.Minute
+
+// status of grape units, consumed and destroyed are terminal
+const (
+	statusActive    = "active"
+	statusConsumed  = "consumed"
+	statusDestroyed = "destroyed"
+)
+
+// roles functions are restricted to, a function is unusable if its roles are not configured
+const (
+	roleAccreditationBody = "AccreditationBody"
+	roleCertificationBody = "CertificationBody"
+	roleFarm              = "Farm"
+	roleAuditor           = "Auditor"
+	roleTrader            = "Trader"
+	roleWinery            = "Winery"
+)
+
+// roles used when Init is given none
+var defaultRoles = []string{roleAccreditationBody,roleCertificationBody,roleFarm,roleAuditor,roleTrader,roleWinery}
+
+// certificate attributes identifying the caller when roles are taken from certificates
+const (
+	roleAttribute  = "role"
+	partyAttribute = "partyID"
+)
+
 // Smart-contract
 type AgrifoodChaincode struct {
 	roles        []string // list of roles
+	certRoles    bool     // take caller role from certificate attributes instead of the party registry
 }
 
 // initialize smart-contract
 func (t *AgrifoodChaincode) Init(stub shim.ChaincodeStubInterface, function string, args []string) ([]byte, error) {
 	myLogger.Info("Init Chaincode...")
 
+	// Check number of arguments
+	if len(args) < 1 || len(args) > 3 {
+		msg := "Expecting 1 to 3 arguments: admin certificate, (optional) roles, (optional) roles from certificate attributes"
+		myLogger.Error(msg)
+		return nil, errors.New(msg)
+	}
+
 	// Roles of parties able to invoke chaincode
-	t.roles = []string{"AccreditationBody","CertificationBody","Farm","Auditor","Trader"}
+	t.roles = defaultRoles
 
-	// Initiate empty arrays
-	err := stub.PutState("AdminCerts", []byte("[]"))
-	err = stub.PutState("Parties", []byte("[]"))
-	err = stub.PutState("SigningAccreditations", []byte("[]"))
-	err = stub.PutState("SigningAuthorizations", []byte("[]"))
-	err = stub.PutState("GrapeUnits", []byte("[]"))
+	// optional custom roles replacing the default roles
+	if len(args) >= 2 && args[1] != "" {
+		var roles []string
+		err := json.Unmarshal([]byte(args[1]), &roles)
+		if err != nil {
+			msg := fmt.Sprintf("Error parsing roles: %s", err)
+			myLogger.Error(msg)
+			return nil, errors.New(msg)
+		}
+
+		if len(roles) == 0 {
+			msg := "Roles must not be empty"
+			myLogger.Error(msg)
+			return nil, errors.New(msg)
+		}
+
+		known := make(map[string]bool)
+		for _, role := range roles {
+			if strings.TrimSpace(role) == "" || known[role] {
+				msg := fmt.Sprintf("Invalid role %q, roles need to be unique and not empty", role)
+				myLogger.Error(msg)
+				return nil, errors.New(msg)
+			}
+			known[role] = true
+		}
+		t.roles = roles
+	}
+
+	// persist roles, the chaincode object doesn't keep them between invocations
+	roles_b, err := json.Marshal(t.roles)
+	if err != nil {
+		msg := fmt.Sprintf("Error marshalling roles: %s", err)
+		myLogger.Error(msg)
+		return nil, errors.New(msg)
+	}
+
+	err = stub.PutState(rolesKey, roles_b)
+	if err != nil {
+		msg := fmt.Sprintf("Failed initializing Roles: %s", err)
+		myLogger.Errorf(msg)
+		return nil, errors.New(msg)
+	}
 
+	// optional flag to take caller roles from certificate attributes, the party registry is used otherwise
+	t.certRoles = false
+	if len(args) == 3 {
+		t.certRoles, err = strconv.ParseBool(args[2])
+		if err != nil {
+			msg := fmt.Sprintf("Invalid certificate roles flag: %s", args[2])
+			myLogger.Error(msg)
+			return nil, errors.New(msg)
+		}
+	}
+
+	err = stub.PutState(certRolesKey, []byte(strconv.FormatBool(t.certRoles)))
 	if err != nil {
-		msg := fmt.Sprintf("Failed initializing variables: %s", err)
+		msg := fmt.Sprintf("Failed initializing role source: %s", err)
 		myLogger.Errorf(msg)
 		return nil, errors.New(msg)
 	}
 
+	// Initiate empty arrays
+	for _, key := range []string{adminCertsKey, signingAccreditationsKey, signingAuthorizationsKey} {
+		err := stub.PutState(key, []byte("[]"))
+		if err != nil {
+			msg := fmt.Sprintf("Failed initializing %s: %s", key, err)
+			myLogger.Errorf(msg)
+			return nil, errors.New(msg)
+		}
+	}
+
 	// Add encoded certificate to AdminCerts
 	add_err := t.addAdminCert(stub, args[0])
 	if add_err != nil {
-		msg := fmt.Sprintf("Failed adding to AdminCerts array: %s", err)
+		msg := fmt.Sprintf("Failed adding to AdminCerts array: %s", add_err)
 		myLogger.Errorf(msg)
 		return nil, errors.New(msg)
 	}
@@ -115,6 +504,36 @@ Invoke section
 func (t *AgrifoodChaincode) Invoke(stub shim.ChaincodeStubInterface, function string, args []string) ([]byte, error) {
 	myLogger.Infof("Calling Invoke with function: %s", function)
 
+	// load roles stored at Init
+	roles, err := getRoles(stub)
+	if err != nil {
+		return nil, err
+	}
+	t.roles = roles
+
+	t.certRoles, err = getCertRoles(stub)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := t.invokeFunction(stub, function, args)
+	if err != nil {
+		return nil, err
+	}
+
+	// count successful call as part of the same transaction
+	err = t.incrementMetric(stub, function)
+	if err != nil {
+		msg := fmt.Sprintf("Error updating metrics: %s", err)
+		myLogger.Error(msg)
+		return nil, errors.New(msg)
+	}
+
+	return result, nil
+}
+
+// dispatch invoke to function
+func (t *AgrifoodChaincode) invokeFunction(stub shim.ChaincodeStubInterface, function string, args []string) ([]byte, error) {
 	// Handle different functions
 	if function == "add_admin" {
 		return t.add_admin(stub, args)
@@ -124,22 +543,66 @@ func (t *AgrifoodChaincode) Invoke(stub shim.ChaincodeStubInterface, function st
 		return t.add_cert(stub, args)
 	} else if function == "add_signing_accreditation" {
 		return t.add_signing_accreditation(stub, args)
+	} else if function == "renew_signing_accreditation" {
+		return t.renew_signing_accreditation(stub, args)
 	} else if function == "issue_signing_accreditation" {
 		return t.issue_signing_accreditation(stub, args)
 	} else if function == "revoke_signing_accreditation" {
 		return t.revoke_signing_accreditation(stub, args)
+	} else if function == "set_accreditation_scope" {
+		return t.set_accreditation_scope(stub, args)
+	} else if function == "revoke_expired_accreditations" {
+		return t.revoke_expired_accreditations(stub, args)
+	} else if function == "set_min_signatures" {
+		return t.set_min_signatures(stub, args)
 	} else if function == "grant_signing_authority" {
 		return t.grant_signing_authority(stub, args)
+	} else if function == "grant_signing_authority_batch" {
+		return t.grant_signing_authority_batch(stub, args)
 	} else if function == "revoke_signing_authority" {
 		return t.revoke_signing_authority(stub, args)
 	} else if function == "create_grapes" {
 		return t.create_grapes(stub, args)
+	} else if function == "create_produce" {
+		return t.create_produce(stub, args)
 	} else if function == "certify_grapes" {
 		return t.certify_grapes(stub, args)
 	} else if function == "revoke_signature" {
 		return t.revoke_signature(stub, args)
 	} else if function == "transfer_grapes" {
 		return t.transfer_grapes(stub, args)
+	} else if function == "transfer_grapes_batch" {
+		return t.transfer_grapes_batch(stub, args)
+	} else if function == "transfer_share" {
+		return t.transfer_share(stub, args)
+	} else if function == "retire_grapes" {
+		return t.retire_grapes(stub, args)
+	} else if function == "flag_transfer_fraudulent" {
+		return t.flag_transfer_fraudulent(stub, args)
+	} else if function == "recall_grapes" {
+		return t.recall_grapes(stub, args)
+	} else if function == "clear_recall" {
+		return t.clear_recall(stub, args)
+	} else if function == "update_party_info" {
+		return t.update_party_info(stub, args)
+	} else if function == "transfer_accreditation" {
+		return t.transfer_accreditation(stub, args)
+	} else if function == "create_wine" {
+		return t.create_wine(stub, args)
+	} else if function == "remove_party" {
+		return t.remove_party(stub, args)
+	} else if function == "change_party_role" {
+		return t.change_party_role(stub, args)
+	} else if function == "create_grapes_batch" {
+		return t.create_grapes_batch(stub, args)
+	} else if function == "add_role_admin" {
+		return t.add_role_admin(stub, args)
+	} else if function == "remove_admin" {
+		return t.remove_admin(stub, args)
+	} else if function == "split_grapes" {
+		return t.split_grapes(stub, args)
+	} else if function == "merge_grapes" {
+		return t.merge_grapes(stub, args)
 	}
 
 	myLogger.Errorf("Received unknown function invocation: %s", function)
@@ -173,10 +636,15 @@ func (t *AgrifoodChaincode) add_admin(stub shim.ChaincodeStubInterface, args []s
 		return nil, errors.New(msg)
 	}
 
+	err = verifyID("certificate", args[0])
+	if err != nil {
+		return nil, err
+	}
+
 	// add encoded cert (args[0)) to admin arrays
 	add_err := t.addAdminCert(stub, args[0])
 	if add_err != nil {
-		msg := fmt.Sprintf("Failed adding to AdminCerts array: %s", err)
+		msg := fmt.Sprintf("Failed adding to AdminCerts array: %s", add_err)
 		myLogger.Errorf(msg)
 		return nil, errors.New(msg)
 	}
@@ -186,10 +654,10 @@ func (t *AgrifoodChaincode) add_admin(stub shim.ChaincodeStubInterface, args []s
 	return nil, err
 }
 
-// add party to world-state
-func (t *AgrifoodChaincode) add_party(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+// remove admin transaction certificate
+func (t *AgrifoodChaincode) remove_admin(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
 	// Can only be called by an admin
-	myLogger.Info("Add party..")
+	myLogger.Info("Remove admin..")
 
 	correctCaller, err := t.verifyAdmin(stub)
 
@@ -207,1144 +675,5484 @@ func (t *AgrifoodChaincode) add_party(stub shim.ChaincodeStubInterface, args []s
 	}
 
 	// Check number of arguments
-	if len(args) != 3 {
-		msg := "Incorrect number of arguments. Expecting 3" // ID, Role, Encoded Cert
+	if len(args) != 1 {
+		msg := "Incorrect number of arguments. Expecting 1" // Encoded Cert
 		myLogger.Error(msg)
 		return nil, errors.New(msg)
 	}
 
-	// verify role validity
-	valid_role := false
-
-	for _, role := range t.roles {
-		if args[1] == role {
-			valid_role = true
-		}
-	}
-
-	// if role is not valid, throw error
-	if !valid_role {
-		msg := "Incorrect role"
+	fingerprint, err := certFingerprint(args[0])
+	if err != nil {
+		msg := fmt.Sprintf("Invalid certificate: %s", err)
 		myLogger.Error(msg)
 		return nil, errors.New(msg)
 	}
 
-	// initiate new party
-	party := Party{ID: args[0], Role: args[1], Certs: []string{args[2]}}
-
-	// get parties from storage
-	parties, err := t.getParties(stub)
+	certs, err := t.getAdminCerts(stub)
 	if err != nil {
-		msg := fmt.Sprintf("Error getting parties: %s", err)
+		msg := fmt.Sprintf("Error retrieving certs: %s", err)
 		myLogger.Error(msg)
 		return nil, errors.New(msg)
 	}
 
-	// verify uniqueness of ID
-	for _, known_party := range parties {
-		if known_party.ID == party.ID {
-			msg := "Party ID must be unique"
-			myLogger.Error(msg)
-			return nil, errors.New(msg)
+	// encodings of the same certificate may differ
+	var remaining []string
+	for _, cert := range certs {
+		admin_fingerprint, err := certFingerprint(cert)
+		if err != nil || admin_fingerprint != fingerprint {
+			remaining = append(remaining, cert)
 		}
 	}
 
-	err = t.saveParty(stub, party, true)
-	if err != nil {
-		msg := fmt.Sprintf("Error getting parties: %s", err)
+	if len(remaining) == len(certs) {
+		msg := "Certificate is not an admin certificate"
 		myLogger.Error(msg)
 		return nil, errors.New(msg)
 	}
 
-	msg := fmt.Sprintf("New party added: %s, role: %s", party.ID, party.Role)
-	myLogger.Info(msg)
-	return []byte(msg), err
-}
-
-// add transaction certificate to party
-func (t *AgrifoodChaincode) add_cert(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
-	// Can only be called by party
-	myLogger.Info("Add certificate..")
-
-	party, err := t.getCallerParty(stub)
-	if err != nil {
-		msg := "Failed retrieving party"
+	// at least one admin has to remain to manage the chaincode
+	if len(remaining) == 0 {
+		msg := "Cannot remove the last admin certificate"
 		myLogger.Error(msg)
 		return nil, errors.New(msg)
 	}
 
-	myLogger.Debugf("Add cert to: %s", party.ID)
-
-	// Check number of arguments
-	if len(args) != 1 {
-		msg := "Incorrect number of arguments. Expecting 1"
+	certs_serialized, err := json.Marshal(remaining)
+	if err != nil {
+		msg := fmt.Sprintf("Failed reserializing certs: %s", err)
 		myLogger.Error(msg)
 		return nil, errors.New(msg)
 	}
 
-	// add (encoded) cert to array
-	party.Certs = append(party.Certs, args[0])
-
-	// save updated party
-	err = t.saveParty(stub, party, false)
+	err = stub.PutState(adminCertsKey, certs_serialized)
 	if err != nil {
-		msg := "Failed saving party"
+		msg := fmt.Sprintf("Failed saving new AdminCerts: %s", err)
 		myLogger.Error(msg)
 		return nil, errors.New(msg)
 	}
 
-	myLogger.Info("Saved updated party")
-
-	return []byte("Successfully saved party"), nil
+	msg := "Removed certificate from admincerts array"
+	myLogger.Info(msg)
+	return []byte(msg), nil
 }
 
-// add signing certificate
-func (t *AgrifoodChaincode) add_signing_accreditation(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
-	// can only be called by AccreditationBody
-	myLogger.Info("Register new signing accreditation")
+// add admin transaction certificate that may only add parties of one role
+func (t *AgrifoodChaincode) add_role_admin(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+	// Can only be called by an admin
+	myLogger.Info("Add role admin..")
+
+	correctCaller, err := t.verifyAdmin(stub)
 
-	party, err := t.getCallerParty(stub)
 	if err != nil {
-		msg := fmt.Sprintf("Error determining party: %s", err)
+		msg := "Failed verifying certificates"
 		myLogger.Error(msg)
 		return nil, errors.New(msg)
 	}
 
-	myLogger.Debugf("Received party: %s, role:%s", party.ID, party.Role)
-
-	// check if caller is a AccreditationBody
-	if party.Role != t.roles[0] {
-		msg := "Caller is not an AccreditationBody"
+	// caller is not admin, return
+	if !correctCaller {
+		msg := "The caller is not an admin"
 		myLogger.Error(msg)
 		return nil, errors.New(msg)
 	}
 
 	// Check number of arguments
-	if len(args) != 4 {
-		msg := "Incorrect number of arguments. Expecting 4" // ID, description,created,expiration date
+	if len(args) != 2 {
+		msg := "Incorrect number of arguments. Expecting 2" // Role, Encoded Cert
 		myLogger.Error(msg)
 		return nil, errors.New(msg)
 	}
 
-	signingAccreditation := SigningAccreditation{ID:args[0],AccreditationBody:party.ID,Description:args[1],Revoked:false}
-	signingAccreditation.Created, err = time.Parse(time.RFC3339,args[2])
+	err = verifyID("certificate", args[1])
 	if err != nil {
-		msg := "Error parsing time (created date)"
+		return nil, err
+	}
+
+	// verify role validity
+	valid_role := false
+	for _, role := range t.roles {
+		if args[0] == role {
+			valid_role = true
+		}
+	}
+
+	if !valid_role {
+		msg := "Incorrect role"
 		myLogger.Error(msg)
 		return nil, errors.New(msg)
 	}
 
-	signingAccreditation.Expires, err = time.Parse(time.RFC3339,args[3])
+	fingerprint, err := certFingerprint(args[1])
 	if err != nil {
-		msg := "Error parsing time (expiration date)"
+		msg := fmt.Sprintf("Invalid certificate: %s", err)
 		myLogger.Error(msg)
 		return nil, errors.New(msg)
 	}
 
-	// save certificate
-	err = t.saveSigningAccreditation(stub, signingAccreditation,true)
+	roleAdmins, err := t.getRoleAdmins(stub)
 	if err != nil {
-		msg := fmt.Sprintf("Error saving signing accreditation: %s", err)
-		myLogger.Error(msg)
-		return nil, errors.New(msg)
+		return nil, err
 	}
 
-	msg := fmt.Sprintf("New signing accreditation added by %s",party.ID)
-	myLogger.Info(msg)
-	return []byte(msg), nil
-}
+	// adding a known certificate is a no-op, encodings of the same certificate may differ
+	for _, cert := range roleAdmins[args[0]] {
+		admin_fingerprint, err := certFingerprint(cert)
+		if err == nil && admin_fingerprint == fingerprint {
+			msg := fmt.Sprintf("Certificate is already an admin for role %s", args[0])
+			myLogger.Info(msg)
+			return []byte(msg), nil
+		}
+	}
 
-// issue signing accreditation to certification body
-func (t *AgrifoodChaincode) issue_signing_accreditation(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
-	// can only be called by AccreditationBody
-	myLogger.Info("Assign signing accreditation to a certificate body")
+	roleAdmins[args[0]] = append(roleAdmins[args[0]], args[1])
 
-	party, err := t.getCallerParty(stub)
+	roleAdmins_b, err := json.Marshal(roleAdmins)
 	if err != nil {
-		msg := fmt.Sprintf("Error determining party: %s", err)
+		msg := fmt.Sprintf("Error marshalling RoleAdmins: %s", err)
 		myLogger.Error(msg)
 		return nil, errors.New(msg)
 	}
 
-	myLogger.Debugf("Received party: %s, role:%s", party.ID, party.Role)
-
-	// check if caller is a AccreditationBody
-	if party.Role != t.roles[0] {
-		msg := "Caller is not an AccreditationBody"
+	err = stub.PutState(roleAdminsKey, roleAdmins_b)
+	if err != nil {
+		msg := "Error saving RoleAdmins"
 		myLogger.Error(msg)
 		return nil, errors.New(msg)
 	}
 
-	// Check number of arguments
-	if len(args) != 2 {
-		msg := "Incorrect number of arguments. Expecting 2" // AccreditationID, Certificate body ID
-		myLogger.Error(msg)
-		return nil, errors.New(msg)
-	}
+	msg := fmt.Sprintf("Added admin for role %s", args[0])
+	myLogger.Info(msg)
+	return []byte(msg), nil
+}
+
+// set minimum number of distinct valid accreditation signatures required before grapes are first transferred
+func (t *AgrifoodChaincode) set_min_signatures(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+	// Can only be called by an admin
+	myLogger.Info("Set minimum signatures..")
+
+	correctCaller, err := t.verifyAdmin(stub)
 
-	// get accreditation
-	accreditation, err := t.getSigningAccreditation(stub,args[0])
 	if err != nil {
-		msg := fmt.Sprintf("Error determining accreditation: %s", err)
+		msg := "Failed verifying certificates"
 		myLogger.Error(msg)
 		return nil, errors.New(msg)
 	}
 
-	// see if accreditation is still valid
-	if accreditation.Expires.Before(time.Now()) {
-		msg := "Error: Accreditation expired"
+	// caller is not admin, return
+	if !correctCaller {
+		msg := "The caller is not an admin"
 		myLogger.Error(msg)
 		return nil, errors.New(msg)
 	}
 
-	if accreditation.AccreditationBody != party.ID {
-		msg := fmt.Sprintf("Error: Accreditation body (%s) is not the issuer of this accreditation (%s)",party.ID, accreditation.ID)
-		myLogger.Warning(msg)
-		return nil, errors.New(msg)
-	}
-
-	// verify accreditation is not revoked
-	if accreditation.Revoked {
-		msg := fmt.Sprintf("Error: Accreditation is revoked at %s",accreditation.RevocationTimestamp)
-		myLogger.Warning(msg)
-		return nil, errors.New(msg)
-	}
-
-	// get party
-	certBody, err := t.getParty(stub,args[1])
-	if err != nil {
-		msg := fmt.Sprintf("Error determining party: %s", err)
+	// Check number of arguments
+	if len(args) != 1 {
+		msg := "Incorrect number of arguments. Expecting 1" // minimum signatures
 		myLogger.Error(msg)
 		return nil, errors.New(msg)
 	}
 
-	// verify party is a certificate body
-	if certBody.Role != t.roles[1] {
-		msg := fmt.Sprintf("Error: supplied party is no CertifiactionBody: %s", err)
-		myLogger.Warning(msg)
+	minSignatures, err := strconv.Atoi(args[0])
+	if err != nil || minSignatures < 0 {
+		msg := fmt.Sprintf("Invalid minimum signatures: %s", args[0])
+		myLogger.Error(msg)
 		return nil, errors.New(msg)
 	}
 
-	// set certification body on accreditation
-	accreditation.CertificationBody = certBody.ID
-
-	// save updated certificate
-	err = t.saveSigningAccreditation(stub, accreditation,false)
+	err = stub.PutState(minSignaturesKey, []byte(strconv.Itoa(minSignatures)))
 	if err != nil {
-		msg := "Error saving certificate"
+		msg := "Error saving MinSignatures"
 		myLogger.Error(msg)
 		return nil, errors.New(msg)
 	}
 
-	msg := fmt.Sprintf("Successfully added %s as certification body on %s",certBody.ID, accreditation.ID)
+	msg := fmt.Sprintf("Minimum signatures set to %d", minSignatures)
 	myLogger.Info(msg)
 	return []byte(msg), nil
 }
 
-// revoke signing accreditation
-func (t *AgrifoodChaincode) revoke_signing_accreditation(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
-	// can only be called by AccreditationBody or auditor
-	myLogger.Info("Revoke signing accreditation")
+// add party to world-state
+func (t *AgrifoodChaincode) add_party(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+	// Can only be called by an admin, or an admin of the role of the party
+	myLogger.Info("Add party..")
 
-	party, err := t.getCallerParty(stub)
-	if err != nil {
-		msg := fmt.Sprintf("Error determining party: %s", err)
+	// Check number of arguments
+	if len(args) != 3 {
+		msg := "Incorrect number of arguments. Expecting 3" // ID, Role, Encoded Cert
 		myLogger.Error(msg)
 		return nil, errors.New(msg)
 	}
 
-	myLogger.Debugf("Received party: %s, role:%s", party.ID, party.Role)
+	correctCaller, err := t.verifyAdmin(stub)
 
-	// check if caller is a AccreditationBody or auditor
-	if party.Role != t.roles[0] && party.Role != t.roles[3] {
-		msg := "Caller is not an AccreditationBody or Auditor"
+	if err != nil {
+		msg := "Failed verifying certificates"
 		myLogger.Error(msg)
 		return nil, errors.New(msg)
 	}
 
-	// Check number of arguments
-	if len(args) != 2 {
-		msg := "Incorrect number of arguments. Expecting 2" // AccreditationID, revokeTimestamp
+	if !correctCaller {
+		correctCaller, err = t.verifyRoleAdmin(stub, args[1])
+		if err != nil {
+			msg := "Failed verifying certificates"
+			myLogger.Error(msg)
+			return nil, errors.New(msg)
+		}
+	}
+
+	// caller is not admin, return
+	if !correctCaller {
+		msg := fmt.Sprintf("The caller is not an admin of role %s", args[1])
 		myLogger.Error(msg)
 		return nil, errors.New(msg)
 	}
 
-	// get accreditation
-	accreditation, err := t.getSigningAccreditation(stub,args[0])
+	err = verifyID("party ID", args[0])
 	if err != nil {
-		msg := fmt.Sprintf("Error determining accreditation: %s", err)
+		return nil, err
+	}
+
+	err = verifyID("certificate", args[2])
+	if err != nil {
+		return nil, err
+	}
+
+	// verify role validity
+	valid_role := false
+
+	for _, role := range t.roles {
+		if args[1] == role {
+			valid_role = true
+		}
+	}
+
+	// if role is not valid, throw error
+	if !valid_role {
+		msg := "Incorrect role"
 		myLogger.Error(msg)
 		return nil, errors.New(msg)
 	}
 
-	// verify if accreditation body is owner of certificate
-	if party.Role == t.roles[0] && accreditation.AccreditationBody != party.ID {
-		msg := fmt.Sprintf("Error: Accreditation body (%s) is not the issuer of this accreditation (%s)",party.ID, accreditation.ID)
-		myLogger.Warning(msg)
+	// verify uniqueness of ID
+	_, err = t.getParty(stub, args[0])
+	if err == nil {
+		msg := "Party ID must be unique"
+		myLogger.Error(msg)
 		return nil, errors.New(msg)
 	}
 
-	// Revoke certificate
-	accreditation.Revoked = true
-	accreditation.RevocationTimestamp, err = time.Parse(time.RFC3339, args[1])
+	// verify cert is not registered to another party
+	owner, err := t.getCertOwner(stub, args[2])
 	if err != nil {
-		msg := "Error parsing time"
+		msg := fmt.Sprintf("Failed determining certificate owner: %s", err)
 		myLogger.Error(msg)
 		return nil, errors.New(msg)
 	}
 
-	// save updated accreditation
-	err = t.saveSigningAccreditation(stub, accreditation, false)
+	if owner != "" {
+		msg := "Certificate is already registered to another party"
+		myLogger.Error(msg)
+		return nil, errors.New(msg)
+	}
+
+	// initiate new party
+	party := Party{ID: args[0], Role: args[1], Certs: []string{args[2]}}
+
+	err = t.saveParty(stub, party, true)
 	if err != nil {
-		msg := "Error saving updated accreditation"
+		msg := fmt.Sprintf("Error saving party: %s", err)
 		myLogger.Error(msg)
 		return nil, errors.New(msg)
 	}
 
-	msg := fmt.Sprintf("Successfully revoked signing accreditation %s", accreditation.ID)
+	msg := fmt.Sprintf("New party added: %s, role: %s", party.ID, party.Role)
 	myLogger.Info(msg)
-	return []byte(msg),nil
+	return []byte(msg), err
 }
 
-// grant farm sigining authority
-func (t *AgrifoodChaincode) grant_signing_authority(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
-	// can only be called by Certification Body
-	myLogger.Info("Grant sigining authority to party")
+// add transaction certificate to party
+func (t *AgrifoodChaincode) add_cert(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+	// Can only be called by party
+	myLogger.Info("Add certificate..")
 
-	party, err := t.getCallerParty(stub)
+	party, err := t.assertCallerRole(stub, t.roles...)
 	if err != nil {
-		msg := fmt.Sprintf("Error determining party: %s", err)
+		return nil, err
+	}
+
+	myLogger.Debugf("Add cert to: %s", party.ID)
+
+	// Check number of arguments
+	if len(args) != 1 {
+		msg := "Incorrect number of arguments. Expecting 1"
 		myLogger.Error(msg)
 		return nil, errors.New(msg)
 	}
 
-	myLogger.Debugf("Received party: %s, role:%s", party.ID, party.Role)
+	err = verifyID("certificate", args[0])
+	if err != nil {
+		return nil, err
+	}
 
-	// check if caller is a CertificationBody
-	if party.Role != t.roles[1] {
-		msg := "Caller is not a CertificationBody"
+	// verify cert is not registered to another party
+	owner, err := t.getCertOwner(stub, args[0])
+	if err != nil {
+		msg := fmt.Sprintf("Failed determining certificate owner: %s", err)
 		myLogger.Error(msg)
 		return nil, errors.New(msg)
 	}
 
-	// Check number of arguments
-	if len(args) != 3 {
-		msg := "Incorrect number of arguments. Expecting 3" // AccreditationID, authorized partyID, Expiration timestamp
+	if owner != "" && owner != party.ID {
+		msg := "Certificate is already registered to another party"
 		myLogger.Error(msg)
 		return nil, errors.New(msg)
 	}
 
-	// get accreditation
-	accreditation, err := t.getSigningAccreditation(stub,args[0])
+	// adding a known certificate is a no-op
+	if owner == party.ID {
+		myLogger.Info("Certificate is already registered to party")
+		return []byte("Successfully saved party"), nil
+	}
+
+	// add (encoded) cert to array
+	party.Certs = append(party.Certs, args[0])
+
+	// save updated party
+	err = t.saveParty(stub, party, false)
 	if err != nil {
-		msg := fmt.Sprintf("Error determining accreditation: %s", err)
+		msg := "Failed saving party"
 		myLogger.Error(msg)
 		return nil, errors.New(msg)
 	}
 
-	// verify accreditation is not revoked
-	if accreditation.Revoked {
-		msg := fmt.Sprintf("Error: Accreditation is revoked at %s",accreditation.RevocationTimestamp)
-		myLogger.Warning(msg)
-		return nil, errors.New(msg)
-	}
+	myLogger.Info("Saved updated party")
 
-	// see if accreditation is still valid
-	if accreditation.Expires.Before(time.Now()) {
-		msg := "Error: Accreditation expired"
+	return []byte("Successfully saved party"), nil
+}
+
+// update contact information of a party
+func (t *AgrifoodChaincode) update_party_info(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+	// Can only be called by an admin or the party itself
+	myLogger.Info("Update party info..")
+
+	// Check number of arguments
+	if len(args) != 2 {
+		msg := "Incorrect number of arguments. Expecting 2" // party ID, info (JSON object)
 		myLogger.Error(msg)
 		return nil, errors.New(msg)
 	}
 
-	// verify access rights
-	if accreditation.CertificationBody != party.ID {
-		msg := fmt.Sprintf("Party %s is not the certification body of %s", party.ID, accreditation.ID)
+	caller, isAdmin, err := t.assertAdminOrCallerRole(stub, t.roles...)
+	if err != nil {
+		return nil, err
+	}
+
+	if !isAdmin && caller.ID != args[0] {
+		msg := "The caller is not an admin or the party itself"
 		myLogger.Error(msg)
 		return nil, errors.New(msg)
 	}
 
-	// verify authorized party
-	authorizedParty, err := t.getParty(stub,args[1])
+	party, err := t.getParty(stub, args[0])
 	if err != nil {
-		msg := fmt.Sprintf("Error determining authorizedParty: %s", err)
+		msg := fmt.Sprintf("Error determining party: %s", err)
 		myLogger.Error(msg)
 		return nil, errors.New(msg)
 	}
 
-	// create and save signing authorization
-	signingAuthorization := SigningAuthorization{AuthorizedParty:authorizedParty.ID, CertifyingParty:party.ID, AccreditationID:accreditation.ID,Revoked:false}
-	signingAuthorization.Expires, err = time.Parse(time.RFC3339,args[2])
+	var info PartyInfo
+	err = json.Unmarshal([]byte(args[1]), &info)
 	if err != nil {
-		msg := "Error parsing time (expiration date)"
+		msg := fmt.Sprintf("Error parsing party info: %s", err)
 		myLogger.Error(msg)
 		return nil, errors.New(msg)
 	}
 
-	err = t.saveSigningAuthorization(stub,signingAuthorization,true)
+	// merge supplied fields, role and certs are left intact
+	if info.Name != nil {
+		party.Name = *info.Name
+	}
+	if info.Contact != nil {
+		party.Contact = *info.Contact
+	}
+
+	err = t.saveParty(stub, party, false)
 	if err != nil {
-		msg := fmt.Sprintf("Error saving signing authorization: %s", err)
+		msg := fmt.Sprintf("Error saving party: %s", err)
 		myLogger.Error(msg)
 		return nil, errors.New(msg)
 	}
 
-	msg := fmt.Sprintf("Successfully granted signing authority of %s to %s",signingAuthorization.AccreditationID,signingAuthorization.AuthorizedParty)
+	msg := fmt.Sprintf("Updated info of party %s", party.ID)
 	myLogger.Info(msg)
-	return []byte(msg),nil
+	return []byte(msg), nil
 }
 
-// revoke signing authority
-func (t *AgrifoodChaincode) revoke_signing_authority(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
-	// can only be called by Certification Body or auditor
-	myLogger.Info("Revoke sigining authority of party")
+// change role of party, its certificates are kept
+func (t *AgrifoodChaincode) change_party_role(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+	// Can only be called by an admin
+	myLogger.Info("Change party role..")
 
-	party, err := t.getCallerParty(stub)
+	isAdmin, err := t.verifyAdmin(stub)
 	if err != nil {
-		msg := fmt.Sprintf("Error determining party: %s", err)
+		msg := "Failed verifying certificates"
 		myLogger.Error(msg)
 		return nil, errors.New(msg)
 	}
 
-	myLogger.Debugf("Received party: %s, role:%s", party.ID, party.Role)
-
-	// check if caller is a Certification Body or Auditor
-	if party.Role != t.roles[1] && party.Role != t.roles[3] {
-		msg := "Caller is not a CertificationBody or Auditor"
+	if !isAdmin {
+		msg := "The caller is not an admin"
 		myLogger.Error(msg)
 		return nil, errors.New(msg)
 	}
 
 	// Check number of arguments
-	if len(args) != 3 {
-		msg := "Incorrect number of arguments. Expecting 3" // AccreditationID, authorized partyID, revokeTimestamp
+	if len(args) != 2 {
+		msg := "Incorrect number of arguments. Expecting 2" // party ID, role
 		myLogger.Error(msg)
 		return nil, errors.New(msg)
 	}
 
-	// get certificate
-	accreditation, err := t.getSigningAccreditation(stub,args[0])
-	if err != nil {
-		msg := fmt.Sprintf("Error determining accreditation: %s", err)
-		myLogger.Error(msg)
-		return nil, errors.New(msg)
+	// verify role validity
+	valid_role := false
+	for _, role := range t.roles {
+		if args[1] == role {
+			valid_role = true
+		}
 	}
 
-	// verify access rights
-	if (party.Role != t.roles[1] && accreditation.CertificationBody != party.ID) && party.Role != t.roles[3] {
-		msg := fmt.Sprintf("Party %s is not the certification body of %s, nor an auditor", party.ID, accreditation.ID)
+	if !valid_role {
+		msg := fmt.Sprintf("Invalid role: %s", args[1])
 		myLogger.Error(msg)
 		return nil, errors.New(msg)
 	}
 
-	// verify authorized party
-	authorizedParty, err := t.getParty(stub,args[1])
+	party, err := t.getParty(stub, args[0])
 	if err != nil {
-		msg := fmt.Sprintf("Error determining authorizedParty: %s", err)
+		msg := fmt.Sprintf("Error determining party: %s", err)
 		myLogger.Error(msg)
 		return nil, errors.New(msg)
 	}
 
-	signingAuthorization, err := t.getSigningAuthorization(stub, accreditation.ID,authorizedParty.ID)
-	if err != nil {
-		msg := fmt.Sprintf("Error determining signingAuthorization: %s", err)
+	if party.Deactivated {
+		msg := fmt.Sprintf("Party %s is deactivated", party.ID)
 		myLogger.Error(msg)
 		return nil, errors.New(msg)
 	}
 
-	// update authorization entry
-	signingAuthorization.Revoked = true
-	signingAuthorization.RevocationTimestamp, err = time.Parse(time.RFC3339,args[2])
-	if err != nil {
-		msg := "Error parsing time"
+	if party.Role == args[1] {
+		msg := fmt.Sprintf("Party %s already has role %s", party.ID, args[1])
 		myLogger.Error(msg)
 		return nil, errors.New(msg)
 	}
 
-	// save authorization entry
-	err = t.saveSigningAuthorization(stub,signingAuthorization,false)
+	previous_role := party.Role
+	party.Role = args[1]
+
+	err = t.saveParty(stub, party, false)
 	if err != nil {
-		msg := fmt.Sprintf("Error saving updated signingAuthorization: %s", err)
+		msg := fmt.Sprintf("Error saving party: %s", err)
 		myLogger.Error(msg)
 		return nil, errors.New(msg)
 	}
 
-	msg := fmt.Sprintf("Successfully revoked signing authority of %s to %s",signingAuthorization.AccreditationID,signingAuthorization.AuthorizedParty)
+	msg := fmt.Sprintf("Changed role of party %s from %s to %s", party.ID, previous_role, party.Role)
 	myLogger.Info(msg)
-	return []byte(msg),nil
+	return []byte(msg), nil
 }
 
-// create grapes asset
-func (t *AgrifoodChaincode) create_grapes(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
-	// can only be called by a farm
-	myLogger.Info("Create grapes asset")
+// deactivate party, it is kept so its history stays verifiable
+func (t *AgrifoodChaincode) remove_party(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+	// Can only be called by an admin
+	myLogger.Info("Remove party..")
 
-	party, err := t.getCallerParty(stub)
+	isAdmin, err := t.verifyAdmin(stub)
 	if err != nil {
-		msg := fmt.Sprintf("Error determining party: %s", err)
+		msg := "Failed verifying certificates"
 		myLogger.Error(msg)
 		return nil, errors.New(msg)
 	}
 
-	myLogger.Debugf("Received party: %s, role:%s", party.ID, party.Role)
-
-	// check if caller is a farm
-	if party.Role != t.roles[2] {
-		msg := "Caller is not a farm"
+	if !isAdmin {
+		msg := "The caller is not an admin"
 		myLogger.Error(msg)
 		return nil, errors.New(msg)
 	}
 
 	// Check number of arguments
-	if len(args) != 3 {
-		msg := "Incorrect number of arguments. Expecting 3" // UUID, created, Amount
+	if len(args) != 1 {
+		msg := "Incorrect number of arguments. Expecting 1" // party ID
 		myLogger.Error(msg)
 		return nil, errors.New(msg)
 	}
 
-	// define new grapeUnit
-	grapesUnit := GrapesUnit{UUID:args[0],Producer:party.ID}
-	grapesUnit.Created, err = time.Parse(time.RFC3339, args[1])
+	party, err := t.getParty(stub, args[0])
 	if err != nil {
-		msg := "Error parsing time"
+		msg := fmt.Sprintf("Error determining party: %s", err)
 		myLogger.Error(msg)
 		return nil, errors.New(msg)
 	}
 
-	amount, err := strconv.Atoi(args[2])
-	if err != nil {
-		msg := fmt.Sprintf("Error parsing amount: %s", err)
+	if party.Deactivated {
+		msg := fmt.Sprintf("Party %s is already deactivated", party.ID)
 		myLogger.Error(msg)
 		return nil, errors.New(msg)
 	}
-	grapesUnit.Amount = amount
 
-	// Add to ownership chain
-	ownershipEntry := OwnershipEntry{PartyID:party.ID,Timestamp:grapesUnit.Created}
-	// initiate array
-	grapesUnit.Ownership = append(grapesUnit.Ownership, ownershipEntry)
+	party.Deactivated = true
 
-	// save grape unit
-	err = t.saveGrapeUnit(stub,grapesUnit,true)
+	err = t.saveParty(stub, party, false)
 	if err != nil {
-		msg := "Error saving certificate"
+		msg := fmt.Sprintf("Error saving party: %s", err)
 		myLogger.Error(msg)
 		return nil, errors.New(msg)
 	}
 
-	msg := fmt.Sprintf("Successfully added grapes (%s), produced by %s",grapesUnit.UUID,grapesUnit.Producer)
+	msg := fmt.Sprintf("Deactivated party %s", party.ID)
 	myLogger.Info(msg)
 	return []byte(msg), nil
 }
 
-// certify grapes
-func (t *AgrifoodChaincode) certify_grapes(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
-	// can only be called by farm
-	myLogger.Info("Certify grapes asset")
+// add signing certificate
+func (t *AgrifoodChaincode) add_signing_accreditation(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+	// can only be called by AccreditationBody
+	myLogger.Info("Register new signing accreditation")
 
-	party, err := t.getCallerParty(stub)
+	party, err := t.assertCallerRole(stub, roleAccreditationBody)
 	if err != nil {
-		msg := fmt.Sprintf("Error determining party: %s", err)
+		return nil, err
+	}
+
+	// Check number of arguments
+	if len(args) < 4 || len(args) > 6 {
+		msg := "Incorrect number of arguments. Expecting 4 to 6" // ID, description,created,expiration date, (optional) scope, (optional) max quantity
 		myLogger.Error(msg)
 		return nil, errors.New(msg)
 	}
 
-	myLogger.Debugf("Received party: %s, role:%s", party.ID, party.Role)
+	err = verifyID("accreditation ID", args[0])
+	if err != nil {
+		return nil, err
+	}
 
-	// check if caller is a farm
-	if party.Role != t.roles[2] {
-		msg := "Caller is not a farm"
+	signingAccreditation := SigningAccreditation{ID:args[0],AccreditationBody:party.ID,Description:args[1],Revoked:false}
+	signingAccreditation.Created, err = time.Parse(time.RFC3339,args[2])
+	if err != nil {
+		msg := "Error parsing time (created date)"
 		myLogger.Error(msg)
 		return nil, errors.New(msg)
 	}
 
-	// Check number of arguments
-	if len(args) != 3 {
-		msg := "Incorrect number of arguments. Expecting 3" // UUID, accreditationID, timestamp
+	signingAccreditation.Expires, err = time.Parse(time.RFC3339,args[3])
+	if err != nil {
+		msg := "Error parsing time (expiration date)"
 		myLogger.Error(msg)
 		return nil, errors.New(msg)
 	}
 
-	// get grapes unit
-	grapesUnit, err := t.getGrapesUnit(stub,args[0])
-	if err != nil {
-		msg := fmt.Sprintf("Error determining grapesUnit: %s", err)
+	// verify accreditation expires after it is created
+	if !signingAccreditation.Expires.After(signingAccreditation.Created) {
+		msg := fmt.Sprintf("Error: expiration date %s is not after created date %s", args[3], args[2])
 		myLogger.Error(msg)
 		return nil, errors.New(msg)
 	}
 
-	// verify if caller is producer of grapes
-	if grapesUnit.Producer != party.ID {
-		msg := fmt.Sprintf("Caller is not producer of grapes: %s", grapesUnit.UUID)
+	// verify accreditation is not expired already
+	now, err := txTime(stub)
+	if err != nil {
+		msg := fmt.Sprintf("Error determining transaction time: %s", err)
 		myLogger.Error(msg)
 		return nil, errors.New(msg)
 	}
 
-	// verify sigining authority of farm
-	signAuth, err := t.getSigningAuthorization(stub,args[1],party.ID)
-	if err != nil {
-		msg := fmt.Sprintf("Error determining signing authority: %s", err)
+	if !signingAccreditation.Expires.After(now) {
+		msg := fmt.Sprintf("Error: expiration date %s is in the past", args[3])
 		myLogger.Error(msg)
 		return nil, errors.New(msg)
 	}
 
-	// validate sigining authority
-	if signAuth.Revoked {
-		msg := fmt.Sprintf("No signing authority for %s on %s",signAuth.AccreditationID,party.ID)
+	// optional scope: JSON array of crop types
+	if len(args) >= 5 && args[4] != "" {
+		err = json.Unmarshal([]byte(args[4]), &signingAccreditation.Scope)
+		if err != nil {
+			msg := fmt.Sprintf("Error parsing scope: %s", err)
+			myLogger.Error(msg)
+			return nil, errors.New(msg)
+		}
+	}
+
+	// optional maximum amount to certify
+	if len(args) == 6 {
+		signingAccreditation.MaxQuantity, err = strconv.Atoi(args[5])
+		if err != nil || signingAccreditation.MaxQuantity < 0 {
+			msg := fmt.Sprintf("Invalid max quantity: %s", args[5])
+			myLogger.Error(msg)
+			return nil, errors.New(msg)
+		}
+	}
+
+	// save certificate
+	err = t.saveSigningAccreditation(stub, signingAccreditation,true)
+	if err != nil {
+		msg := fmt.Sprintf("Error saving signing accreditation: %s", err)
 		myLogger.Error(msg)
 		return nil, errors.New(msg)
 	}
 
-	// check expiration date
-	if signAuth.Expires.Before(time.Now()){
-		msg := fmt.Sprintf("Signing authority for %s by %s has expired",signAuth.AccreditationID,party.ID)
+	msg := fmt.Sprintf("New signing accreditation added by %s",party.ID)
+	myLogger.Info(msg)
+	return []byte(msg), nil
+}
+
+// renew signing accreditation, the successor takes over description, bodies and scope
+func (t *AgrifoodChaincode) renew_signing_accreditation(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+	// can only be called by AccreditationBody
+	myLogger.Info("Renew signing accreditation")
+
+	party, err := t.assertCallerRole(stub, roleAccreditationBody)
+	if err != nil {
+		return nil, err
+	}
+
+	// Check number of arguments
+	if len(args) < 4 || len(args) > 5 {
+		msg := "Incorrect number of arguments. Expecting 4 or 5" // AccreditationID, new ID, created, expiration date, (optional) migrate authorizations
 		myLogger.Error(msg)
 		return nil, errors.New(msg)
 	}
 
+	err = verifyID("accreditation ID", args[1])
+	if err != nil {
+		return nil, err
+	}
+
+	// optional flag to move active authorizations to the successor
+	migrate := false
+	if len(args) == 5 {
+		migrate, err = strconv.ParseBool(args[4])
+		if err != nil {
+			msg := fmt.Sprintf("Invalid migrate flag: %s", args[4])
+			myLogger.Error(msg)
+			return nil, errors.New(msg)
+		}
+	}
+
 	// get accreditation
-	accreditation, err := t.getSigningAccreditation(stub,signAuth.AccreditationID)
+	accreditation, err := t.getSigningAccreditation(stub,args[0])
 	if err != nil {
 		msg := fmt.Sprintf("Error determining accreditation: %s", err)
 		myLogger.Error(msg)
 		return nil, errors.New(msg)
 	}
 
-	// see if accreditation is valid
+	if accreditation.AccreditationBody != party.ID {
+		msg := fmt.Sprintf("Error: Accreditation body (%s) is not the issuer of this accreditation (%s)",party.ID, accreditation.ID)
+		myLogger.Warning(msg)
+		return nil, errors.New(msg)
+	}
+
+	// revoked accreditations are withdrawn, not renewed
 	if accreditation.Revoked {
-		msg := fmt.Sprintf("Invalid signing accreditation: %s", accreditation.ID)
-		myLogger.Error(msg)
+		msg := fmt.Sprintf("Error: cannot renew accreditation %s, it was revoked at %s",accreditation.ID,accreditation.RevocationTimestamp)
+		myLogger.Warning(msg)
 		return nil, errors.New(msg)
 	}
 
-	// check expiration date
-	if accreditation.Expires.Before(time.Now()){
-		msg := fmt.Sprintf("Accreditation %s has expired",signAuth.AccreditationID)
+	if accreditation.SupersededBy != "" {
+		msg := fmt.Sprintf("Error: accreditation %s is already renewed by %s",accreditation.ID,accreditation.SupersededBy)
 		myLogger.Error(msg)
 		return nil, errors.New(msg)
 	}
 
-	// accreditation is valid
-
-	// actually attach accreditation signature to grapes
-	signature := AccreditationSignature{Issuer:signAuth.AuthorizedParty, AccreditationID:accreditation.ID,Revoked:false}
-	signature.Issued, err = time.Parse(time.RFC3339, args[2])
+	successor := SigningAccreditation{ID:args[1],Description:accreditation.Description,AccreditationBody:accreditation.AccreditationBody,CertificationBody:accreditation.CertificationBody,Scope:accreditation.Scope,MaxQuantity:accreditation.MaxQuantity,Supersedes:accreditation.ID}
+	successor.Created, err = time.Parse(time.RFC3339,args[2])
 	if err != nil {
-		msg := "Error parsing time"
+		msg := "Error parsing time (created date)"
 		myLogger.Error(msg)
 		return nil, errors.New(msg)
 	}
 
-	// append signature to grapes unit
-	grapesUnit.AccreditationSignatures = append(grapesUnit.AccreditationSignatures, signature)
-
-	// save to world-state
-	err = t.saveGrapeUnit(stub,grapesUnit,false)
+	successor.Expires, err = time.Parse(time.RFC3339,args[3])
 	if err != nil {
-		msg := "Error saving grapeUnit"
+		msg := "Error parsing time (expiration date)"
 		myLogger.Error(msg)
 		return nil, errors.New(msg)
 	}
 
-	msg := fmt.Sprintf("Successfully signed signature for grapes: %s",grapesUnit.UUID)
-	myLogger.Info(msg)
-	return []byte(msg),nil
-}
-
-// revoke signature on grape units
-func (t *AgrifoodChaincode) revoke_signature(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
-	// can only be called by Auditors and Farms that issued the signature
-	myLogger.Info("Revoke signature on grapes unit")
-
-	party, err := t.getCallerParty(stub)
-	if err != nil {
-		msg := fmt.Sprintf("Error determining party: %s", err)
+	// verify accreditation expires after it is created
+	if !successor.Expires.After(successor.Created) {
+		msg := fmt.Sprintf("Error: expiration date %s is not after created date %s", args[3], args[2])
 		myLogger.Error(msg)
 		return nil, errors.New(msg)
 	}
 
-	myLogger.Debugf("Received party: %s, role:%s", party.ID, party.Role)
-
-	// check if caller is a Farm or Auditor
-	if party.Role != t.roles[2] && party.Role != t.roles[3] {
-		msg := "Caller is not a Farm or Auditor"
+	now, err := txTime(stub)
+	if err != nil {
+		msg := fmt.Sprintf("Error determining transaction time: %s", err)
 		myLogger.Error(msg)
 		return nil, errors.New(msg)
 	}
 
-	// Check number of arguments
-	if len(args) != 3 {
-		msg := "Incorrect number of arguments. Expecting 3" // UUID, accreditationID, revokeTimestamp
+	if !successor.Expires.After(now) {
+		msg := fmt.Sprintf("Error: expiration date %s is in the past", args[3])
 		myLogger.Error(msg)
 		return nil, errors.New(msg)
 	}
 
-	// get grape unit from storage
-	grapeUnit, err := t.getGrapesUnit(stub,args[0])
+	err = t.saveSigningAccreditation(stub, successor, true)
 	if err != nil {
-		msg := fmt.Sprintf("Error determining grapeUnit: %s", err)
+		msg := fmt.Sprintf("Error saving signing accreditation: %s", err)
 		myLogger.Error(msg)
 		return nil, errors.New(msg)
 	}
 
-	// if caller is farm, check if it's the producer of the grapes
-	if party.Role == t.roles[2] && grapeUnit.Producer != party.ID {
-		msg := fmt.Sprintf("Farm is not producer of targeted grapes: %s", grapeUnit.UUID)
+	// link predecessor to successor
+	accreditation.SupersededBy = successor.ID
+	err = t.saveSigningAccreditation(stub, accreditation, false)
+	if err != nil {
+		msg := fmt.Sprintf("Error saving signing accreditation: %s", err)
 		myLogger.Error(msg)
 		return nil, errors.New(msg)
 	}
 
-	// loop over signatures
-	for i, signature := range grapeUnit.AccreditationSignatures {
-		// find correct signature
-		if signature.AccreditationID == args[2] {
-			// revoke signature
-			signature.Revoked = true
-			signature.RevocationTimestamp, err = time.Parse(time.RFC3339,args[3])
+	// carry active authorizations over to the successor
+	migrated := 0
+	if migrate {
+		authorizations, err := t.getSigningAuthorizations(stub)
+		if err != nil {
+			msg := fmt.Sprintf("Error retrieving signing authorizations: %s", err)
+			myLogger.Error(msg)
+			return nil, errors.New(msg)
+		}
+
+		for _, auth := range authorizations {
+			if auth.AccreditationID != accreditation.ID || auth.Revoked || isExpired(auth.Expires, now) {
+				continue
+			}
+
+			// the original authorization is replaced by the migrated one
+			original := auth
+			original.Revoked = true
+			original.RevocationTimestamp = now
+			original.RevocationReason = "Migrated to " + successor.ID
+			err = t.saveSigningAuthorization(stub, original, false)
 			if err != nil {
-				msg := "Error parsing time"
+				msg := fmt.Sprintf("Error saving signing authorization: %s", err)
 				myLogger.Error(msg)
 				return nil, errors.New(msg)
 			}
 
-			// update signature
-			grapeUnit.AccreditationSignatures[i] = signature
+			auth.AccreditationID = successor.ID
+			// an authorization can't outlive the accreditation it is granted under
+			if auth.Expires.After(successor.Expires) {
+				auth.Expires = successor.Expires
+			}
+			err = t.saveSigningAuthorization(stub, auth, true)
+			if err != nil {
+				msg := fmt.Sprintf("Error saving signing authorization: %s", err)
+				myLogger.Error(msg)
+				return nil, errors.New(msg)
+			}
+			migrated++
 		}
 	}
 
-	// save to world-state
-	err = t.saveGrapeUnit(stub,grapeUnit,false)
-	if err != nil {
-		msg := fmt.Sprintf("Error saving updated grapeUnit: %s", err)
-		myLogger.Error(msg)
-		return nil, errors.New(msg)
-	}
-
-	// done
-	msg := fmt.Sprintf("Successfully revoked signature of %s for grapes: %s",args[2],grapeUnit.UUID)
+	msg := fmt.Sprintf("Accreditation %s renewed by %s, %d authorizations migrated",accreditation.ID,successor.ID,migrated)
 	myLogger.Info(msg)
-	return []byte(msg),nil
+	return []byte(msg), nil
 }
 
-// transfer grapes to new owner (trader)
-func (t *AgrifoodChaincode) transfer_grapes(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
-	// can only be called by farms and traders
-	myLogger.Info("Transfer ownership of grapes")
+// issue signing accreditation to certification body
+func (t *AgrifoodChaincode) issue_signing_accreditation(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+	// can only be called by AccreditationBody
+	myLogger.Info("Assign signing accreditation to a certificate body")
 
-	party, err := t.getCallerParty(stub)
+	party, err := t.assertCallerRole(stub, roleAccreditationBody)
 	if err != nil {
-		msg := fmt.Sprintf("Error determining party: %s", err)
+		return nil, err
+	}
+
+	// Check number of arguments
+	if len(args) < 2 || len(args) > 3 {
+		msg := "Incorrect number of arguments. Expecting 2 or 3" // AccreditationID, Certificate body ID, (optional) reassign
 		myLogger.Error(msg)
 		return nil, errors.New(msg)
 	}
 
-	myLogger.Debugf("Received party: %s, role:%s", party.ID, party.Role)
+	// optional flag to reassign an already issued accreditation
+	reassign := false
+	if len(args) == 3 {
+		reassign, err = strconv.ParseBool(args[2])
+		if err != nil {
+			msg := fmt.Sprintf("Invalid reassign flag: %s", args[2])
+			myLogger.Error(msg)
+			return nil, errors.New(msg)
+		}
+	}
 
-	// check if caller is a Farm or Trader
-	if party.Role != t.roles[2] && party.Role != t.roles[4] {
-		msg := "Caller is not a Farm or Trader"
+	// get accreditation
+	accreditation, err := t.getSigningAccreditation(stub,args[0])
+	if err != nil {
+		msg := fmt.Sprintf("Error determining accreditation: %s", err)
 		myLogger.Error(msg)
 		return nil, errors.New(msg)
 	}
 
-	// Check number of arguments
-	if len(args) != 3 {
-		msg := "Incorrect number of arguments. Expecting 3" // UUID, newParty, timestamp
+	// see if accreditation is still valid
+	now, err := txTime(stub)
+	if err != nil {
+		msg := fmt.Sprintf("Error determining transaction time: %s", err)
 		myLogger.Error(msg)
 		return nil, errors.New(msg)
 	}
 
-	// get grapesUnit
-	grapesUnit, err := t.getGrapesUnit(stub,args[0])
-	if err != nil {
-		msg := fmt.Sprintf("Error determining grapesUnit: %s", err)
+	if isExpired(accreditation.Expires, now) {
+		msg := "Error: Accreditation expired"
 		myLogger.Error(msg)
 		return nil, errors.New(msg)
 	}
 
-	// verify caller is current owner of grapes
-	if grapesUnit.Ownership[len(grapesUnit.Ownership)-1].PartyID != party.ID {
-		msg := fmt.Sprintf("Caller is not the current owner of the grapes: %s", grapesUnit.UUID)
-		myLogger.Error(msg)
+	if accreditation.AccreditationBody != party.ID {
+		msg := fmt.Sprintf("Error: Accreditation body (%s) is not the issuer of this accreditation (%s)",party.ID, accreditation.ID)
+		myLogger.Warning(msg)
 		return nil, errors.New(msg)
 	}
 
-	// get newParty
-	newParty, err := t.getParty(stub, args[1])
-	if err != nil {
-		msg := fmt.Sprintf("Error determining new party: %s", err)
-		myLogger.Error(msg)
+	// verify accreditation is not revoked
+	if accreditation.Revoked {
+		msg := fmt.Sprintf("Error: cannot issue accreditation %s, it was revoked at %s",accreditation.ID,accreditation.RevocationTimestamp)
+		myLogger.Warning(msg)
 		return nil, errors.New(msg)
 	}
 
-	// create new provenance entry
-	ownershipEntry := OwnershipEntry{PartyID:newParty.ID}
-	ownershipEntry.Timestamp, err = time.Parse(time.RFC3339,args[2])
+	// get party
+	certBody, err := t.getParty(stub,args[1])
 	if err != nil {
-		msg := fmt.Sprintf("Error parsing timestamp: %s", err)
+		msg := fmt.Sprintf("Error determining party: %s", err)
 		myLogger.Error(msg)
 		return nil, errors.New(msg)
 	}
 
-	// verify ownership entry timestamp is after last provenance entry timestamp
-	if grapesUnit.Ownership[len(grapesUnit.Ownership)-1].Timestamp.After(ownershipEntry.Timestamp) {
-		msg := "new ownership timestamp needs to be after latest ownership entry timestamp"
-		myLogger.Error(msg)
+	// verify party is a certificate body
+	if certBody.Role != roleCertificationBody {
+		msg := fmt.Sprintf("Error: supplied party is no CertifiactionBody: %s", err)
+		myLogger.Warning(msg)
 		return nil, errors.New(msg)
 	}
 
-	// append provenance entry
-	grapesUnit.Ownership = append(grapesUnit.Ownership, ownershipEntry)
+	// verify certification body is active
+	if certBody.Deactivated {
+		msg := fmt.Sprintf("Error: CertificationBody %s is deactivated", certBody.ID)
+		myLogger.Warning(msg)
+		return nil, errors.New(msg)
+	}
 
-	// save to world-state
-	err = t.saveGrapeUnit(stub,grapesUnit,false)
+	// verify accreditation is not issued already, unless reassigning explicitly
+	if accreditation.CertificationBody != "" && !reassign {
+		msg := fmt.Sprintf("Error: accreditation %s is already issued to %s", accreditation.ID, accreditation.CertificationBody)
+		myLogger.Warning(msg)
+		return nil, errors.New(msg)
+	}
+
+	// set certification body on accreditation
+	accreditation.CertificationBody = certBody.ID
+
+	// save updated certificate
+	err = t.saveSigningAccreditation(stub, accreditation,false)
 	if err != nil {
-		msg := fmt.Sprintf("Error saving updated grapeUnit: %s", err)
+		msg := "Error saving certificate"
 		myLogger.Error(msg)
 		return nil, errors.New(msg)
 	}
 
-	// done
-	msg := fmt.Sprintf("Successfully transferred grapes %s from %s to: %s",grapesUnit.UUID,party.ID, ownershipEntry.PartyID)
+	msg := fmt.Sprintf("Successfully added %s as certification body on %s",certBody.ID, accreditation.ID)
 	myLogger.Info(msg)
-	return []byte(msg),nil
+	return []byte(msg), nil
 }
 
-// save grape unit to world-state
-func (t *AgrifoodChaincode) saveGrapeUnit(stub shim.ChaincodeStubInterface, grapeUnit GrapesUnit, new bool) error {
-	grapes, err := t.getGrapes(stub)
+// revoke signing accreditation
+func (t *AgrifoodChaincode) revoke_signing_accreditation(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+	// can only be called by AccreditationBody or auditor
+	myLogger.Info("Revoke signing accreditation")
+
+	party, err := t.assertCallerRole(stub, roleAccreditationBody, roleAuditor)
 	if err != nil {
-		msg := fmt.Sprintf("Error retrieving signing grapes: %s", err)
-		myLogger.Error(msg)
-		return errors.New(msg)
+		return nil, err
 	}
 
-	if !new { //update
-		// set new grape unit state
-		for i, v := range grapes {
-			if v.UUID == grapeUnit.UUID {
-				grapes[i] = grapeUnit
-			}
+	// Check number of arguments
+	if len(args) < 2 || len(args) > 3 {
+		msg := "Incorrect number of arguments. Expecting 2 or 3" // AccreditationID, revokeTimestamp, (optional) reason
+		myLogger.Error(msg)
+		return nil, errors.New(msg)
+	}
+
+	// get accreditation
+	accreditation, err := t.getSigningAccreditation(stub,args[0])
+	if err != nil {
+		msg := fmt.Sprintf("Error determining accreditation: %s", err)
+		myLogger.Error(msg)
+		return nil, errors.New(msg)
+	}
+
+	// verify if accreditation body is owner of certificate
+	if party.Role == roleAccreditationBody && accreditation.AccreditationBody != party.ID {
+		msg := fmt.Sprintf("Error: Accreditation body (%s) is not the issuer of this accreditation (%s)",party.ID, accreditation.ID)
+		myLogger.Warning(msg)
+		return nil, errors.New(msg)
+	}
+
+	// Revoke certificate
+	accreditation.Revoked = true
+	accreditation.RevocationTimestamp, err = time.Parse(time.RFC3339, args[1])
+	if err != nil {
+		msg := "Error parsing time"
+		myLogger.Error(msg)
+		return nil, errors.New(msg)
+	}
+
+	err = verifyTimestamp(stub, accreditation.RevocationTimestamp)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(args) == 3 {
+		accreditation.RevocationReason = args[2]
+	}
+
+	// save updated accreditation
+	err = t.saveSigningAccreditation(stub, accreditation, false)
+	if err != nil {
+		msg := "Error saving updated accreditation"
+		myLogger.Error(msg)
+		return nil, errors.New(msg)
+	}
+
+	// notify certification bodies relying on the accreditation
+	err = setEvent(stub, "accreditation_revoked", AccreditationRevokedEvent{AccreditationID:accreditation.ID, RevocationTimestamp:accreditation.RevocationTimestamp, RevocationReason:accreditation.RevocationReason})
+	if err != nil {
+		return nil, err
+	}
+
+	msg := fmt.Sprintf("Successfully revoked signing accreditation %s", accreditation.ID)
+	myLogger.Info(msg)
+	return []byte(msg),nil
+}
+
+// set the crop types a signing accreditation applies to
+func (t *AgrifoodChaincode) set_accreditation_scope(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+	// can only be called by the AccreditationBody that created the accreditation
+	myLogger.Info("Set scope of signing accreditation")
+
+	party, err := t.assertCallerRole(stub, roleAccreditationBody)
+	if err != nil {
+		return nil, err
+	}
+
+	// Check number of arguments
+	if len(args) != 2 {
+		msg := "Incorrect number of arguments. Expecting 2" // AccreditationID, scope (JSON array of crop types)
+		myLogger.Error(msg)
+		return nil, errors.New(msg)
+	}
+
+	// get accreditation
+	accreditation, err := t.getSigningAccreditation(stub,args[0])
+	if err != nil {
+		msg := fmt.Sprintf("Error determining accreditation: %s", err)
+		myLogger.Error(msg)
+		return nil, errors.New(msg)
+	}
+
+	if accreditation.AccreditationBody != party.ID {
+		msg := fmt.Sprintf("Error: Accreditation body (%s) is not the issuer of this accreditation (%s)",party.ID, accreditation.ID)
+		myLogger.Warning(msg)
+		return nil, errors.New(msg)
+	}
+
+	var scope []string
+	err = json.Unmarshal([]byte(args[1]), &scope)
+	if err != nil {
+		msg := fmt.Sprintf("Error parsing scope: %s", err)
+		myLogger.Error(msg)
+		return nil, errors.New(msg)
+	}
+	accreditation.Scope = scope
+
+	// save updated accreditation
+	err = t.saveSigningAccreditation(stub, accreditation, false)
+	if err != nil {
+		msg := "Error saving updated accreditation"
+		myLogger.Error(msg)
+		return nil, errors.New(msg)
+	}
+
+	msg := fmt.Sprintf("Successfully set scope of signing accreditation %s", accreditation.ID)
+	myLogger.Info(msg)
+	return []byte(msg),nil
+}
+
+// revoke all expired signing accreditations
+func (t *AgrifoodChaincode) revoke_expired_accreditations(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+	// can only be called by an admin or auditor
+	myLogger.Info("Revoke expired signing accreditations")
+
+	_, _, err := t.assertAdminOrCallerRole(stub, roleAuditor)
+	if err != nil {
+		return nil, err
+	}
+
+	// Check number of arguments
+	if len(args) != 1 {
+		msg := "Incorrect number of arguments. Expecting 1" // revokeTimestamp
+		myLogger.Error(msg)
+		return nil, errors.New(msg)
+	}
+
+	revocationTimestamp, err := time.Parse(time.RFC3339, args[0])
+	if err != nil {
+		msg := "Error parsing time"
+		myLogger.Error(msg)
+		return nil, errors.New(msg)
+	}
+
+	err = verifyTimestamp(stub, revocationTimestamp)
+	if err != nil {
+		return nil, err
+	}
+
+	accreditations, err := t.getSigningAccreditations(stub)
+	if err != nil {
+		msg := fmt.Sprintf("Error retrieving accreditations: %s", err)
+		myLogger.Error(msg)
+		return nil, errors.New(msg)
+	}
+
+	// mark expired accreditations as revoked
+	now, err := txTime(stub)
+	if err != nil {
+		msg := fmt.Sprintf("Error determining transaction time: %s", err)
+		myLogger.Error(msg)
+		return nil, errors.New(msg)
+	}
+
+	var revoked []string
+	for i, accreditation := range accreditations {
+		if !accreditation.Revoked && isExpired(accreditation.Expires, now) {
+			accreditations[i].Revoked = true
+			accreditations[i].RevocationTimestamp = revocationTimestamp
+			accreditations[i].RevocationReason = "Expired"
+			revoked = append(revoked, accreditation.ID)
 		}
-	} else { // save new
-		// verify uniqueness
-		for _, v := range grapes {
-			if v.UUID == grapeUnit.UUID {
-				msg := "Error: GrapeUnits UUID needs to be unique"
+	}
+
+	err = t.putSigningAccreditations(stub, accreditations)
+	if err != nil {
+		msg := fmt.Sprintf("Error saving updated accreditations: %s", err)
+		myLogger.Error(msg)
+		return nil, errors.New(msg)
+	}
+
+	msg := fmt.Sprintf("Successfully revoked %d expired signing accreditations: %v", len(revoked), revoked)
+	myLogger.Info(msg)
+	return []byte(msg),nil
+}
+
+// reassign signing accreditations from one accreditation body to another
+func (t *AgrifoodChaincode) transfer_accreditation(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+	// Can only be called by an admin
+	myLogger.Info("Transfer signing accreditations to other accreditation body")
+
+	correctCaller, err := t.verifyAdmin(stub)
+	if err != nil {
+		msg := "Failed verifying certificates"
+		myLogger.Error(msg)
+		return nil, errors.New(msg)
+	}
+
+	// caller is not admin, return
+	if !correctCaller {
+		msg := "The caller is not an admin"
+		myLogger.Error(msg)
+		return nil, errors.New(msg)
+	}
+
+	// Check number of arguments
+	if len(args) != 3 {
+		msg := "Incorrect number of arguments. Expecting 3" // current AccreditationBody, new AccreditationBody, AccreditationIDs (JSON array)
+		myLogger.Error(msg)
+		return nil, errors.New(msg)
+	}
+
+	// verify new accreditation body
+	newBody, err := t.getParty(stub, args[1])
+	if err != nil {
+		msg := fmt.Sprintf("Error determining new accreditation body: %s", err)
+		myLogger.Error(msg)
+		return nil, errors.New(msg)
+	}
+
+	if newBody.Role != roleAccreditationBody {
+		msg := fmt.Sprintf("Error: supplied party is no AccreditationBody: %s", newBody.ID)
+		myLogger.Error(msg)
+		return nil, errors.New(msg)
+	}
+
+	if newBody.Deactivated {
+		msg := fmt.Sprintf("Error: new accreditation body %s is deactivated", newBody.ID)
+		myLogger.Error(msg)
+		return nil, errors.New(msg)
+	}
+
+	var accreditationIDs []string
+	err = json.Unmarshal([]byte(args[2]), &accreditationIDs)
+	if err != nil {
+		msg := fmt.Sprintf("Error parsing accreditation IDs: %s", err)
+		myLogger.Error(msg)
+		return nil, errors.New(msg)
+	}
+
+	accreditations, err := t.getSigningAccreditations(stub)
+	if err != nil {
+		msg := fmt.Sprintf("Error retrieving accreditations: %s", err)
+		myLogger.Error(msg)
+		return nil, errors.New(msg)
+	}
+
+	// reassign all accreditations, or none if one is not owned by the current body
+	for _, accreditationID := range accreditationIDs {
+		found := false
+		for i, accreditation := range accreditations {
+			if accreditation.ID != accreditationID {
+				continue
+			}
+
+			if accreditation.AccreditationBody != args[0] {
+				msg := fmt.Sprintf("Error: Accreditation body (%s) is not the issuer of this accreditation (%s)", args[0], accreditation.ID)
 				myLogger.Error(msg)
-				return errors.New(msg)
+				return nil, errors.New(msg)
 			}
+
+			accreditations[i].AccreditationBody = newBody.ID
+			found = true
+		}
+
+		if !found {
+			msg := fmt.Sprintf("Unable to determine SigningAccreditation: %s", accreditationID)
+			myLogger.Error(msg)
+			return nil, errors.New(msg)
+		}
+	}
+
+	err = t.putSigningAccreditations(stub, accreditations)
+	if err != nil {
+		msg := fmt.Sprintf("Error saving updated accreditations: %s", err)
+		myLogger.Error(msg)
+		return nil, errors.New(msg)
+	}
+
+	msg := fmt.Sprintf("Successfully transferred %d signing accreditations from %s to %s", len(accreditationIDs), args[0], newBody.ID)
+	myLogger.Info(msg)
+	return []byte(msg),nil
+}
+
+// grant farm sigining authority
+func (t *AgrifoodChaincode) grant_signing_authority(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+	// can only be called by Certification Body
+	myLogger.Info("Grant sigining authority to party")
+
+	party, err := t.assertCallerRole(stub, roleCertificationBody)
+	if err != nil {
+		return nil, err
+	}
+
+	// Check number of arguments
+	if len(args) != 3 {
+		msg := "Incorrect number of arguments. Expecting 3" // AccreditationID, authorized partyID, Expiration timestamp
+		myLogger.Error(msg)
+		return nil, errors.New(msg)
+	}
+
+	// get and validate accreditation
+	accreditation, err := t.getGrantableAccreditation(stub, party, args[0])
+	if err != nil {
+		return nil, err
+	}
+
+	// verify authorized party
+	authorizedParty, err := t.getParty(stub,args[1])
+	if err != nil {
+		msg := fmt.Sprintf("Error determining authorizedParty: %s", err)
+		myLogger.Error(msg)
+		return nil, errors.New(msg)
+	}
+
+	// only active farms can be authorized to sign
+	if authorizedParty.Role != roleFarm {
+		msg := fmt.Sprintf("Party %s is no Farm: %s", authorizedParty.ID, authorizedParty.Role)
+		myLogger.Error(msg)
+		return nil, errors.New(msg)
+	}
+
+	if authorizedParty.Deactivated {
+		msg := fmt.Sprintf("Party %s is deactivated", authorizedParty.ID)
+		myLogger.Error(msg)
+		return nil, errors.New(msg)
+	}
+
+	// create and save signing authorization
+	signingAuthorization := SigningAuthorization{AuthorizedParty:authorizedParty.ID, CertifyingParty:party.ID, AccreditationID:accreditation.ID,Revoked:false}
+	signingAuthorization.Expires, err = time.Parse(time.RFC3339,args[2])
+	if err != nil {
+		msg := "Error parsing time (expiration date)"
+		myLogger.Error(msg)
+		return nil, errors.New(msg)
+	}
+
+	err = t.verifyAuthorizationExpiry(stub, accreditation, signingAuthorization.Expires)
+	if err != nil {
+		return nil, err
+	}
+
+	// an existing authorization (e.g. a revoked one) is replaced by the new grant
+	err = t.upsertSigningAuthorization(stub,signingAuthorization)
+	if err != nil {
+		msg := fmt.Sprintf("Error saving signing authorization: %s", err)
+		myLogger.Error(msg)
+		return nil, errors.New(msg)
+	}
+
+	msg := fmt.Sprintf("Successfully granted signing authority of %s to %s",signingAuthorization.AccreditationID,signingAuthorization.AuthorizedParty)
+	myLogger.Info(msg)
+	return []byte(msg),nil
+}
+
+// grant multiple farms signing authority under one accreditation
+func (t *AgrifoodChaincode) grant_signing_authority_batch(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+	// can only be called by Certification Body
+	myLogger.Info("Grant sigining authority to multiple parties")
+
+	party, err := t.assertCallerRole(stub, roleCertificationBody)
+	if err != nil {
+		return nil, err
+	}
+
+	// Check number of arguments
+	if len(args) != 3 {
+		msg := "Incorrect number of arguments. Expecting 3" // AccreditationID, authorized partyIDs (JSON array), Expiration timestamp
+		myLogger.Error(msg)
+		return nil, errors.New(msg)
+	}
+
+	// get and validate accreditation
+	accreditation, err := t.getGrantableAccreditation(stub, party, args[0])
+	if err != nil {
+		return nil, err
+	}
+
+	var partyIDs []string
+	err = json.Unmarshal([]byte(args[1]), &partyIDs)
+	if err != nil {
+		msg := fmt.Sprintf("Error parsing party IDs: %s", err)
+		myLogger.Error(msg)
+		return nil, errors.New(msg)
+	}
+
+	expires, err := time.Parse(time.RFC3339,args[2])
+	if err != nil {
+		msg := "Error parsing time (expiration date)"
+		myLogger.Error(msg)
+		return nil, errors.New(msg)
+	}
+
+	err = t.verifyAuthorizationExpiry(stub, accreditation, expires)
+	if err != nil {
+		return nil, err
+	}
+
+	// grant authority to each valid farm
+	var results []BatchResult
+	for _, partyID := range partyIDs {
+		result := BatchResult{PartyID:partyID}
+
+		authorizedParty, err := t.getParty(stub, partyID)
+		if err != nil {
+			result.Message = fmt.Sprintf("Error determining authorizedParty: %s", err)
+		} else if authorizedParty.Role != roleFarm {
+			result.Message = fmt.Sprintf("Party is no Farm: %s", authorizedParty.Role)
+		} else if authorizedParty.Deactivated {
+			result.Message = "Party is deactivated"
+		} else {
+			signingAuthorization := SigningAuthorization{AuthorizedParty:authorizedParty.ID, CertifyingParty:party.ID, AccreditationID:accreditation.ID, Expires:expires, Revoked:false}
+			err = t.upsertSigningAuthorization(stub,signingAuthorization)
+			if err != nil {
+				result.Message = fmt.Sprintf("Error saving signing authorization: %s", err)
+			} else {
+				result.Success = true
+				result.Message = fmt.Sprintf("Successfully granted signing authority of %s", accreditation.ID)
+			}
+		}
+
+		if !result.Success {
+			myLogger.Warning(result.Message)
+		}
+		results = append(results, result)
+	}
+
+	results_b, err := json.Marshal(results)
+	if err != nil {
+		msg := fmt.Sprintf("Error marshalling results: %s", err)
+		myLogger.Error(msg)
+		return nil, errors.New(msg)
+	}
+
+	myLogger.Infof("Granted signing authority of %s to batch of %d parties", accreditation.ID, len(partyIDs))
+	return results_b,nil
+}
+
+// get accreditation a certification body can currently grant signing authority for
+func (t *AgrifoodChaincode) getGrantableAccreditation(stub shim.ChaincodeStubInterface, party Party, accreditationID string) (SigningAccreditation, error) {
+	// get accreditation
+	accreditation, err := t.getSigningAccreditation(stub,accreditationID)
+	if err != nil {
+		msg := fmt.Sprintf("Error determining accreditation: %s", err)
+		myLogger.Error(msg)
+		return SigningAccreditation{}, errors.New(msg)
+	}
+
+	// verify accreditation is not revoked
+	if accreditation.Revoked {
+		msg := fmt.Sprintf("Error: Accreditation is revoked at %s",accreditation.RevocationTimestamp)
+		myLogger.Warning(msg)
+		return SigningAccreditation{}, errors.New(msg)
+	}
+
+	// see if accreditation is still valid
+	now, err := txTime(stub)
+	if err != nil {
+		msg := fmt.Sprintf("Error determining transaction time: %s", err)
+		myLogger.Error(msg)
+		return SigningAccreditation{}, errors.New(msg)
+	}
+
+	if isExpired(accreditation.Expires, now) {
+		msg := "Error: Accreditation expired"
+		myLogger.Error(msg)
+		return SigningAccreditation{}, errors.New(msg)
+	}
+
+	// verify access rights
+	if accreditation.CertificationBody != party.ID {
+		msg := fmt.Sprintf("Party %s is not the certification body of %s", party.ID, accreditation.ID)
+		myLogger.Error(msg)
+		return SigningAccreditation{}, errors.New(msg)
+	}
+
+	return accreditation, nil
+}
+
+// verify expiration date of a new signing authorization is in the future and within the accreditation's validity
+func (t *AgrifoodChaincode) verifyAuthorizationExpiry(stub shim.ChaincodeStubInterface, accreditation SigningAccreditation, expires time.Time) error {
+	now, err := txTime(stub)
+	if err != nil {
+		msg := fmt.Sprintf("Error determining transaction time: %s", err)
+		myLogger.Error(msg)
+		return errors.New(msg)
+	}
+
+	if !expires.After(now) {
+		msg := fmt.Sprintf("Error: expiration date %s is not after transaction time %s", expires.Format(time.RFC3339), now.Format(time.RFC3339))
+		myLogger.Error(msg)
+		return errors.New(msg)
+	}
+
+	// an authorization can't outlive the accreditation it is granted under
+	if expires.After(accreditation.Expires) {
+		msg := fmt.Sprintf("Error: expiration date %s is after expiration date %s of accreditation %s", expires.Format(time.RFC3339), accreditation.Expires.Format(time.RFC3339), accreditation.ID)
+		myLogger.Error(msg)
+		return errors.New(msg)
+	}
+
+	return nil
+}
+
+// revoke signing authority
+func (t *AgrifoodChaincode) revoke_signing_authority(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+	// can only be called by Certification Body or auditor
+	myLogger.Info("Revoke sigining authority of party")
+
+	party, err := t.assertCallerRole(stub, roleCertificationBody, roleAuditor)
+	if err != nil {
+		return nil, err
+	}
+
+	// Check number of arguments
+	if len(args) != 3 {
+		msg := "Incorrect number of arguments. Expecting 3" // AccreditationID, authorized partyID, revokeTimestamp
+		myLogger.Error(msg)
+		return nil, errors.New(msg)
+	}
+
+	// get certificate
+	accreditation, err := t.getSigningAccreditation(stub,args[0])
+	if err != nil {
+		msg := fmt.Sprintf("Error determining accreditation: %s", err)
+		myLogger.Error(msg)
+		return nil, errors.New(msg)
+	}
+
+	// verify authorized party
+	authorizedParty, err := t.getParty(stub,args[1])
+	if err != nil {
+		msg := fmt.Sprintf("Error determining authorizedParty: %s", err)
+		myLogger.Error(msg)
+		return nil, errors.New(msg)
+	}
+
+	signingAuthorization, err := t.getSigningAuthorization(stub, accreditation.ID,authorizedParty.ID)
+	if err != nil {
+		msg := fmt.Sprintf("Error determining signingAuthorization: %s", err)
+		myLogger.Error(msg)
+		return nil, errors.New(msg)
+	}
+
+	// verify access rights, only the certification body that granted the authorization (or an auditor) can revoke it
+	if party.Role != roleAuditor && signingAuthorization.CertifyingParty != party.ID {
+		msg := fmt.Sprintf("Party %s is not the grantor of the signing authority of %s on %s, nor an auditor", party.ID, authorizedParty.ID, accreditation.ID)
+		myLogger.Error(msg)
+		return nil, errors.New(msg)
+	}
+
+	// update authorization entry
+	signingAuthorization.Revoked = true
+	signingAuthorization.RevocationTimestamp, err = time.Parse(time.RFC3339,args[2])
+	if err != nil {
+		msg := "Error parsing time"
+		myLogger.Error(msg)
+		return nil, errors.New(msg)
+	}
+
+	err = verifyTimestamp(stub, signingAuthorization.RevocationTimestamp)
+	if err != nil {
+		return nil, err
+	}
+
+	// save authorization entry
+	err = t.upsertSigningAuthorization(stub,signingAuthorization)
+	if err != nil {
+		msg := fmt.Sprintf("Error saving updated signingAuthorization: %s", err)
+		myLogger.Error(msg)
+		return nil, errors.New(msg)
+	}
+
+	msg := fmt.Sprintf("Successfully revoked signing authority of %s to %s",signingAuthorization.AccreditationID,signingAuthorization.AuthorizedParty)
+	myLogger.Info(msg)
+	return []byte(msg),nil
+}
+
+// create grapes asset
+func (t *AgrifoodChaincode) create_grapes(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+	// Check number of arguments
+	if len(args) < 3 || len(args) > 7 || len(args) == 6 {
+		msg := "Incorrect number of arguments. Expecting 3 to 5 or 7" // UUID, created, Amount, (optional) crop type, (optional) unit, (optional) latitude, longitude
+		myLogger.Error(msg)
+		return nil, errors.New(msg)
+	}
+
+	// grapes unless another crop type is given
+	cropType := defaultCropType
+	if len(args) >= 4 && args[3] != "" {
+		cropType = args[3]
+	}
+	produce_args := append([]string{cropType}, args[:3]...)
+	if len(args) >= 5 {
+		produce_args = append(produce_args, args[4:]...)
+	}
+
+	return t.create_produce(stub, produce_args)
+}
+
+// create produce asset of any crop type
+func (t *AgrifoodChaincode) create_produce(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+	// can only be called by a farm
+	myLogger.Info("Create produce asset")
+
+	party, err := t.assertCallerRole(stub, roleFarm)
+	if err != nil {
+		return nil, err
+	}
+
+	// Check number of arguments
+	if len(args) < 4 || len(args) > 7 || len(args) == 6 {
+		msg := "Incorrect number of arguments. Expecting 4, 5 or 7" // crop type, UUID, created, Amount, (optional) unit, (optional) latitude, longitude
+		myLogger.Error(msg)
+		return nil, errors.New(msg)
+	}
+
+	// the crop type is required here, only create_grapes and batches default to grapes
+	err = verifyID("crop type", args[0])
+	if err != nil {
+		return nil, err
+	}
+
+	entry := GrapesBatchEntry{CropType:args[0],UUID:args[1]}
+	if len(args) >= 5 {
+		entry.Unit = args[4]
+	}
+	entry.Created, err = time.Parse(time.RFC3339, args[2])
+	if err != nil {
+		msg := "Error parsing time"
+		myLogger.Error(msg)
+		return nil, errors.New(msg)
+	}
+
+	entry.Amount, err = strconv.Atoi(args[3])
+	if err != nil {
+		msg := fmt.Sprintf("Invalid amount %s, expecting a positive number", args[3])
+		myLogger.Error(msg)
+		return nil, errors.New(msg)
+	}
+
+	if len(args) == 7 {
+		entry.Latitude, entry.Longitude, err = parseLocation(args[5], args[6])
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	grapesUnit, err := t.newProduceUnit(stub, party, entry)
+	if err != nil {
+		return nil, err
+	}
+
+	// save grape unit
+	err = t.saveGrapeUnit(stub,grapesUnit,true)
+	if err != nil {
+		msg := "Error saving certificate"
+		myLogger.Error(msg)
+		return nil, errors.New(msg)
+	}
+
+	// notify listeners
+	err = setEvent(stub, "grapes_created", GrapesEvent{UUID:grapesUnit.UUID, Producer:grapesUnit.Producer, Timestamp:grapesUnit.Created})
+	if err != nil {
+		return nil, err
+	}
+
+	msg := fmt.Sprintf("Successfully added %s (%s), produced by %s",grapesUnit.CropType,grapesUnit.UUID,grapesUnit.Producer)
+	myLogger.Info(msg)
+	return []byte(msg), nil
+}
+
+// validate a produce unit to create and build it, owned by its producer; single and batch
+// creation both go through here so they apply the same rules
+func (t *AgrifoodChaincode) newProduceUnit(stub shim.ChaincodeStubInterface, party Party, entry GrapesBatchEntry) (ProduceUnit, error) {
+	grapesUnit := ProduceUnit{UUID:entry.UUID,Producer:party.ID,Created:entry.Created,Amount:entry.Amount,Unit:defaultUnit,CropType:defaultCropType,Status:statusActive}
+	if entry.CropType != "" {
+		grapesUnit.CropType = entry.CropType
+	}
+	if entry.Unit != "" {
+		grapesUnit.Unit = entry.Unit
+	}
+
+	err := verifyID("crop type", grapesUnit.CropType)
+	if err != nil {
+		return ProduceUnit{}, err
+	}
+
+	err = verifyUUID(grapesUnit.UUID)
+	if err != nil {
+		return ProduceUnit{}, err
+	}
+
+	if grapesUnit.Amount <= 0 {
+		msg := fmt.Sprintf("Invalid amount %d for grapes %s, expecting a positive number", grapesUnit.Amount, grapesUnit.UUID)
+		myLogger.Error(msg)
+		return ProduceUnit{}, errors.New(msg)
+	}
+
+	err = verifyTimestamp(stub, grapesUnit.Created)
+	if err != nil {
+		return ProduceUnit{}, err
+	}
+
+	// Add to ownership chain
+	ownershipEntry := OwnershipEntry{PartyID:party.ID,Timestamp:grapesUnit.Created}
+	if entry.Latitude != nil || entry.Longitude != nil {
+		if entry.Latitude == nil || entry.Longitude == nil {
+			msg := fmt.Sprintf("Error: location of grapes %s needs both latitude and longitude", grapesUnit.UUID)
+			myLogger.Error(msg)
+			return ProduceUnit{}, errors.New(msg)
+		}
+		err = verifyLocation(*entry.Latitude, *entry.Longitude)
+		if err != nil {
+			return ProduceUnit{}, err
+		}
+		ownershipEntry.Latitude, ownershipEntry.Longitude = entry.Latitude, entry.Longitude
+	}
+	grapesUnit.Ownership = append(grapesUnit.Ownership, ownershipEntry)
+
+	return grapesUnit, nil
+}
+
+// create multiple grapes assets, either all or none are created
+func (t *AgrifoodChaincode) create_grapes_batch(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+	// can only be called by a farm
+	myLogger.Info("Create batch of grapes assets")
+
+	party, err := t.assertCallerRole(stub, roleFarm)
+	if err != nil {
+		return nil, err
+	}
+
+	// Check number of arguments
+	if len(args) != 1 {
+		msg := "Incorrect number of arguments. Expecting 1" // grape units (JSON array of UUID, Created, Amount, (optional) Unit, CropType, Latitude, Longitude)
+		myLogger.Error(msg)
+		return nil, errors.New(msg)
+	}
+
+	var entries []GrapesBatchEntry
+	err = json.Unmarshal([]byte(args[0]), &entries)
+	if err != nil {
+		msg := fmt.Sprintf("Error parsing grape units: %s", err)
+		myLogger.Error(msg)
+		return nil, errors.New(msg)
+	}
+
+	// validate every unit before saving any
+	seen := make(map[string]bool)
+	var grapes []ProduceUnit
+	for _, entry := range entries {
+		grapesUnit, err := t.newProduceUnit(stub, party, entry)
+		if err != nil {
+			return nil, err
+		}
+
+		if seen[grapesUnit.UUID] {
+			msg := fmt.Sprintf("Error: UUID %s is listed twice in batch", grapesUnit.UUID)
+			myLogger.Error(msg)
+			return nil, errors.New(msg)
+		}
+		seen[grapesUnit.UUID] = true
+
+		_, err = t.getGrapesUnit(stub, grapesUnit.UUID)
+		if err == nil {
+			msg := fmt.Sprintf("Error: GrapeUnits UUID %s needs to be unique", grapesUnit.UUID)
+			myLogger.Error(msg)
+			return nil, errors.New(msg)
+		}
+
+		grapes = append(grapes, grapesUnit)
+	}
+
+	// a transaction carries a single event, so the batch reports all its units in one
+	var events []GrapesEvent
+	for _, grapesUnit := range grapes {
+		err = t.saveGrapeUnit(stub,grapesUnit,true)
+		if err != nil {
+			msg := fmt.Sprintf("Error saving grapes %s: %s", grapesUnit.UUID, err)
+			myLogger.Error(msg)
+			return nil, errors.New(msg)
+		}
+		events = append(events, GrapesEvent{UUID:grapesUnit.UUID, Producer:grapesUnit.Producer, Timestamp:grapesUnit.Created})
+	}
+
+	// notify listeners
+	if len(events) > 0 {
+		err = setEvent(stub, "grapes_created", events)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	msg := fmt.Sprintf("Successfully added %d grape units, produced by %s",len(entries),party.ID)
+	myLogger.Info(msg)
+	return []byte(msg), nil
+}
+
+// certify grapes
+func (t *AgrifoodChaincode) certify_grapes(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+	// can only be called by farm
+	myLogger.Info("Certify grapes asset")
+
+	party, err := t.assertCallerRole(stub, roleFarm)
+	if err != nil {
+		return nil, err
+	}
+
+	// Check number of arguments
+	if len(args) != 3 {
+		msg := "Incorrect number of arguments. Expecting 3" // UUID, accreditationID, timestamp
+		myLogger.Error(msg)
+		return nil, errors.New(msg)
+	}
+
+	// get grapes unit
+	grapesUnit, err := t.getGrapesUnit(stub,args[0])
+	if err != nil {
+		msg := fmt.Sprintf("Error determining grapesUnit: %s", err)
+		myLogger.Error(msg)
+		return nil, errors.New(msg)
+	}
+
+	signAuth, accreditation, err := t.checkCertification(stub, party, grapesUnit, args[1])
+	if err != nil {
+		return nil, err
+	}
+
+	// accreditation is valid
+
+	// actually attach accreditation signature to grapes
+	signature := AccreditationSignature{Issuer:signAuth.AuthorizedParty, AccreditationID:accreditation.ID,Revoked:false}
+	signature.Issued, err = time.Parse(time.RFC3339, args[2])
+	if err != nil {
+		msg := "Error parsing time"
+		myLogger.Error(msg)
+		return nil, errors.New(msg)
+	}
+
+	err = verifyTimestamp(stub, signature.Issued)
+	if err != nil {
+		return nil, err
+	}
+
+	// append signature to grapes unit
+	grapesUnit.AccreditationSignatures = append(grapesUnit.AccreditationSignatures, signature)
+
+	// save to world-state
+	err = t.saveGrapeUnit(stub,grapesUnit,false)
+	if err != nil {
+		msg := "Error saving grapeUnit"
+		myLogger.Error(msg)
+		return nil, errors.New(msg)
+	}
+
+	// track amount certified under accreditation
+	accreditation.CertifiedQuantity += grapesUnit.Amount
+	err = t.saveSigningAccreditation(stub, accreditation, false)
+	if err != nil {
+		msg := "Error saving updated accreditation"
+		myLogger.Error(msg)
+		return nil, errors.New(msg)
+	}
+
+	// notify listeners
+	err = setEvent(stub, "grapes_certified", GrapesEvent{UUID:grapesUnit.UUID, Producer:grapesUnit.Producer, Timestamp:signature.Issued})
+	if err != nil {
+		return nil, err
+	}
+
+	msg := fmt.Sprintf("Successfully signed signature for grapes: %s",grapesUnit.UUID)
+	myLogger.Info(msg)
+	return []byte(msg),nil
+}
+
+// check whether the caller could certify grapes with an accreditation
+func (t *AgrifoodChaincode) can_certify(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+	// Check number of arguments
+	if len(args) != 2 {
+		msg := "Incorrect number of arguments. Expecting 2" // UUID, accreditationID
+		myLogger.Error(msg)
+		return nil, errors.New(msg)
+	}
+
+	eligibility := CertifyEligibility{Eligible:true}
+
+	// same checks as certify_grapes, failures are reported instead of returned
+	party, err := t.assertCallerRole(stub, roleFarm)
+	if err == nil {
+		var grapesUnit ProduceUnit
+		grapesUnit, err = t.getGrapesUnit(stub,args[0])
+		if err == nil {
+			_, _, err = t.checkCertification(stub, party, grapesUnit, args[1])
+		}
+	}
+	if err != nil {
+		eligibility = CertifyEligibility{Eligible:false,Reason:err.Error()}
+	}
+
+	eligibility_b, err := json.Marshal(eligibility)
+	if err != nil {
+		msg := fmt.Sprintf("Error marshalling eligibility: %s", err)
+		myLogger.Error(msg)
+		return nil, errors.New(msg)
+	}
+
+	return eligibility_b, nil
+}
+
+// run the checks certifying grapes with an accreditation has to pass, without changing state
+func (t *AgrifoodChaincode) checkCertification(stub shim.ChaincodeStubInterface, party Party, grapesUnit ProduceUnit, accreditationID string) (SigningAuthorization, SigningAccreditation, error) {
+	// verify if caller is producer of grapes
+	if grapesUnit.Producer != party.ID {
+		msg := fmt.Sprintf("Caller is not producer of grapes: %s", grapesUnit.UUID)
+		myLogger.Error(msg)
+		return SigningAuthorization{}, SigningAccreditation{}, errors.New(msg)
+	}
+
+	// retired grapes can no longer be certified
+	if isRetired(grapesUnit) {
+		msg := fmt.Sprintf("Grapes %s are %s", grapesUnit.UUID, grapesUnit.Status)
+		myLogger.Error(msg)
+		return SigningAuthorization{}, SigningAccreditation{}, errors.New(msg)
+	}
+
+	// verify sigining authority of farm
+	signAuth, err := t.getSigningAuthorization(stub,accreditationID,party.ID)
+	if err != nil {
+		msg := fmt.Sprintf("Error determining signing authority: %s", err)
+		myLogger.Error(msg)
+		return SigningAuthorization{}, SigningAccreditation{}, errors.New(msg)
+	}
+
+	// validate sigining authority
+	if signAuth.Revoked {
+		msg := fmt.Sprintf("No signing authority for %s on %s",signAuth.AccreditationID,party.ID)
+		myLogger.Error(msg)
+		return SigningAuthorization{}, SigningAccreditation{}, errors.New(msg)
+	}
+
+	// check expiration date
+	now, err := txTime(stub)
+	if err != nil {
+		msg := fmt.Sprintf("Error determining transaction time: %s", err)
+		myLogger.Error(msg)
+		return SigningAuthorization{}, SigningAccreditation{}, errors.New(msg)
+	}
+
+	if isExpired(signAuth.Expires, now) {
+		msg := fmt.Sprintf("Signing authority for %s by %s has expired",signAuth.AccreditationID,party.ID)
+		myLogger.Error(msg)
+		return SigningAuthorization{}, SigningAccreditation{}, errors.New(msg)
+	}
+
+	// get accreditation
+	accreditation, err := t.getSigningAccreditation(stub,signAuth.AccreditationID)
+	if err != nil {
+		msg := fmt.Sprintf("Error determining accreditation: %s", err)
+		myLogger.Error(msg)
+		return SigningAuthorization{}, SigningAccreditation{}, errors.New(msg)
+	}
+
+	// see if accreditation is valid
+	if accreditation.Revoked {
+		msg := fmt.Sprintf("Invalid signing accreditation: %s", accreditation.ID)
+		myLogger.Error(msg)
+		return SigningAuthorization{}, SigningAccreditation{}, errors.New(msg)
+	}
+
+	// check expiration date
+	if isExpired(accreditation.Expires, now) {
+		msg := fmt.Sprintf("Accreditation %s has expired",signAuth.AccreditationID)
+		myLogger.Error(msg)
+		return SigningAuthorization{}, SigningAccreditation{}, errors.New(msg)
+	}
+
+	// check crop type is within scope of accreditation
+	if !inAccreditationScope(accreditation, grapesUnit.CropType) {
+		msg := fmt.Sprintf("Crop type %s is not within scope of accreditation %s",grapesUnit.CropType,accreditation.ID)
+		myLogger.Error(msg)
+		return SigningAuthorization{}, SigningAccreditation{}, errors.New(msg)
+	}
+
+	// an accreditation signs a grape unit once, until the signature is revoked
+	for _, signature := range grapesUnit.AccreditationSignatures {
+		if signature.AccreditationID == accreditation.ID && !signature.Revoked {
+			msg := fmt.Sprintf("Grapes %s are already certified under accreditation %s",grapesUnit.UUID,accreditation.ID)
+			myLogger.Error(msg)
+			return SigningAuthorization{}, SigningAccreditation{}, errors.New(msg)
+		}
+	}
+
+	// check amount certified under accreditation stays within maximum
+	if accreditation.MaxQuantity > 0 && accreditation.CertifiedQuantity + grapesUnit.Amount > accreditation.MaxQuantity {
+		msg := fmt.Sprintf("Certifying %d exceeds maximum quantity of accreditation %s (%d of %d certified)",grapesUnit.Amount,accreditation.ID,accreditation.CertifiedQuantity,accreditation.MaxQuantity)
+		myLogger.Error(msg)
+		return SigningAuthorization{}, SigningAccreditation{}, errors.New(msg)
+	}
+
+	return signAuth, accreditation, nil
+}
+
+// revoke signature on grape units
+func (t *AgrifoodChaincode) revoke_signature(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+	// can only be called by Auditors and Farms that issued the signature
+	myLogger.Info("Revoke signature on grapes unit")
+
+	party, err := t.assertCallerRole(stub, roleFarm, roleAuditor)
+	if err != nil {
+		return nil, err
+	}
+
+	// Check number of arguments
+	if len(args) < 3 || len(args) > 4 {
+		msg := "Incorrect number of arguments. Expecting 3 or 4" // UUID, accreditationID, revokeTimestamp, (optional) issuedTimestamp
+		myLogger.Error(msg)
+		return nil, errors.New(msg)
+	}
+
+	// optional issue time, to revoke a single signature of the accreditation
+	var issued time.Time
+	if len(args) == 4 {
+		issued, err = time.Parse(time.RFC3339,args[3])
+		if err != nil {
+			msg := "Error parsing time (issued)"
+			myLogger.Error(msg)
+			return nil, errors.New(msg)
+		}
+	}
+
+	// get grape unit from storage
+	grapeUnit, err := t.getGrapesUnit(stub,args[0])
+	if err != nil {
+		msg := fmt.Sprintf("Error determining grapeUnit: %s", err)
+		myLogger.Error(msg)
+		return nil, errors.New(msg)
+	}
+
+	// if caller is farm, check if it's the producer of the grapes
+	if party.Role == roleFarm && grapeUnit.Producer != party.ID {
+		msg := fmt.Sprintf("Farm is not producer of targeted grapes: %s", grapeUnit.UUID)
+		myLogger.Error(msg)
+		return nil, errors.New(msg)
+	}
+
+	// loop over signatures
+	matched := false
+	uncertified := false
+	for i, signature := range grapeUnit.AccreditationSignatures {
+		// find correct signature, all of the accreditation if no issue time is given
+		if signature.AccreditationID == args[1] && (issued.IsZero() || signature.Issued.Equal(issued)) {
+			matched = true
+			if !signature.Revoked {
+				uncertified = true
+			}
+
+			// revoke signature
+			signature.Revoked = true
+			signature.RevocationTimestamp, err = time.Parse(time.RFC3339,args[2])
+			if err != nil {
+				msg := "Error parsing time"
+				myLogger.Error(msg)
+				return nil, errors.New(msg)
+			}
+
+			err = verifyTimestamp(stub, signature.RevocationTimestamp)
+			if err != nil {
+				return nil, err
+			}
+
+			// update signature
+			grapeUnit.AccreditationSignatures[i] = signature
+		}
+	}
+
+	if !matched {
+		msg := fmt.Sprintf("Error: no matching signature found on grape unit %s", grapeUnit.UUID)
+		myLogger.Error(msg)
+		return nil, errors.New(msg)
+	}
+
+	// save to world-state
+	err = t.saveGrapeUnit(stub,grapeUnit,false)
+	if err != nil {
+		msg := fmt.Sprintf("Error saving updated grapeUnit: %s", err)
+		myLogger.Error(msg)
+		return nil, errors.New(msg)
+	}
+
+	// amount is no longer certified under accreditation
+	if uncertified {
+		accreditation, err := t.getSigningAccreditation(stub,args[1])
+		if err != nil {
+			msg := fmt.Sprintf("Error determining accreditation: %s", err)
+			myLogger.Error(msg)
+			return nil, errors.New(msg)
+		}
+
+		accreditation.CertifiedQuantity -= grapeUnit.Amount
+		if accreditation.CertifiedQuantity < 0 {
+			accreditation.CertifiedQuantity = 0
+		}
+
+		err = t.saveSigningAccreditation(stub, accreditation, false)
+		if err != nil {
+			msg := "Error saving updated accreditation"
+			myLogger.Error(msg)
+			return nil, errors.New(msg)
+		}
+	}
+
+	// done
+	msg := fmt.Sprintf("Successfully revoked signature of %s for grapes: %s",args[1],grapeUnit.UUID)
+	myLogger.Info(msg)
+	return []byte(msg),nil
+}
+
+// transfer grapes to new owner (trader)
+func (t *AgrifoodChaincode) transfer_grapes(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+	// can only be called by farms, traders and wineries
+	myLogger.Info("Transfer ownership of grapes")
+
+	party, err := t.assertCallerRole(stub, roleFarm, roleTrader, roleWinery)
+	if err != nil {
+		return nil, err
+	}
+
+	// Check number of arguments
+	if len(args) != 3 && len(args) != 5 {
+		msg := "Incorrect number of arguments. Expecting 3 or 5" // UUID, newParty, timestamp, (optional) latitude, longitude
+		myLogger.Error(msg)
+		return nil, errors.New(msg)
+	}
+
+	// get grapesUnit
+	grapesUnit, err := t.getGrapesUnit(stub,args[0])
+	if err != nil {
+		msg := fmt.Sprintf("Error determining grapesUnit: %s", err)
+		myLogger.Error(msg)
+		return nil, errors.New(msg)
+	}
+
+	// verify caller is current (sole) owner of grapes
+	if !isSoleOwner(grapesUnit, party.ID) {
+		msg := fmt.Sprintf("Caller is not the current owner of the grapes: %s", grapesUnit.UUID)
+		myLogger.Error(msg)
+		return nil, errors.New(msg)
+	}
+
+	// retired grapes can no longer be transferred
+	if isRetired(grapesUnit) {
+		msg := fmt.Sprintf("Grapes %s are %s", grapesUnit.UUID, grapesUnit.Status)
+		myLogger.Error(msg)
+		return nil, errors.New(msg)
+	}
+
+	// get newParty
+	newParty, err := t.getTransferRecipient(stub, args[1])
+	if err != nil {
+		return nil, err
+	}
+
+	if newParty.ID == party.ID {
+		msg := "Error: cannot transfer grapes to the current owner"
+		myLogger.Error(msg)
+		return nil, errors.New(msg)
+	}
+
+	// grapes need to comply with the signature policy before they leave the farm
+	err = t.verifyFirstTransferCompliance(stub, grapesUnit)
+	if err != nil {
+		return nil, err
+	}
+
+	// create new provenance entry
+	ownershipEntry := OwnershipEntry{PartyID:newParty.ID,TransferredBy:party.ID}
+	ownershipEntry.Timestamp, err = time.Parse(time.RFC3339,args[2])
+	if err != nil {
+		msg := fmt.Sprintf("Error parsing timestamp: %s", err)
+		myLogger.Error(msg)
+		return nil, errors.New(msg)
+	}
+	if len(args) == 5 {
+		ownershipEntry.Latitude, ownershipEntry.Longitude, err = parseLocation(args[3], args[4])
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	err = verifyTimestamp(stub, ownershipEntry.Timestamp)
+	if err != nil {
+		return nil, err
+	}
+
+	// verify ownership entry timestamp is strictly after last provenance entry timestamp,
+	// equal timestamps would leave the order of the entries ambiguous
+	if !ownershipEntry.Timestamp.After(grapesUnit.Ownership[len(grapesUnit.Ownership)-1].Timestamp) {
+		msg := "new ownership timestamp needs to be after latest ownership entry timestamp"
+		myLogger.Error(msg)
+		return nil, errors.New(msg)
+	}
+
+	// append provenance entry
+	grapesUnit.Ownership = append(grapesUnit.Ownership, ownershipEntry)
+
+	// save to world-state
+	err = t.saveGrapeUnit(stub,grapesUnit,false)
+	if err != nil {
+		msg := fmt.Sprintf("Error saving updated grapeUnit: %s", err)
+		myLogger.Error(msg)
+		return nil, errors.New(msg)
+	}
+
+	// done
+	msg := fmt.Sprintf("Successfully transferred grapes %s from %s to: %s",grapesUnit.UUID,party.ID, ownershipEntry.PartyID)
+	myLogger.Info(msg)
+	return []byte(msg),nil
+}
+
+// transfer multiple grape units to the same new owner, either all or none are transferred
+func (t *AgrifoodChaincode) transfer_grapes_batch(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+	// can only be called by farms, traders and wineries
+	myLogger.Info("Transfer ownership of batch of grapes")
+
+	party, err := t.assertCallerRole(stub, roleFarm, roleTrader, roleWinery)
+	if err != nil {
+		return nil, err
+	}
+
+	// Check number of arguments
+	if len(args) != 3 {
+		msg := "Incorrect number of arguments. Expecting 3" // UUIDs (JSON array), newParty, timestamp
+		myLogger.Error(msg)
+		return nil, errors.New(msg)
+	}
+
+	var uuids []string
+	err = json.Unmarshal([]byte(args[0]), &uuids)
+	if err != nil {
+		msg := fmt.Sprintf("Error parsing grape units: %s", err)
+		myLogger.Error(msg)
+		return nil, errors.New(msg)
+	}
+
+	if len(uuids) == 0 {
+		msg := "Error: no grape units to transfer"
+		myLogger.Error(msg)
+		return nil, errors.New(msg)
+	}
+
+	// get newParty
+	newParty, err := t.getTransferRecipient(stub, args[1])
+	if err != nil {
+		return nil, err
+	}
+
+	if newParty.ID == party.ID {
+		msg := "Error: cannot transfer grapes to the current owner"
+		myLogger.Error(msg)
+		return nil, errors.New(msg)
+	}
+
+	timestamp, err := time.Parse(time.RFC3339,args[2])
+	if err != nil {
+		msg := fmt.Sprintf("Error parsing timestamp: %s", err)
+		myLogger.Error(msg)
+		return nil, errors.New(msg)
+	}
+
+	err = verifyTimestamp(stub, timestamp)
+	if err != nil {
+		return nil, err
+	}
+
+	// validate every unit before saving any
+	seen := make(map[string]bool)
+	grapes := []ProduceUnit{}
+	for _, uuid := range uuids {
+		if seen[uuid] {
+			msg := fmt.Sprintf("Error: UUID %s is listed twice in batch", uuid)
+			myLogger.Error(msg)
+			return nil, errors.New(msg)
+		}
+		seen[uuid] = true
+
+		grapesUnit, err := t.getGrapesUnit(stub,uuid)
+		if err != nil {
+			msg := fmt.Sprintf("Error determining grapesUnit: %s", err)
+			myLogger.Error(msg)
+			return nil, errors.New(msg)
+		}
+
+		// verify caller is current (sole) owner of grapes
+		if !isSoleOwner(grapesUnit, party.ID) {
+			msg := fmt.Sprintf("Caller is not the current owner of the grapes: %s", grapesUnit.UUID)
+			myLogger.Error(msg)
+			return nil, errors.New(msg)
+		}
+
+		// retired grapes can no longer be transferred
+		if isRetired(grapesUnit) {
+			msg := fmt.Sprintf("Grapes %s are %s", grapesUnit.UUID, grapesUnit.Status)
+			myLogger.Error(msg)
+			return nil, errors.New(msg)
+		}
+
+		// grapes need to comply with the signature policy before they leave the farm
+		err = t.verifyFirstTransferCompliance(stub, grapesUnit)
+		if err != nil {
+			return nil, err
+		}
+
+		if !timestamp.After(grapesUnit.Ownership[len(grapesUnit.Ownership)-1].Timestamp) {
+			msg := fmt.Sprintf("new ownership timestamp needs to be after latest ownership entry timestamp of grapes %s", grapesUnit.UUID)
+			myLogger.Error(msg)
+			return nil, errors.New(msg)
+		}
+
+		grapes = append(grapes, grapesUnit)
+	}
+
+	for _, grapesUnit := range grapes {
+		grapesUnit.Ownership = append(grapesUnit.Ownership, OwnershipEntry{PartyID:newParty.ID,TransferredBy:party.ID,Timestamp:timestamp})
+
+		err = t.saveGrapeUnit(stub,grapesUnit,false)
+		if err != nil {
+			msg := fmt.Sprintf("Error saving updated grapeUnit %s: %s", grapesUnit.UUID, err)
+			myLogger.Error(msg)
+			return nil, errors.New(msg)
+		}
+	}
+
+	// done
+	msg := fmt.Sprintf("Successfully transferred %d grape units from %s to: %s",len(grapes),party.ID,newParty.ID)
+	myLogger.Info(msg)
+	return []byte(msg),nil
+}
+
+// transfer a percentage of the ownership of grapes to another party
+func (t *AgrifoodChaincode) transfer_share(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+	// can only be called by farms, traders and wineries owning a share
+	myLogger.Info("Transfer ownership share of grapes")
+
+	party, err := t.assertCallerRole(stub, roleFarm, roleTrader, roleWinery)
+	if err != nil {
+		return nil, err
+	}
+
+	// Check number of arguments
+	if len(args) != 4 {
+		msg := "Incorrect number of arguments. Expecting 4" // UUID, newParty, percentage, timestamp
+		myLogger.Error(msg)
+		return nil, errors.New(msg)
+	}
+
+	// get grapesUnit
+	grapesUnit, err := t.getGrapesUnit(stub,args[0])
+	if err != nil {
+		msg := fmt.Sprintf("Error determining grapesUnit: %s", err)
+		myLogger.Error(msg)
+		return nil, errors.New(msg)
+	}
+
+	// retired grapes can no longer be transferred
+	if isRetired(grapesUnit) {
+		msg := fmt.Sprintf("Grapes %s are %s", grapesUnit.UUID, grapesUnit.Status)
+		myLogger.Error(msg)
+		return nil, errors.New(msg)
+	}
+
+	// find share of caller
+	shares := getOwnershipShares(grapesUnit)
+	callerShare := -1
+	for i, share := range shares {
+		if share.PartyID == party.ID {
+			callerShare = i
+		}
+	}
+
+	if callerShare < 0 {
+		msg := fmt.Sprintf("Caller is not an owner of the grapes: %s", grapesUnit.UUID)
+		myLogger.Error(msg)
+		return nil, errors.New(msg)
+	}
+
+	percentage, err := strconv.Atoi(args[2])
+	if err != nil || percentage <= 0 || percentage > shares[callerShare].Percentage {
+		msg := fmt.Sprintf("Invalid percentage %s, caller owns %d%%", args[2], shares[callerShare].Percentage)
+		myLogger.Error(msg)
+		return nil, errors.New(msg)
+	}
+
+	// get newParty
+	newParty, err := t.getTransferRecipient(stub, args[1])
+	if err != nil {
+		return nil, err
+	}
+
+	if newParty.ID == party.ID {
+		msg := "Error: cannot transfer a share to the current owner"
+		myLogger.Error(msg)
+		return nil, errors.New(msg)
+	}
+
+	// grapes need to comply with the signature policy before they leave the farm
+	err = t.verifyFirstTransferCompliance(stub, grapesUnit)
+	if err != nil {
+		return nil, err
+	}
+
+	timestamp, err := time.Parse(time.RFC3339,args[3])
+	if err != nil {
+		msg := fmt.Sprintf("Error parsing timestamp: %s", err)
+		myLogger.Error(msg)
+		return nil, errors.New(msg)
+	}
+
+	err = verifyTimestamp(stub, timestamp)
+	if err != nil {
+		return nil, err
+	}
+
+	// verify timestamp is strictly after last provenance entry timestamp
+	if !timestamp.After(grapesUnit.Ownership[len(grapesUnit.Ownership)-1].Timestamp) {
+		msg := "new ownership timestamp needs to be after latest ownership entry timestamp"
+		myLogger.Error(msg)
+		return nil, errors.New(msg)
+	}
+
+	// move percentage from caller to new party
+	shares[callerShare].Percentage -= percentage
+	received := false
+	for i, share := range shares {
+		if share.PartyID == newParty.ID {
+			shares[i].Percentage += percentage
+			shares[i].Acquired = timestamp
+			received = true
+		}
+	}
+	if !received {
+		shares = append(shares, OwnershipShare{PartyID:newParty.ID, Percentage:percentage, Acquired:timestamp})
+	}
+
+	// drop parties without a share
+	var owners []OwnershipShare
+	for _, share := range shares {
+		if share.Percentage > 0 {
+			owners = append(owners, share)
+		}
+	}
+
+	err = validateOwnershipShares(owners)
+	if err != nil {
+		myLogger.Error(err.Error())
+		return nil, err
+	}
+
+	if len(owners) == 1 {
+		// new party became sole owner, continue ownership trail
+		grapesUnit.Ownership = append(grapesUnit.Ownership, OwnershipEntry{PartyID:owners[0].PartyID, Timestamp:timestamp, TransferredBy:party.ID})
+		grapesUnit.Owners = nil
+	} else {
+		grapesUnit.Owners = owners
+	}
+
+	// save to world-state
+	err = t.saveGrapeUnit(stub,grapesUnit,false)
+	if err != nil {
+		msg := fmt.Sprintf("Error saving updated grapeUnit: %s", err)
+		myLogger.Error(msg)
+		return nil, errors.New(msg)
+	}
+
+	msg := fmt.Sprintf("Successfully transferred %d%% of grapes %s from %s to: %s", percentage, grapesUnit.UUID, party.ID, newParty.ID)
+	myLogger.Info(msg)
+	return []byte(msg),nil
+}
+
+// flag a transfer of grapes as fraudulent, optionally restoring ownership to the prior owner
+func (t *AgrifoodChaincode) flag_transfer_fraudulent(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+	// can only be called by auditors
+	myLogger.Info("Flag transfer of grapes as fraudulent")
+
+	party, err := t.assertCallerRole(stub, roleAuditor)
+	if err != nil {
+		return nil, err
+	}
+
+	// Check number of arguments
+	if len(args) != 3 && len(args) != 4 {
+		msg := "Incorrect number of arguments. Expecting 3 or 4" // UUID, ownership entry index, reason, (optional) restore timestamp
+		myLogger.Error(msg)
+		return nil, errors.New(msg)
+	}
+
+	// get grapesUnit
+	grapesUnit, err := t.getGrapesUnit(stub,args[0])
+	if err != nil {
+		msg := fmt.Sprintf("Error determining grapesUnit: %s", err)
+		myLogger.Error(msg)
+		return nil, errors.New(msg)
+	}
+
+	// first entry is the creation, not a transfer
+	index, err := strconv.Atoi(args[1])
+	if err != nil || index < 1 || index >= len(grapesUnit.Ownership) {
+		msg := fmt.Sprintf("Invalid ownership entry index: %s", args[1])
+		myLogger.Error(msg)
+		return nil, errors.New(msg)
+	}
+
+	if grapesUnit.Ownership[index].Fraudulent {
+		msg := fmt.Sprintf("Transfer %d of grapes %s is already flagged as fraudulent", index, grapesUnit.UUID)
+		myLogger.Error(msg)
+		return nil, errors.New(msg)
+	}
+
+	grapesUnit.Ownership[index].Fraudulent = true
+	grapesUnit.Ownership[index].FraudReason = args[2]
+
+	// restore ownership to prior owner with a compensating entry
+	if len(args) == 4 {
+		compensatingEntry := OwnershipEntry{PartyID:grapesUnit.Ownership[index-1].PartyID, Compensating:true, TransferredBy:party.ID}
+		compensatingEntry.Timestamp, err = time.Parse(time.RFC3339,args[3])
+		if err != nil {
+			msg := fmt.Sprintf("Error parsing timestamp: %s", err)
+			myLogger.Error(msg)
+			return nil, errors.New(msg)
+		}
+
+		err = verifyTimestamp(stub, compensatingEntry.Timestamp)
+		if err != nil {
+			return nil, err
+		}
+
+		// verify ownership entry timestamp is strictly after last provenance entry timestamp
+		if !compensatingEntry.Timestamp.After(grapesUnit.Ownership[len(grapesUnit.Ownership)-1].Timestamp) {
+			msg := "new ownership timestamp needs to be after latest ownership entry timestamp"
+			myLogger.Error(msg)
+			return nil, errors.New(msg)
+		}
+
+		grapesUnit.Ownership = append(grapesUnit.Ownership, compensatingEntry)
+		grapesUnit.Owners = nil
+	}
+
+	// save to world-state
+	err = t.saveGrapeUnit(stub,grapesUnit,false)
+	if err != nil {
+		msg := fmt.Sprintf("Error saving updated grapeUnit: %s", err)
+		myLogger.Error(msg)
+		return nil, errors.New(msg)
+	}
+
+	msg := fmt.Sprintf("Auditor %s flagged transfer %d of grapes %s as fraudulent", party.ID, index, grapesUnit.UUID)
+	myLogger.Info(msg)
+	return []byte(msg),nil
+}
+
+// flag grapes as recalled so their holders are warned
+func (t *AgrifoodChaincode) recall_grapes(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+	// can only be called by the producing farm and auditors
+	myLogger.Info("Recall grapes")
+
+	party, err := t.assertCallerRole(stub, roleFarm, roleAuditor)
+	if err != nil {
+		return nil, err
+	}
+
+	// Check number of arguments
+	if len(args) != 3 {
+		msg := "Incorrect number of arguments. Expecting 3" // UUID, reason, timestamp
+		myLogger.Error(msg)
+		return nil, errors.New(msg)
+	}
+
+	// get grapesUnit
+	grapesUnit, err := t.getGrapesUnit(stub,args[0])
+	if err != nil {
+		msg := fmt.Sprintf("Error determining grapesUnit: %s", err)
+		myLogger.Error(msg)
+		return nil, errors.New(msg)
+	}
+
+	// farms can only recall their own grapes
+	if party.Role == roleFarm && grapesUnit.Producer != party.ID {
+		msg := fmt.Sprintf("Caller is not producer of grapes: %s", grapesUnit.UUID)
+		myLogger.Error(msg)
+		return nil, errors.New(msg)
+	}
+
+	if grapesUnit.Recalled {
+		msg := fmt.Sprintf("Grapes %s are already recalled: %s", grapesUnit.UUID, grapesUnit.RecallReason)
+		myLogger.Error(msg)
+		return nil, errors.New(msg)
+	}
+
+	if strings.TrimSpace(args[1]) == "" {
+		msg := "Error: recall reason can't be empty"
+		myLogger.Error(msg)
+		return nil, errors.New(msg)
+	}
+
+	timestamp, err := time.Parse(time.RFC3339,args[2])
+	if err != nil {
+		msg := fmt.Sprintf("Error parsing timestamp: %s", err)
+		myLogger.Error(msg)
+		return nil, errors.New(msg)
+	}
+
+	err = verifyTimestamp(stub, timestamp)
+	if err != nil {
+		return nil, err
+	}
+
+	grapesUnit.Recalled = true
+	grapesUnit.RecallReason = args[1]
+	grapesUnit.RecalledBy = party.ID
+	grapesUnit.RecallTimestamp = timestamp
+	grapesUnit.RecallClearedBy = ""
+	grapesUnit.RecallCleared = time.Time{}
+
+	// save to world-state
+	err = t.saveGrapeUnit(stub,grapesUnit,false)
+	if err != nil {
+		msg := fmt.Sprintf("Error saving updated grapeUnit: %s", err)
+		myLogger.Error(msg)
+		return nil, errors.New(msg)
+	}
+
+	// notify listeners
+	err = setEvent(stub, "grapes_recalled", GrapesRecalledEvent{UUID:grapesUnit.UUID, Producer:grapesUnit.Producer, RecalledBy:party.ID, Reason:grapesUnit.RecallReason, Timestamp:timestamp})
+	if err != nil {
+		return nil, err
+	}
+
+	msg := fmt.Sprintf("%s recalled grapes %s: %s", party.ID, grapesUnit.UUID, grapesUnit.RecallReason)
+	myLogger.Info(msg)
+	return []byte(msg),nil
+}
+
+// lift the recall of grapes
+func (t *AgrifoodChaincode) clear_recall(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+	// can only be called by auditors
+	myLogger.Info("Clear recall of grapes")
+
+	party, err := t.assertCallerRole(stub, roleAuditor)
+	if err != nil {
+		return nil, err
+	}
+
+	// Check number of arguments
+	if len(args) != 2 {
+		msg := "Incorrect number of arguments. Expecting 2" // UUID, timestamp
+		myLogger.Error(msg)
+		return nil, errors.New(msg)
+	}
+
+	// get grapesUnit
+	grapesUnit, err := t.getGrapesUnit(stub,args[0])
+	if err != nil {
+		msg := fmt.Sprintf("Error determining grapesUnit: %s", err)
+		myLogger.Error(msg)
+		return nil, errors.New(msg)
+	}
+
+	if !grapesUnit.Recalled {
+		msg := fmt.Sprintf("Grapes %s are not recalled", grapesUnit.UUID)
+		myLogger.Error(msg)
+		return nil, errors.New(msg)
+	}
+
+	timestamp, err := time.Parse(time.RFC3339,args[1])
+	if err != nil {
+		msg := fmt.Sprintf("Error parsing timestamp: %s", err)
+		myLogger.Error(msg)
+		return nil, errors.New(msg)
+	}
+
+	err = verifyTimestamp(stub, timestamp)
+	if err != nil {
+		return nil, err
+	}
+
+	if !timestamp.After(grapesUnit.RecallTimestamp) {
+		msg := fmt.Sprintf("Error: timestamp %s is not after recall at %s", args[1], grapesUnit.RecallTimestamp.Format(time.RFC3339))
+		myLogger.Error(msg)
+		return nil, errors.New(msg)
+	}
+
+	// reason and recalling party are kept as a record of the lifted recall
+	grapesUnit.Recalled = false
+	grapesUnit.RecallClearedBy = party.ID
+	grapesUnit.RecallCleared = timestamp
+
+	// save to world-state
+	err = t.saveGrapeUnit(stub,grapesUnit,false)
+	if err != nil {
+		msg := fmt.Sprintf("Error saving updated grapeUnit: %s", err)
+		myLogger.Error(msg)
+		return nil, errors.New(msg)
+	}
+
+	// notify listeners
+	err = setEvent(stub, "recall_cleared", RecallClearedEvent{UUID:grapesUnit.UUID, Producer:grapesUnit.Producer, ClearedBy:party.ID, Timestamp:timestamp})
+	if err != nil {
+		return nil, err
+	}
+
+	msg := fmt.Sprintf("Auditor %s cleared recall of grapes %s", party.ID, grapesUnit.UUID)
+	myLogger.Info(msg)
+	return []byte(msg),nil
+}
+
+// get party grapes can be transferred to
+func (t *AgrifoodChaincode) getTransferRecipient(stub shim.ChaincodeStubInterface, partyID string) (Party, error) {
+	newParty, err := t.getParty(stub, partyID)
+	if err != nil {
+		msg := fmt.Sprintf("Error determining new party: unknown party %s", partyID)
+		myLogger.Error(msg)
+		return Party{}, errors.New(msg)
+	}
+
+	if newParty.Deactivated {
+		msg := fmt.Sprintf("Error: new party %s is deactivated", newParty.ID)
+		myLogger.Error(msg)
+		return Party{}, errors.New(msg)
+	}
+
+	// grapes can only be transferred to farms, traders and wineries
+	if newParty.Role != roleFarm && newParty.Role != roleTrader && newParty.Role != roleWinery {
+		msg := fmt.Sprintf("Error: new party %s has disallowed role %s, expecting Farm, Trader or Winery", newParty.ID, newParty.Role)
+		myLogger.Error(msg)
+		return Party{}, errors.New(msg)
+	}
+
+	return newParty, nil
+}
+
+// verify grapes leaving the producer for the first time comply with the signature policy
+func (t *AgrifoodChaincode) verifyFirstTransferCompliance(stub shim.ChaincodeStubInterface, grapesUnit ProduceUnit) error {
+	if len(grapesUnit.Ownership) != 1 || len(grapesUnit.Owners) != 0 {
+		return nil
+	}
+
+	compliance, err := t.getCompliance(stub, grapesUnit)
+	if err != nil {
+		msg := fmt.Sprintf("Error determining compliance: %s", err)
+		myLogger.Error(msg)
+		return errors.New(msg)
+	}
+
+	if !compliance.Compliant {
+		msg := fmt.Sprintf("Grapes %s have %d valid signatures, %d required before first transfer", grapesUnit.UUID, compliance.ValidSignatures, compliance.RequiredSignatures)
+		myLogger.Error(msg)
+		return errors.New(msg)
+	}
+
+	return nil
+}
+
+// retire grapes at end of life (consumed or destroyed)
+func (t *AgrifoodChaincode) retire_grapes(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+	// can only be called by current owner
+	myLogger.Info("Retire grapes")
+
+	party, err := t.assertCallerRole(stub, roleFarm, roleTrader, roleWinery)
+	if err != nil {
+		return nil, err
+	}
+
+	// Check number of arguments
+	if len(args) != 3 {
+		msg := "Incorrect number of arguments. Expecting 3" // UUID, status, timestamp
+		myLogger.Error(msg)
+		return nil, errors.New(msg)
+	}
+
+	if args[1] != statusConsumed && args[1] != statusDestroyed {
+		msg := fmt.Sprintf("Invalid status %s, expecting %s or %s", args[1], statusConsumed, statusDestroyed)
+		myLogger.Error(msg)
+		return nil, errors.New(msg)
+	}
+
+	// get grapesUnit
+	grapesUnit, err := t.getGrapesUnit(stub,args[0])
+	if err != nil {
+		msg := fmt.Sprintf("Error determining grapesUnit: %s", err)
+		myLogger.Error(msg)
+		return nil, errors.New(msg)
+	}
+
+	// verify caller is current (sole) owner of grapes
+	if !isSoleOwner(grapesUnit, party.ID) {
+		msg := fmt.Sprintf("Caller is not the current owner of the grapes: %s", grapesUnit.UUID)
+		myLogger.Error(msg)
+		return nil, errors.New(msg)
+	}
+
+	if isRetired(grapesUnit) {
+		msg := fmt.Sprintf("Grapes %s are already %s", grapesUnit.UUID, grapesUnit.Status)
+		myLogger.Error(msg)
+		return nil, errors.New(msg)
+	}
+
+	grapesUnit.Retired, err = time.Parse(time.RFC3339,args[2])
+	if err != nil {
+		msg := fmt.Sprintf("Error parsing timestamp: %s", err)
+		myLogger.Error(msg)
+		return nil, errors.New(msg)
+	}
+
+	err = verifyTimestamp(stub, grapesUnit.Retired)
+	if err != nil {
+		return nil, err
+	}
+
+	grapesUnit.Status = args[1]
+
+	// save to world-state
+	err = t.saveGrapeUnit(stub,grapesUnit,false)
+	if err != nil {
+		msg := fmt.Sprintf("Error saving updated grapeUnit: %s", err)
+		myLogger.Error(msg)
+		return nil, errors.New(msg)
+	}
+
+	msg := fmt.Sprintf("Successfully retired grapes %s as %s", grapesUnit.UUID, grapesUnit.Status)
+	myLogger.Info(msg)
+	return []byte(msg),nil
+}
+
+// create wine asset from grapes owned by the caller
+func (t *AgrifoodChaincode) create_wine(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+	// can only be called by traders and wineries
+	myLogger.Info("Create wine asset")
+
+	party, err := t.assertCallerRole(stub, roleTrader, roleWinery)
+	if err != nil {
+		return nil, err
+	}
+
+	// Check number of arguments
+	if len(args) != 3 {
+		msg := "Incorrect number of arguments. Expecting 3" // BatchID, created, source grape UUIDs (JSON array)
+		myLogger.Error(msg)
+		return nil, errors.New(msg)
+	}
+
+	err = verifyID("batch ID", args[0])
+	if err != nil {
+		return nil, err
+	}
+
+	// verify uniqueness
+	existing_b, err := stub.GetState(wineKey(args[0]))
+	if err != nil {
+		msg := fmt.Sprintf("Error getting wine from storage: %s", err)
+		myLogger.Error(msg)
+		return nil, errors.New(msg)
+	}
+	if existing_b != nil {
+		msg := "Error: WineBatch ID needs to be unique"
+		myLogger.Error(msg)
+		return nil, errors.New(msg)
+	}
+
+	wineBatch := WineBatch{BatchID:args[0],Producer:party.ID}
+	wineBatch.Created, err = time.Parse(time.RFC3339, args[1])
+	if err != nil {
+		msg := "Error parsing time"
+		myLogger.Error(msg)
+		return nil, errors.New(msg)
+	}
+
+	err = verifyTimestamp(stub, wineBatch.Created)
+	if err != nil {
+		return nil, err
+	}
+
+	err = json.Unmarshal([]byte(args[2]), &wineBatch.SourceGrapeUUIDs)
+	if err != nil || len(wineBatch.SourceGrapeUUIDs) == 0 {
+		msg := fmt.Sprintf("Invalid source grapes: %s", args[2])
+		myLogger.Error(msg)
+		return nil, errors.New(msg)
+	}
+
+	// verify source grapes and mark them consumed
+	var sources []ProduceUnit
+	for _, uuid := range wineBatch.SourceGrapeUUIDs {
+		grapesUnit, err := t.getGrapesUnit(stub,uuid)
+		if err != nil {
+			msg := fmt.Sprintf("grapes not found: %s", uuid)
+			myLogger.Error(msg)
+			return nil, errors.New(msg)
+		}
+
+		if !isSoleOwner(grapesUnit, party.ID) {
+			msg := fmt.Sprintf("Caller is not the current owner of the grapes: %s", uuid)
+			myLogger.Error(msg)
+			return nil, errors.New(msg)
+		}
+
+		if isRetired(grapesUnit) {
+			msg := fmt.Sprintf("Grapes %s are already %s", uuid, grapesUnit.Status)
+			myLogger.Error(msg)
+			return nil, errors.New(msg)
+		}
+
+		for _, source := range sources {
+			if source.UUID == uuid {
+				msg := fmt.Sprintf("Grapes %s are listed twice", uuid)
+				myLogger.Error(msg)
+				return nil, errors.New(msg)
+			}
+		}
+
+		grapesUnit.Status = statusConsumed
+		grapesUnit.Retired = wineBatch.Created
+		sources = append(sources, grapesUnit)
+	}
+
+	for _, grapesUnit := range sources {
+		err = t.saveGrapeUnit(stub,grapesUnit,false)
+		if err != nil {
+			msg := fmt.Sprintf("Error saving updated grapeUnit: %s", err)
+			myLogger.Error(msg)
+			return nil, errors.New(msg)
+		}
+	}
+
+	// Add to ownership chain
+	wineBatch.Ownership = append(wineBatch.Ownership, OwnershipEntry{PartyID:party.ID,Timestamp:wineBatch.Created})
+
+	wine_b, err := json.Marshal(wineBatch)
+	if err != nil {
+		msg := "Error marshalling wine"
+		myLogger.Error(msg)
+		return nil, errors.New(msg)
+	}
+
+	err = stub.PutState(wineKey(wineBatch.BatchID), wine_b)
+	if err != nil {
+		msg := "Error saving wine"
+		myLogger.Error(msg)
+		return nil, errors.New(msg)
+	}
+
+	msg := fmt.Sprintf("Successfully added wine (%s) from %d grape units, produced by %s",wineBatch.BatchID,len(sources),wineBatch.Producer)
+	myLogger.Info(msg)
+	return []byte(msg), nil
+}
+
+// split grapes into child units, the parent is consumed
+func (t *AgrifoodChaincode) split_grapes(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+	// can only be called by current owner
+	myLogger.Info("Split grapes")
+
+	party, err := t.assertCallerRole(stub, roleFarm, roleTrader, roleWinery)
+	if err != nil {
+		return nil, err
+	}
+
+	// Check number of arguments
+	if len(args) != 3 {
+		msg := "Incorrect number of arguments. Expecting 3" // UUID, child units (JSON array of UUID, Amount), timestamp
+		myLogger.Error(msg)
+		return nil, errors.New(msg)
+	}
+
+	// get grapesUnit
+	grapesUnit, err := t.getGrapesUnit(stub,args[0])
+	if err != nil {
+		msg := fmt.Sprintf("Error determining grapesUnit: %s", err)
+		myLogger.Error(msg)
+		return nil, errors.New(msg)
+	}
+
+	// verify caller is current (sole) owner of grapes
+	if !isSoleOwner(grapesUnit, party.ID) {
+		msg := fmt.Sprintf("Caller is not the current owner of the grapes: %s", grapesUnit.UUID)
+		myLogger.Error(msg)
+		return nil, errors.New(msg)
+	}
+
+	if isRetired(grapesUnit) {
+		msg := fmt.Sprintf("Grapes %s are already %s", grapesUnit.UUID, grapesUnit.Status)
+		myLogger.Error(msg)
+		return nil, errors.New(msg)
+	}
+
+	var entries []GrapesSplitEntry
+	err = json.Unmarshal([]byte(args[1]), &entries)
+	if err != nil || len(entries) < 2 {
+		msg := fmt.Sprintf("Invalid child units, expecting at least 2: %s", args[1])
+		myLogger.Error(msg)
+		return nil, errors.New(msg)
+	}
+
+	timestamp, err := time.Parse(time.RFC3339,args[2])
+	if err != nil {
+		msg := fmt.Sprintf("Error parsing timestamp: %s", err)
+		myLogger.Error(msg)
+		return nil, errors.New(msg)
+	}
+
+	err = verifyTimestamp(stub, timestamp)
+	if err != nil {
+		return nil, err
+	}
+
+	// amounts of the children need to add up to the amount of the parent
+	total := 0
+	seen := make(map[string]bool)
+	for _, entry := range entries {
+		if entry.Amount <= 0 {
+			msg := fmt.Sprintf("Invalid amount %d for child unit %s", entry.Amount, entry.UUID)
+			myLogger.Error(msg)
+			return nil, errors.New(msg)
+		}
+		err = verifyUUID(entry.UUID)
+		if err != nil {
+			return nil, err
+		}
+		if seen[entry.UUID] {
+			msg := fmt.Sprintf("Error: UUID %s is listed twice", entry.UUID)
+			myLogger.Error(msg)
+			return nil, errors.New(msg)
+		}
+		seen[entry.UUID] = true
+		total += entry.Amount
+	}
+
+	if total != grapesUnit.Amount {
+		msg := fmt.Sprintf("Amounts of child units add up to %d, expecting %d", total, grapesUnit.Amount)
+		myLogger.Error(msg)
+		return nil, errors.New(msg)
+	}
+
+	// children inherit ownership trail and signatures of the parent
+	for _, entry := range entries {
+		child := ProduceUnit{UUID:entry.UUID,Producer:grapesUnit.Producer,Created:grapesUnit.Created,Amount:entry.Amount,Unit:grapesUnit.Unit,CropType:grapesUnit.CropType,Status:statusActive,ParentUUIDs:[]string{grapesUnit.UUID}}
+		child.Ownership = append([]OwnershipEntry{}, grapesUnit.Ownership...)
+		child.AccreditationSignatures = append([]AccreditationSignature{}, grapesUnit.AccreditationSignatures...)
+		// a recall applies to every part of the grapes
+		child.Recalled, child.RecallReason, child.RecalledBy, child.RecallTimestamp = grapesUnit.Recalled, grapesUnit.RecallReason, grapesUnit.RecalledBy, grapesUnit.RecallTimestamp
+
+		err = t.saveGrapeUnit(stub,child,true)
+		if err != nil {
+			msg := fmt.Sprintf("Error saving grapes %s: %s", child.UUID, err)
+			myLogger.Error(msg)
+			return nil, errors.New(msg)
+		}
+	}
+
+	grapesUnit.Status = statusConsumed
+	grapesUnit.Retired = timestamp
+
+	err = t.saveGrapeUnit(stub,grapesUnit,false)
+	if err != nil {
+		msg := fmt.Sprintf("Error saving updated grapeUnit: %s", err)
+		myLogger.Error(msg)
+		return nil, errors.New(msg)
+	}
+
+	msg := fmt.Sprintf("Successfully split grapes %s into %d units", grapesUnit.UUID, len(entries))
+	myLogger.Info(msg)
+	return []byte(msg),nil
+}
+
+// merge grapes of the caller into a new unit, the sources are consumed
+func (t *AgrifoodChaincode) merge_grapes(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+	// can only be called by current owner of all sources
+	myLogger.Info("Merge grapes")
+
+	party, err := t.assertCallerRole(stub, roleFarm, roleTrader, roleWinery)
+	if err != nil {
+		return nil, err
+	}
+
+	// Check number of arguments
+	if len(args) != 3 {
+		msg := "Incorrect number of arguments. Expecting 3" // new UUID, source UUIDs (JSON array), timestamp
+		myLogger.Error(msg)
+		return nil, errors.New(msg)
+	}
+
+	var uuids []string
+	err = json.Unmarshal([]byte(args[1]), &uuids)
+	if err != nil || len(uuids) < 2 {
+		msg := fmt.Sprintf("Invalid source grapes, expecting at least 2: %s", args[1])
+		myLogger.Error(msg)
+		return nil, errors.New(msg)
+	}
+
+	timestamp, err := time.Parse(time.RFC3339,args[2])
+	if err != nil {
+		msg := fmt.Sprintf("Error parsing timestamp: %s", err)
+		myLogger.Error(msg)
+		return nil, errors.New(msg)
+	}
+
+	err = verifyTimestamp(stub, timestamp)
+	if err != nil {
+		return nil, err
+	}
+
+	// get and verify sources
+	var sources []ProduceUnit
+	for _, uuid := range uuids {
+		grapesUnit, err := t.getGrapesUnit(stub,uuid)
+		if err != nil {
+			msg := fmt.Sprintf("grapes not found: %s", uuid)
+			myLogger.Error(msg)
+			return nil, errors.New(msg)
+		}
+
+		// all sources need to have the caller as current (sole) owner
+		if !isSoleOwner(grapesUnit, party.ID) {
+			msg := fmt.Sprintf("Caller is not the current owner of the grapes: %s", uuid)
+			myLogger.Error(msg)
+			return nil, errors.New(msg)
+		}
+
+		if isRetired(grapesUnit) {
+			msg := fmt.Sprintf("Grapes %s are already %s", uuid, grapesUnit.Status)
+			myLogger.Error(msg)
+			return nil, errors.New(msg)
+		}
+
+		// only grapes of the same crop type can be merged
+		if len(sources) > 0 && grapesUnit.CropType != sources[0].CropType {
+			msg := fmt.Sprintf("Grapes %s are %s, cannot merge with %s", uuid, grapesUnit.CropType, sources[0].CropType)
+			myLogger.Error(msg)
+			return nil, errors.New(msg)
+		}
+
+		// amounts can only be added up in the same unit
+		if len(sources) > 0 && grapesUnit.Unit != sources[0].Unit {
+			msg := fmt.Sprintf("Grapes %s are measured in %s, cannot merge with %s", uuid, grapesUnit.Unit, sources[0].Unit)
+			myLogger.Error(msg)
+			return nil, errors.New(msg)
+		}
+
+		for _, source := range sources {
+			if source.UUID == uuid {
+				msg := fmt.Sprintf("Grapes %s are listed twice", uuid)
+				myLogger.Error(msg)
+				return nil, errors.New(msg)
+			}
+		}
+
+		sources = append(sources, grapesUnit)
+	}
+
+	err = verifyUUID(args[0])
+	if err != nil {
+		return nil, err
+	}
+
+	merged := ProduceUnit{UUID:args[0],Producer:sources[0].Producer,Created:sources[0].Created,Unit:sources[0].Unit,CropType:sources[0].CropType,Status:statusActive,ParentUUIDs:uuids}
+
+	var ownership ownershipEntries
+	for _, source := range sources {
+		merged.Amount += source.Amount
+
+		// the caller becomes the producer of lots from different producers
+		if source.Producer != merged.Producer {
+			merged.Producer = party.ID
+		}
+		if source.Created.Before(merged.Created) {
+			merged.Created = source.Created
+		}
+
+		// a recall of any source applies to the merged grapes
+		if source.Recalled && !merged.Recalled {
+			merged.Recalled, merged.RecallReason, merged.RecalledBy, merged.RecallTimestamp = true, source.RecallReason, source.RecalledBy, source.RecallTimestamp
+		}
+
+		// merge ownership trails, skipping entries shared by sources split from the same parent
+		for _, entry := range source.Ownership {
+			duplicate := false
+			for _, known := range ownership {
+				if known.PartyID == entry.PartyID && known.Timestamp.Equal(entry.Timestamp) {
+					duplicate = true
+				}
+			}
+			if !duplicate {
+				ownership = append(ownership, entry)
+			}
+		}
+
+		source.Status = statusConsumed
+		source.Retired = timestamp
+		err = t.saveGrapeUnit(stub,source,false)
+		if err != nil {
+			msg := fmt.Sprintf("Error saving updated grapeUnit: %s", err)
+			myLogger.Error(msg)
+			return nil, errors.New(msg)
+		}
+	}
+
+	// latest entry stays the caller, who owns every source
+	sort.Stable(ownership)
+	merged.Ownership = ownership
+
+	// keep signatures of accreditations all sources are signed under
+	for _, signature := range sources[0].AccreditationSignatures {
+		if signature.Revoked {
+			continue
+		}
+
+		common := true
+		for _, source := range sources[1:] {
+			signed := false
+			for _, other := range source.AccreditationSignatures {
+				if other.AccreditationID == signature.AccreditationID && !other.Revoked {
+					signed = true
+				}
+			}
+			common = common && signed
+		}
+
+		if common {
+			merged.AccreditationSignatures = append(merged.AccreditationSignatures, signature)
+		}
+	}
+
+	err = t.saveGrapeUnit(stub,merged,true)
+	if err != nil {
+		msg := fmt.Sprintf("Error saving grapes %s: %s", merged.UUID, err)
+		myLogger.Error(msg)
+		return nil, errors.New(msg)
+	}
+
+	msg := fmt.Sprintf("Successfully merged %d units into grapes %s", len(sources), merged.UUID)
+	myLogger.Info(msg)
+	return []byte(msg),nil
+}
+
+// verify identifier argument is not empty
+func verifyID(name string, value string) error {
+	if strings.TrimSpace(value) == "" {
+		msg := fmt.Sprintf("Error: %s must not be empty", name)
+		myLogger.Error(msg)
+		return errors.New(msg)
+	}
+
+	// IDs are part of index keys, which separate them from the rest of the key with keySeparator
+	if strings.Contains(value, keySeparator) {
+		msg := fmt.Sprintf("Error: %s must not contain NUL characters", name)
+		myLogger.Error(msg)
+		return errors.New(msg)
+	}
+
+	return nil
+}
+
+// verify grape unit UUID is a canonical RFC4122 UUID
+func verifyUUID(uuid string) error {
+	if !uuidPattern.MatchString(uuid) {
+		msg := fmt.Sprintf("Error: UUID %q is not a canonical RFC4122 UUID", uuid)
+		myLogger.Error(msg)
+		return errors.New(msg)
+	}
+
+	return nil
+}
+
+// parse coordinates of a custody change
+func parseLocation(latitude string, longitude string) (*float64, *float64, error) {
+	lat, err := strconv.ParseFloat(latitude, 64)
+	if err != nil {
+		msg := fmt.Sprintf("Invalid latitude %s, expecting -90 to 90", latitude)
+		myLogger.Error(msg)
+		return nil, nil, errors.New(msg)
+	}
+
+	long, err := strconv.ParseFloat(longitude, 64)
+	if err != nil {
+		msg := fmt.Sprintf("Invalid longitude %s, expecting -180 to 180", longitude)
+		myLogger.Error(msg)
+		return nil, nil, errors.New(msg)
+	}
+
+	err = verifyLocation(lat, long)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return &lat, &long, nil
+}
+
+// verify coordinates are within range
+func verifyLocation(lat float64, long float64) error {
+	if lat < -90 || lat > 90 {
+		msg := fmt.Sprintf("Invalid latitude %v, expecting -90 to 90", lat)
+		myLogger.Error(msg)
+		return errors.New(msg)
+	}
+
+	if long < -180 || long > 180 {
+		msg := fmt.Sprintf("Invalid longitude %v, expecting -180 to 180", long)
+		myLogger.Error(msg)
+		return errors.New(msg)
+	}
+
+	return nil
+}
+
+// check if grapes reached a terminal status
+func isRetired(grapesUnit ProduceUnit) bool {
+	return grapesUnit.Status == statusConsumed || grapesUnit.Status == statusDestroyed
+}
+
+// get ownership shares of grapes, a single owner holds 100%
+func getOwnershipShares(grapesUnit ProduceUnit) []OwnershipShare {
+	if len(grapesUnit.Owners) > 0 {
+		shares := make([]OwnershipShare, len(grapesUnit.Owners))
+		copy(shares, grapesUnit.Owners)
+		return shares
+	}
+
+	latest := grapesUnit.Ownership[len(grapesUnit.Ownership)-1]
+	return []OwnershipShare{OwnershipShare{PartyID:latest.PartyID, Percentage:100, Acquired:latest.Timestamp}}
+}
+
+// get time grapes were first transferred away from the producer (fully or a share)
+func firstTransferTime(grapesUnit ProduceUnit) (time.Time, bool) {
+	if len(grapesUnit.Ownership) > 1 {
+		return grapesUnit.Ownership[1].Timestamp, true
+	}
+
+	var first time.Time
+	found := false
+	for _, share := range grapesUnit.Owners {
+		if share.PartyID != grapesUnit.Ownership[0].PartyID && (!found || share.Acquired.Before(first)) {
+			first = share.Acquired
+			found = true
+		}
+	}
+
+	return first, found
+}
+
+// check if party is the sole owner of grapes
+func isSoleOwner(grapesUnit ProduceUnit, partyID string) bool {
+	shares := getOwnershipShares(grapesUnit)
+	return len(shares) == 1 && shares[0].PartyID == partyID
+}
+
+// check if party owns (a share of) grapes
+func ownsShare(grapesUnit ProduceUnit, partyID string) bool {
+	for _, share := range getOwnershipShares(grapesUnit) {
+		if share.PartyID == partyID {
+			return true
+		}
+	}
+
+	return false
+}
+
+// verify ownership shares are positive and sum up to 100%
+func validateOwnershipShares(shares []OwnershipShare) error {
+	total := 0
+	for _, share := range shares {
+		if share.Percentage <= 0 {
+			msg := fmt.Sprintf("Ownership share of %s needs to be positive", share.PartyID)
+			return errors.New(msg)
+		}
+		total += share.Percentage
+	}
+
+	if total != 100 {
+		msg := fmt.Sprintf("Ownership shares sum up to %d%%, expecting 100%%", total)
+		return errors.New(msg)
+	}
+
+	return nil
+}
+
+// check if crop type is covered by accreditation, an empty scope covers all crop types
+func inAccreditationScope(accreditation SigningAccreditation, cropType string) bool {
+	if len(accreditation.Scope) == 0 {
+		return true
+	}
+
+	// units created before crop types were recorded are grapes
+	if cropType == "" {
+		cropType = defaultCropType
+	}
+
+	for _, scope := range accreditation.Scope {
+		if scope == cropType {
+			return true
+		}
+	}
+
+	return false
+}
+
+// save grape unit to world-state
+func (t *AgrifoodChaincode) saveGrapeUnit(stub shim.ChaincodeStubInterface, grapeUnit ProduceUnit, new bool) error {
+	existing, err := t.getGrapesUnit(stub, grapeUnit.UUID)
+	found := err == nil
+
+	if !new && !found { //update
+		msg := fmt.Sprintf("Error: unknown grape unit %s", grapeUnit.UUID)
+		myLogger.Error(msg)
+		return errors.New(msg)
+	}
+
+	// verify uniqueness, retired units are never removed so their UUIDs can't be reused
+	if new && found && isRetired(existing) {
+		msg := fmt.Sprintf("Error: GrapeUnits UUID %s belongs to %s grapes", existing.UUID, existing.Status)
+		myLogger.Error(msg)
+		return errors.New(msg)
+	}
+	if new && found {
+		msg := "Error: GrapeUnits UUID needs to be unique"
+		myLogger.Error(msg)
+		return errors.New(msg)
+	}
+
+	// serialize grape unit
+	grapes_b, err := json.Marshal(grapeUnit)
+	if err != nil {
+		msg := "Error marshalling grapes"
+		myLogger.Error(msg)
+		return errors.New(msg)
+	}
+
+	// save serialized grape unit under its own key
+	err = stub.PutState(grapesKey(grapeUnit.UUID), grapes_b)
+	if err != nil {
+		msg := "Error saving GrapeUnits"
+		myLogger.Error(msg)
+		return errors.New(msg)
+	}
+
+	// index new grape units by producer
+	if new {
+		err = stub.PutState(producerIndexKey(grapeUnit.Producer, grapeUnit.UUID), []byte(grapeUnit.UUID))
+		if err != nil {
+			msg := "Error saving producer index"
+			myLogger.Error(msg)
+			return errors.New(msg)
+		}
+	}
+
+	// record written state in the history of the grape unit, one key per transaction
+	now, err := txTime(stub)
+	if err != nil {
+		return err
+	}
+
+	entry_b, err := json.Marshal(GrapesHistoryEntry{TxID:stub.GetTxID(), Timestamp:now, Value:grapeUnit})
+	if err != nil {
+		msg := "Error marshalling grapes history"
+		myLogger.Error(msg)
+		return errors.New(msg)
+	}
+
+	err = stub.PutState(grapesHistoryEntryKey(grapeUnit.UUID, stub.GetTxID()), entry_b)
+	if err != nil {
+		msg := "Error saving grapes history"
+		myLogger.Error(msg)
+		return errors.New(msg)
+	}
+
+	return nil
+}
+
+// save signing authorization to world-state
+func (t *AgrifoodChaincode) saveSigningAuthorization(stub shim.ChaincodeStubInterface, signingAuth SigningAuthorization, new bool) error {
+	signing_auths, err := t.getSigningAuthorizations(stub)
+	if err != nil {
+		msg := fmt.Sprintf("Error retrieving signing authorizations: %s", err)
+		myLogger.Error(msg)
+		return errors.New(msg)
+	}
+
+	if !new { //update
+		// set signing authorizations
+		found := false
+		for i, v := range signing_auths {
+			if v.AuthorizedParty == signingAuth.AuthorizedParty && v.AccreditationID == signingAuth.AccreditationID {
+				signing_auths[i] = signingAuth
+				found = true
+			}
+		}
+
+		// updating an unknown authorization is an error, it would otherwise vanish silently
+		if !found {
+			msg := fmt.Sprintf("Error: signing authorization of %s for %s does not exist", signingAuth.AuthorizedParty, signingAuth.AccreditationID)
+			myLogger.Error(msg)
+			return errors.New(msg)
+		}
+	} else { // save new
+		// verify uniqueness
+		for _, v := range signing_auths {
+			if v.AuthorizedParty == signingAuth.AuthorizedParty && v.AccreditationID == signingAuth.AccreditationID {
+				msg := "Error: sighing authorization needs to be unique"
+				myLogger.Error(msg)
+				return errors.New(msg)
+			}
+		}
+		// append to array
+		signing_auths = append(signing_auths, signingAuth)
+	}
+
+	// serialize authorizations
+	signing_auths_b, err := json.Marshal(signing_auths)
+	if err != nil {
+		msg := "Error marshalling signing_auths"
+		myLogger.Error(msg)
+		return errors.New(msg)
+	}
+
+	// save serialized auths
+	err = stub.PutState(signingAuthorizationsKey, signing_auths_b)
+	if err != nil {
+		msg := "Error saving SigningAuthorizations"
+		myLogger.Error(msg)
+		return errors.New(msg)
+	}
+
+	return nil
+}
+
+// save signing authorization, updating it if present and adding it otherwise
+func (t *AgrifoodChaincode) upsertSigningAuthorization(stub shim.ChaincodeStubInterface, signingAuth SigningAuthorization) error {
+	_, err := t.getSigningAuthorization(stub, signingAuth.AccreditationID, signingAuth.AuthorizedParty)
+	return t.saveSigningAuthorization(stub, signingAuth, err != nil)
+}
+
+// save signing certificate to world-state
+func (t *AgrifoodChaincode) saveSigningAccreditation(stub shim.ChaincodeStubInterface, signingAccreditation SigningAccreditation, new bool) error {
+	signing_accreditations, err := t.getSigningAccreditations(stub)
+	if err != nil {
+		msg := fmt.Sprintf("Error retrieving signing accreditations: %s", err)
+		myLogger.Error(msg)
+		return errors.New(msg)
+	}
+
+	if !new { //update
+		// set new signing accreditation state
+		for i, v := range signing_accreditations {
+			if v.ID == signingAccreditation.ID {
+				signing_accreditations[i] = signingAccreditation
+			}
+		}
+	} else { // save new
+		// verify uniqueness
+		for _, v := range signing_accreditations {
+			if v.ID == signingAccreditation.ID {
+				msg := "Error: accreditation ID needs to be unique"
+				myLogger.Error(msg)
+				return errors.New(msg)
+			}
+		}
+		// append to array
+		signing_accreditations = append(signing_accreditations, signingAccreditation)
+	}
+
+	return t.putSigningAccreditations(stub, signing_accreditations)
+}
+
+// write all signing accreditations to world-state
+func (t *AgrifoodChaincode) putSigningAccreditations(stub shim.ChaincodeStubInterface, signing_accreditations []SigningAccreditation) error {
+	// serialize accreditations
+	signing_accreditations_b, err := json.Marshal(signing_accreditations)
+	if err != nil {
+		msg := "Error marshalling signing_accreditations"
+		myLogger.Error(msg)
+		return errors.New(msg)
+	}
+
+	// save serialized signing accreditations
+	err = stub.PutState(signingAccreditationsKey, signing_accreditations_b)
+	if err != nil {
+		msg := "Error saving SigningAccreditations"
+		myLogger.Error(msg)
+		return errors.New(msg)
+	}
+
+	return nil
+}
+
+// save party to world-state
+func (t *AgrifoodChaincode) saveParty(stub shim.ChaincodeStubInterface, party Party, new bool) error {
+	existing_b, err := stub.GetState(partyKey(party.ID))
+	if err != nil {
+		msg := fmt.Sprintf("Error retrieving party: %s", err)
+		myLogger.Error(msg)
+		return errors.New(msg)
+	}
+
+	// verify uniqueness
+	if new && existing_b != nil {
+		msg := "Error: Party ID needs to be unique"
+		myLogger.Error(msg)
+		return errors.New(msg)
+	}
+
+	// updating an unknown party is an error
+	if !new && existing_b == nil {
+		msg := fmt.Sprintf("Error: Party %s does not exist", party.ID)
+		myLogger.Error(msg)
+		return errors.New(msg)
+	}
+
+	// verify none of the certificates is indexed to another party
+	fingerprints := make(map[string]bool)
+	for _, cert := range party.Certs {
+		fingerprint, err := certFingerprint(cert)
+		if err != nil {
+			return err
+		}
+		fingerprints[fingerprint] = true
+
+		owner_b, err := stub.GetState(certIndexKey(fingerprint))
+		if err != nil {
+			msg := fmt.Sprintf("Error retrieving certificate index: %s", err)
+			myLogger.Error(msg)
+			return errors.New(msg)
+		}
+
+		if owner_b != nil && string(owner_b) != party.ID {
+			msg := fmt.Sprintf("Error: Certificate is already registered to party %s", owner_b)
+			myLogger.Error(msg)
+			return errors.New(msg)
+		}
+	}
+
+	// serialize party
+	party_b, err := json.Marshal(party)
+	if err != nil {
+		msg := "Error marshalling parties"
+		myLogger.Error(msg)
+		return errors.New(msg)
+	}
+
+	// save serialized party under its own key
+	err = stub.PutState(partyKey(party.ID), party_b)
+	if err != nil {
+		msg := "Error saving parties"
+		myLogger.Error(msg)
+		return errors.New(msg)
+	}
+
+	// index certificates so callers can be looked up without verifying every cert
+	for _, cert := range party.Certs {
+		fingerprint, _ := certFingerprint(cert)
+		err = stub.PutState(certIndexKey(fingerprint), []byte(party.ID))
+		if err != nil {
+			msg := fmt.Sprintf("Error saving certificate index: %s", err)
+			myLogger.Error(msg)
+			return errors.New(msg)
+		}
+	}
+
+	// remove index entries of certificates the party no longer holds
+	if existing_b != nil {
+		var existing Party
+		err = json.Unmarshal(existing_b, &existing)
+		if err != nil {
+			msg := "Error parsing party"
+			myLogger.Error(msg)
+			return errors.New(msg)
+		}
+
+		for _, cert := range existing.Certs {
+			fingerprint, err := certFingerprint(cert)
+			if err != nil || fingerprints[fingerprint] {
+				continue
+			}
+
+			err = stub.DelState(certIndexKey(fingerprint))
+			if err != nil {
+				msg := fmt.Sprintf("Error removing certificate index: %s", err)
+				myLogger.Error(msg)
+				return errors.New(msg)
+			}
+		}
+	}
+
+	return nil
+}
+
+// Add certificate to admin array
+func (t *AgrifoodChaincode) addAdminCert(stub shim.ChaincodeStubInterface, cert_encoded string) error {
+	// Get current array of admin certs
+	certs, err := t.getAdminCerts(stub)
+	if err != nil {
+		msg := fmt.Sprintf("Error retrieving certs: %s", err)
+		myLogger.Errorf(msg)
+		return errors.New(msg)
+	}
+
+	fingerprint, err := certFingerprint(cert_encoded)
+	if err != nil {
+		msg := fmt.Sprintf("Invalid certificate: %s", err)
+		myLogger.Errorf(msg)
+		return errors.New(msg)
+	}
+
+	// adding a known certificate is a no-op, encodings of the same certificate may differ
+	for _, cert := range certs {
+		admin_fingerprint, err := certFingerprint(cert)
+		if err == nil && admin_fingerprint == fingerprint {
+			myLogger.Debug("Certificate is already an admin certificate")
+			return nil
+		}
+	}
+
+	// append certificate to array
+	certs = append(certs, cert_encoded)
+
+	// Serialize array of certificates
+	certs_serialized, err := json.Marshal(certs)
+	if err != nil {
+		msg := fmt.Sprintf("Failed reserializing certs: %s", err)
+		myLogger.Errorf(msg)
+		return errors.New(msg)
+	}
+
+	// Save serialized array of certificates
+	save_err := stub.PutState(adminCertsKey, certs_serialized)
+	if save_err != nil {
+		msg := fmt.Sprintf("Failed saving new AdminCerts: %s", save_err)
+		myLogger.Errorf(msg)
+		return errors.New(msg)
+	}
+	myLogger.Debugf("Updated admincerts: %s", string(certs_serialized[:]))
+
+	return nil
+}
+
+/*
+Query section
+*/
+func (t *AgrifoodChaincode) Query(stub shim.ChaincodeStubInterface, function string, args []string) ([]byte, error) {
+	//myLogger.Debug("Query Chaincode...")
+
+	// load roles stored at Init
+	roles, err := getRoles(stub)
+	if err != nil {
+		return nil, err
+	}
+	t.roles = roles
+
+	t.certRoles, err = getCertRoles(stub)
+	if err != nil {
+		return nil, err
+	}
+
+	// Handle different functions
+	if function == "get_roles" {
+		return t.get_roles(stub)
+	} else if function == "get_caller_role" {
+		return t.get_caller_role(stub)
+	}  else if function == "get_role_parties" {
+		return t.get_role_parties(stub, args)
+	} else if function == "grape_ownership_trail" {
+		return t.grape_ownership_trail(stub, args)
+	}  else if function == "grape_signatures" {
+		return t.grape_signatures(stub, args)
+	} else if function == "certification_summary" {
+		return t.certification_summary(stub, args)
+	} else if function == "signer_certs" {
+		return t.signer_certs(stub, args)
+	} else if function == "get_party_accreditations" {
+		return t.get_party_accreditations(stub, args)
+	} else if function == "get_issued_accreditations" {
+		return t.get_issued_accreditations(stub, args)
+	} else if function == "get_issued_authorizations" {
+		return t.get_issued_authorizations(stub, args)
+	} else if function == "get_accreditation" {
+		return t.get_accreditation(stub, args)
+	} else if function == "get_accreditations" {
+		return t.get_accreditations(stub)
+	} else if function == "get_granted_authorizations" {
+		return t.get_granted_authorizations(stub, args)
+	} else if function == "get_granted_authorization" {
+		return t.get_granted_authorization(stub, args)
+	}  else if function == "get_authorizations" {
+		return t.get_authorizations(stub)
+	} else if function == "get_created_grapes" {
+		return t.get_created_grapes(stub, args)
+	} else if function == "get_own_grapes" {
+		return t.get_own_grapes(stub)
+	} else if function == "get_all_grapes" {
+		return t.get_all_grapes(stub)
+	} else if function == "certifiable_grapes" {
+		return t.certifiable_grapes(stub, args)
+	} else if function == "get_non_farm_grapes" {
+		return t.get_non_farm_grapes(stub)
+	} else if function == "expiring_on" {
+		return t.expiring_on(stub, args)
+	} else if function == "grape_diff" {
+		return t.grape_diff(stub, args)
+	} else if function == "grape_timeline" {
+		return t.grape_timeline(stub, args)
+	} else if function == "lead_time_stats" {
+		return t.lead_time_stats(stub, args)
+	} else if function == "compliant" {
+		return t.compliant(stub, args)
+	} else if function == "signature_authorization" {
+		return t.signature_authorization(stub, args)
+	} else if function == "signature_trust_path" {
+		return t.signature_trust_path(stub, args)
+	} else if function == "validate_authorizations" {
+		return t.validate_authorizations(stub)
+	} else if function == "state_sizes" {
+		return t.state_sizes(stub)
+	} else if function == "is_current_owner" {
+		return t.is_current_owner(stub, args)
+	} else if function == "owned_grapes" {
+		return t.owned_grapes(stub, args)
+	} else if function == "current_certifications" {
+		return t.current_certifications(stub, args)
+	} else if function == "certified_under_revoked_authority" {
+		return t.certified_under_revoked_authority(stub)
+	} else if function == "metrics" {
+		return t.metrics(stub)
+	} else if function == "list_parties" {
+		return t.list_parties(stub)
+	} else if function == "get_party" {
+		return t.get_party(stub, args)
+	} else if function == "accreditation_signers" {
+		return t.accreditation_signers(stub, args)
+	} else if function == "get_grapes" {
+		return t.get_grapes(stub, args)
+	} else if function == "list_grapes" {
+		return t.list_grapes(stub, args)
+	} else if function == "grape_history" {
+		return t.grape_history(stub, args)
+	} else if function == "get_wine" {
+		return t.get_wine(stub, args)
+	} else if function == "grape_owner" {
+		return t.grape_owner(stub, args)
+	} else if function == "is_accreditation_valid" {
+		return t.is_accreditation_valid(stub, args)
+	} else if function == "can_certify" {
+		return t.can_certify(stub, args)
+	} else if function == "verify_grapes" {
+		return t.verify_grapes(stub, args)
+	} else if function == "verify_provenance" {
+		return t.verify_provenance(stub, args)
+	} else if function == "party_certs" {
+		return t.party_certs(stub, args)
+	} else if function == "grapes_by_producer" {
+		return t.grapes_by_producer(stub, args)
+	} else if function == "produce_by_crop_type" {
+		return t.produce_by_crop_type(stub, args)
+	} else if function == "uncertified_grapes" {
+		return t.uncertified_grapes(stub)
+	}
+
+	myLogger.Errorf("Received unknown query function: %s", function)
+	return nil, errors.New("Received unknown query function")
+}
+
+// get available roles
+func (t *AgrifoodChaincode) get_roles(stub shim.ChaincodeStubInterface) ([]byte, error) {
+	// Return available roles
+	//myLogger.Info("Get roles..")
+
+	isAdmin, err := t.verifyAdmin(stub)
+	if err != nil {
+		msg := fmt.Sprintf("Error verifying caller status: %s", err)
+		myLogger.Error(msg)
+		return nil, errors.New(msg)
+	}
+
+	if !isAdmin {
+		msg := "Caller is not an admin"
+		myLogger.Error(msg)
+		return nil, errors.New(msg)
+	}
+
+	roles_b, err := json.Marshal(t.roles)
+	if err != nil {
+		msg := fmt.Sprintf("Error marshalling roles: %s", err)
+		myLogger.Error(msg)
+		return nil, errors.New(msg)
+	}
+
+	//myLogger.Info("Return roles")
+	return roles_b,nil
+}
+
+// return the role of a caller
+func (t *AgrifoodChaincode) get_caller_role(stub shim.ChaincodeStubInterface) ([]byte, error) {
+	//myLogger.Info("get caller admin status and role")
+
+	isAdmin, err := t.verifyAdmin(stub)
+	if err != nil {
+		msg := fmt.Sprintf("Error admin status of caller: %s", err)
+		myLogger.Error(msg)
+		return nil, errors.New(msg)
+	}
+
+	party, err := t.assertCallerRole(stub, t.roles...)
+	party_role := "no role"
+	if err == nil {
+		party_role = party.Role
+	}
+
+	role := CallerRole{Admin: isAdmin, Role:party_role}
+
+	role_b, err := json.Marshal(role)
+	if err != nil {
+		msg := fmt.Sprintf("Error marshalling caller role: %s", err)
+		myLogger.Error(msg)
+		return nil, errors.New(msg)
+	}
+
+	//myLogger.Infof("Caller role: %s",string(role_b[:]))
+	return role_b,nil
+}
+
+// return all parties of a role
+func (t *AgrifoodChaincode) get_role_parties(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+
+	// Check number of arguments
+	if len(args) != 1 {
+		msg := "Incorrect number of arguments. Expecting 1" // farmID
+		myLogger.Error(msg)
+		return nil, errors.New(msg)
+	}
+
+	parties, err := t.getParties(stub)
+	if err != nil {
+		msg := fmt.Sprintf("Error retrieving parties: %s", err)
+		myLogger.Error(msg)
+		return nil, errors.New(msg)
+	}
+
+	var role_parties []string
+	for _,party := range parties {
+		if party.Role == args[0] {
+			role_parties = append(role_parties,party.ID)
+		}
+	}
+
+	role_parties_b, err := json.Marshal(role_parties)
+	if err != nil {
+		msg := fmt.Sprintf("Error marshalling role_parties: %s", err)
+		myLogger.Error(msg)
+		return nil, errors.New(msg)
+	}
+
+	return role_parties_b, nil
+}
+
+// return all parties
+func (t *AgrifoodChaincode) list_parties(stub shim.ChaincodeStubInterface) ([]byte, error) {
+	parties, err := t.getParties(stub)
+	if err != nil {
+		msg := fmt.Sprintf("Error retrieving parties: %s", err)
+		myLogger.Error(msg)
+		return nil, errors.New(msg)
+	}
+
+	public_parties := []PublicParty{}
+	for _, party := range parties {
+		public_parties = append(public_parties, publicParty(party))
+	}
+
+	public_parties_b, err := json.Marshal(public_parties)
+	if err != nil {
+		msg := fmt.Sprintf("Error marshalling parties: %s", err)
+		myLogger.Error(msg)
+		return nil, errors.New(msg)
+	}
+
+	return public_parties_b, nil
+}
+
+// return single party
+func (t *AgrifoodChaincode) get_party(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+	// Check number of arguments
+	if len(args) != 1 {
+		msg := "Incorrect number of arguments. Expecting 1" // party
+		myLogger.Error(msg)
+		return nil, errors.New(msg)
+	}
+
+	party, err := t.getParty(stub, args[0])
+	if err != nil {
+		msg := fmt.Sprintf("party not found: %s", args[0])
+		myLogger.Error(msg)
+		return nil, errors.New(msg)
+	}
+
+	party_b, err := json.Marshal(publicParty(party))
+	if err != nil {
+		msg := fmt.Sprintf("Error marshalling party: %s", err)
+		myLogger.Error(msg)
+		return nil, errors.New(msg)
+	}
+
+	return party_b, nil
+}
+
+// public projection of party
+func publicParty(party Party) PublicParty {
+	return PublicParty{ID:party.ID, Role:party.Role, Deactivated:party.Deactivated, Name:party.Name, Contact:party.Contact}
+}
+
+// return complete grapes asset
+func (t *AgrifoodChaincode) get_grapes(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+	// Check number of arguments
+	if len(args) != 1 {
+		msg := "Incorrect number of arguments. Expecting 1" // UUID
+		myLogger.Error(msg)
+		return nil, errors.New(msg)
+	}
+
+	// get grapesUnit
+	grapesUnit, err := t.getGrapesUnit(stub,args[0])
+	if err != nil {
+		msg := fmt.Sprintf("grapes not found: %s", args[0])
+		myLogger.Error(msg)
+		return nil, errors.New(msg)
+	}
+
+	grapes_b, err := json.Marshal(grapesUnit)
+	if err != nil {
+		msg := fmt.Sprintf("Error marshalling grapes: %s", err)
+		myLogger.Error(msg)
+		return nil, errors.New(msg)
+	}
+
+	return grapes_b, nil
+}
+
+// return wine asset
+func (t *AgrifoodChaincode) get_wine(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+	// Check number of arguments
+	if len(args) != 1 {
+		msg := "Incorrect number of arguments. Expecting 1" // BatchID
+		myLogger.Error(msg)
+		return nil, errors.New(msg)
+	}
+
+	wine_b, err := stub.GetState(wineKey(args[0]))
+	if err != nil {
+		msg := fmt.Sprintf("Error getting wine from storage: %s", err)
+		myLogger.Error(msg)
+		return nil, errors.New(msg)
+	}
+
+	if wine_b == nil {
+		msg := fmt.Sprintf("wine not found: %s", args[0])
+		myLogger.Error(msg)
+		return nil, errors.New(msg)
+	}
+
+	return wine_b, nil
+}
+
+// return active grape units without any valid signature
+func (t *AgrifoodChaincode) uncertified_grapes(stub shim.ChaincodeStubInterface) ([]byte, error) {
+	grapes, err := t.getGrapes(stub)
+	if err != nil {
+		msg := fmt.Sprintf("Error retrieving grapes: %s", err)
+		myLogger.Error(msg)
+		return nil, errors.New(msg)
+	}
+
+	accreditations, err := t.getSigningAccreditations(stub)
+	if err != nil {
+		msg := fmt.Sprintf("Error retrieving accreditations: %s", err)
+		myLogger.Error(msg)
+		return nil, errors.New(msg)
+	}
+
+	now, err := txTime(stub)
+	if err != nil {
+		return nil, err
+	}
+
+	// look up validity of each accreditation once instead of per signature
+	validAccreditations := make(map[string]bool)
+	for _, accreditation := range accreditations {
+		validAccreditations[accreditation.ID], _ = accreditationValidity(accreditation, now)
+	}
+
+	uncertified := []ProduceUnit{}
+	for _, grapesUnit := range grapes {
+		if isRetired(grapesUnit) {
+			continue
+		}
+
+		certified := false
+		for _, signature := range grapesUnit.AccreditationSignatures {
+			if !signature.Revoked && validAccreditations[signature.AccreditationID] {
+				certified = true
+			}
+		}
+
+		if !certified {
+			uncertified = append(uncertified, grapesUnit)
+		}
+	}
+
+	uncertified_b, err := json.Marshal(uncertified)
+	if err != nil {
+		msg := fmt.Sprintf("Error marshalling grapes: %s", err)
+		myLogger.Error(msg)
+		return nil, errors.New(msg)
+	}
+
+	return uncertified_b, nil
+}
+
+// return grape units created by a producer, read through the producer index
+func (t *AgrifoodChaincode) grapes_by_producer(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+	// Check number of arguments
+	if len(args) != 1 {
+		msg := "Incorrect number of arguments. Expecting 1" // producer ID
+		myLogger.Error(msg)
+		return nil, errors.New(msg)
+	}
+
+	grapes, err := t.getGrapesByProducer(stub, args[0])
+	if err != nil {
+		msg := fmt.Sprintf("Error retrieving grapes: %s", err)
+		myLogger.Error(msg)
+		return nil, errors.New(msg)
+	}
+
+	grapes_b, err := json.Marshal(grapes)
+	if err != nil {
+		msg := fmt.Sprintf("Error marshalling grapes: %s", err)
+		myLogger.Error(msg)
+		return nil, errors.New(msg)
+	}
+
+	return grapes_b, nil
+}
+
+// return produce units of a crop type
+func (t *AgrifoodChaincode) produce_by_crop_type(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+	// Check number of arguments
+	if len(args) != 1 {
+		msg := "Incorrect number of arguments. Expecting 1" // crop type
+		myLogger.Error(msg)
+		return nil, errors.New(msg)
+	}
+
+	grapes, err := t.getGrapes(stub)
+	if err != nil {
+		msg := fmt.Sprintf("Error retrieving produce: %s", err)
+		myLogger.Error(msg)
+		return nil, errors.New(msg)
+	}
+
+	produce := []ProduceUnit{}
+	for _, grapesUnit := range grapes {
+		// units created before crop types were recorded are grapes
+		cropType := grapesUnit.CropType
+		if cropType == "" {
+			cropType = defaultCropType
+		}
+		if cropType == args[0] {
+			produce = append(produce, grapesUnit)
+		}
+	}
+
+	produce_b, err := json.Marshal(produce)
+	if err != nil {
+		msg := fmt.Sprintf("Error marshalling produce: %s", err)
+		myLogger.Error(msg)
+		return nil, errors.New(msg)
+	}
+
+	return produce_b, nil
+}
+
+// return every state a grape unit has had, oldest first
+func (t *AgrifoodChaincode) grape_history(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+	// Check number of arguments
+	if len(args) != 1 {
+		msg := "Incorrect number of arguments. Expecting 1" // UUID
+		myLogger.Error(msg)
+		return nil, errors.New(msg)
+	}
+
+	// verify grapes exist
+	_, err := t.getGrapesUnit(stub,args[0])
+	if err != nil {
+		msg := fmt.Sprintf("grapes not found: %s", args[0])
+		myLogger.Error(msg)
+		return nil, errors.New(msg)
+	}
+
+	history, err := t.getGrapesHistory(stub, args[0])
+	if err != nil {
+		return nil, err
+	}
+
+	history_b, err := json.Marshal(history)
+	if err != nil {
+		msg := fmt.Sprintf("Error marshalling grapes history: %s", err)
+		myLogger.Error(msg)
+		return nil, errors.New(msg)
+	}
+
+	return history_b, nil
+}
+
+// return a page of grape units, starting at the bookmark
+func (t *AgrifoodChaincode) list_grapes(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+	// Check number of arguments
+	if len(args) < 1 || len(args) > 2 {
+		msg := "Incorrect number of arguments. Expecting 1 or 2" // page size, (optional) bookmark
+		myLogger.Error(msg)
+		return nil, errors.New(msg)
+	}
+
+	pageSize, err := strconv.Atoi(args[0])
+	if err != nil || pageSize < 1 {
+		msg := fmt.Sprintf("Invalid page size: %s", args[0])
+		myLogger.Error(msg)
+		return nil, errors.New(msg)
+	}
+
+	startKey := grapesKeyPrefix
+	if len(args) == 2 && args[1] != "" {
+		startKey = grapesKey(args[1])
+	}
+
+	iter, err := stub.RangeQueryState(startKey, prefixEnd(grapesKeyPrefix))
+	if err != nil {
+		msg := fmt.Sprintf("Error querying grapes: %s", err)
+		myLogger.Error(msg)
+		return nil, errors.New(msg)
+	}
+	defer iter.Close()
+
+	page := GrapesPage{Grapes:[]ProduceUnit{}}
+	for iter.HasNext() {
+		_, grapes_b, err := iter.Next()
+		if err != nil {
+			msg := fmt.Sprintf("Error reading grapes: %s", err)
+			myLogger.Error(msg)
+			return nil, errors.New(msg)
+		}
+
+		var grapeUnit ProduceUnit
+		err = json.Unmarshal(grapes_b, &grapeUnit)
+		if err != nil {
+			msg := "Error parsing grapes"
+			myLogger.Error(msg)
+			return nil, errors.New(msg)
+		}
+
+		// one unit beyond the page becomes the bookmark of the next page
+		if len(page.Grapes) == pageSize {
+			page.Bookmark = grapeUnit.UUID
+			break
+		}
+		page.Grapes = append(page.Grapes, grapeUnit)
+	}
+
+	page_b, err := json.Marshal(page)
+	if err != nil {
+		msg := fmt.Sprintf("Error marshalling grapes page: %s", err)
+		myLogger.Error(msg)
+		return nil, errors.New(msg)
+	}
+
+	return page_b, nil
+}
+
+// return grape provenance
+func (t *AgrifoodChaincode) grape_ownership_trail(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+	// public query function to check ownership trail of grapes
+	//myLogger.Info("Get ownership trail of grapes..")
+
+	// Check number of arguments
+	if len(args) != 1 {
+		msg := "Incorrect number of arguments. Expecting 3" // UUID
+		myLogger.Error(msg)
+		return nil, errors.New(msg)
+	}
+
+	// get grapesUnit
+	grapesUnit, err := t.getGrapesUnit(stub,args[0])
+	if err != nil {
+		msg := fmt.Sprintf("Error determining grapesUnit: %s", err)
+		myLogger.Error(msg)
+		return nil, errors.New(msg)
+	}
+
+	// serialize ownership trail of grapes, recalled grapes should not change hands
+	trail := OwnershipTrail{UUID:grapesUnit.UUID, Ownership:grapesUnit.Ownership, Recalled:grapesUnit.Recalled, RecallReason:grapesUnit.RecallReason}
+	grapes_ownership_b, err := json.Marshal(trail)
+	if err != nil {
+		msg := fmt.Sprintf("Error marshalling grapes ownership trail: %s", err)
+		myLogger.Error(msg)
+		return nil, errors.New(msg)
+	}
+
+	//myLogger.Info("Return provenance")
+	return grapes_ownership_b, nil
+}
+
+// return UUIDs of active grapes a party currently owns (a share of)
+func (t *AgrifoodChaincode) owned_grapes(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+	// Check number of arguments
+	if len(args) != 1 {
+		msg := "Incorrect number of arguments. Expecting 1" // partyID
+		myLogger.Error(msg)
+		return nil, errors.New(msg)
+	}
+
+	// grapes are stored one per key, so this is a single range scan
+	grapes, err := t.getGrapes(stub)
+	if err != nil {
+		msg := fmt.Sprintf("Error retrieving grapes: %s", err)
+		myLogger.Error(msg)
+		return nil, errors.New(msg)
+	}
+
+	uuids := []string{}
+	for _, unit := range grapes {
+		if !isRetired(unit) && ownsShare(unit, args[0]) {
+			uuids = append(uuids, unit.UUID)
+		}
+	}
+
+	uuids_b, err := json.Marshal(uuids)
+	if err != nil {
+		msg := fmt.Sprintf("Error marshalling owned grapes: %s", err)
+		myLogger.Error(msg)
+		return nil, errors.New(msg)
+	}
+
+	return uuids_b, nil
+}
+
+// return whether party currently owns (a share of) grapes
+func (t *AgrifoodChaincode) is_current_owner(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+	// Check number of arguments
+	if len(args) != 2 {
+		msg := "Incorrect number of arguments. Expecting 2" // UUID, partyID
+		myLogger.Error(msg)
+		return nil, errors.New(msg)
+	}
+
+	// get grapesUnit
+	grapesUnit, err := t.getGrapesUnit(stub,args[0])
+	if err != nil {
+		msg := fmt.Sprintf("Error determining grapesUnit: %s", err)
+		myLogger.Error(msg)
+		return nil, errors.New(msg)
+	}
+
+	is_owner_b, err := json.Marshal(ownsShare(grapesUnit, args[1]))
+	if err != nil {
+		msg := fmt.Sprintf("Error marshalling owner status: %s", err)
+		myLogger.Error(msg)
+		return nil, errors.New(msg)
+	}
+
+	return is_owner_b, nil
+}
+
+// check the ownership chain of grapes starts at the producer, is in chronological order
+// and only refers to known parties
+func (t *AgrifoodChaincode) verify_provenance(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+	// Check number of arguments
+	if len(args) != 1 {
+		msg := "Incorrect number of arguments. Expecting 1" // UUID
+		myLogger.Error(msg)
+		return nil, errors.New(msg)
+	}
+
+	// get grapesUnit
+	grapesUnit, err := t.getGrapesUnit(stub,args[0])
+	if err != nil {
+		msg := fmt.Sprintf("grapes not found: %s", args[0])
+		myLogger.Error(msg)
+		return nil, errors.New(msg)
+	}
+
+	verification := ProvenanceVerification{UUID:grapesUnit.UUID, Problems:[]string{}}
+
+	if len(grapesUnit.Ownership) == 0 {
+		verification.Problems = append(verification.Problems, "Ownership chain is empty")
+	} else if grapesUnit.Ownership[0].PartyID != grapesUnit.Producer {
+		verification.Problems = append(verification.Problems, fmt.Sprintf("First owner %s is not producer %s", grapesUnit.Ownership[0].PartyID, grapesUnit.Producer))
+	}
+
+	// look up each party once
+	knownParties := make(map[string]bool)
+	for i, entry := range grapesUnit.Ownership {
+		if i > 0 && !entry.Timestamp.After(grapesUnit.Ownership[i-1].Timestamp) {
+			verification.Problems = append(verification.Problems, fmt.Sprintf("Entry %d (%s) is not after entry %d (%s)", i, entry.Timestamp.Format(time.RFC3339), i-1, grapesUnit.Ownership[i-1].Timestamp.Format(time.RFC3339)))
+		}
+
+		known, checked := knownParties[entry.PartyID]
+		if !checked {
+			_, err = t.getParty(stub, entry.PartyID)
+			known = err == nil
+			knownParties[entry.PartyID] = known
+		}
+		if !known {
+			verification.Problems = append(verification.Problems, fmt.Sprintf("Entry %d refers to unknown party %s", i, entry.PartyID))
+		}
+	}
+
+	verification.Valid = len(verification.Problems) == 0
+
+	verification_b, err := json.Marshal(verification)
+	if err != nil {
+		msg := fmt.Sprintf("Error marshalling verification: %s", err)
+		myLogger.Error(msg)
+		return nil, errors.New(msg)
+	}
+
+	return verification_b, nil
+}
+
+// return whether grapes are currently certified, with the validity of each signature
+func (t *AgrifoodChaincode) verify_grapes(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+	// Check number of arguments
+	if len(args) != 1 {
+		msg := "Incorrect number of arguments. Expecting 1" // UUID
+		myLogger.Error(msg)
+		return nil, errors.New(msg)
+	}
+
+	// get grapesUnit
+	grapesUnit, err := t.getGrapesUnit(stub,args[0])
+	if err != nil {
+		msg := fmt.Sprintf("grapes not found: %s", args[0])
+		myLogger.Error(msg)
+		return nil, errors.New(msg)
+	}
+
+	now, err := txTime(stub)
+	if err != nil {
+		return nil, err
+	}
+
+	verification := GrapesVerification{UUID:grapesUnit.UUID, Details:[]SignatureValidity{}, Recalled:grapesUnit.Recalled, RecallReason:grapesUnit.RecallReason}
+	validAccreditations := make(map[string]bool)
+	for _, signature := range grapesUnit.AccreditationSignatures {
+		detail := SignatureValidity{AccreditationID:signature.AccreditationID, Issuer:signature.Issuer, Issued:signature.Issued, Valid:true}
+
+		if signature.Revoked {
+			detail.Valid = false
+			detail.Reason = fmt.Sprintf("Signature revoked at %s", signature.RevocationTimestamp)
+		} else if accreditation, err := t.getSigningAccreditation(stub, signature.AccreditationID); err != nil {
+			detail.Valid = false
+			detail.Reason = "Unknown accreditation"
+		} else if valid, reason := accreditationValidity(accreditation, now); !valid {
+			detail.Valid = false
+			detail.Reason = fmt.Sprintf("Accreditation %s: %s", accreditation.ID, reason)
+		} else if signAuth, err := t.getSigningAuthorization(stub, signature.AccreditationID, signature.Issuer); err != nil {
+			detail.Valid = false
+			detail.Reason = "Unknown signing authorization"
+		} else if signAuth.Revoked {
+			detail.Valid = false
+			detail.Reason = fmt.Sprintf("Signing authorization revoked at %s", signAuth.RevocationTimestamp)
+		} else if isExpired(signAuth.Expires, now) {
+			detail.Valid = false
+			detail.Reason = fmt.Sprintf("Signing authorization expired at %s", signAuth.Expires)
+		}
+
+		if detail.Valid {
+			validAccreditations[signature.AccreditationID] = true
+		}
+		verification.Details = append(verification.Details, detail)
+	}
+
+	minSignatures, err := t.getMinSignatures(stub)
+	if err != nil {
+		return nil, err
+	}
+	verification.Certified = len(validAccreditations) > 0 && len(validAccreditations) >= minSignatures
+
+	verification_b, err := json.Marshal(verification)
+	if err != nil {
+		msg := fmt.Sprintf("Error marshalling verification: %s", err)
+		myLogger.Error(msg)
+		return nil, errors.New(msg)
+	}
+
+	return verification_b, nil
+}
+
+// return whether accreditation is usable now, and why not
+func (t *AgrifoodChaincode) is_accreditation_valid(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+	// Check number of arguments
+	if len(args) != 1 {
+		msg := "Incorrect number of arguments. Expecting 1" // accreditationID
+		myLogger.Error(msg)
+		return nil, errors.New(msg)
+	}
+
+	now, err := txTime(stub)
+	if err != nil {
+		return nil, err
+	}
+
+	validity := Validity{Reason:"Unknown accreditation"}
+	accreditation, err := t.getSigningAccreditation(stub, args[0])
+	if err == nil {
+		validity.Valid, validity.Reason = accreditationValidity(accreditation, now)
+	}
+
+	validity_b, err := json.Marshal(validity)
+	if err != nil {
+		msg := fmt.Sprintf("Error marshalling validity: %s", err)
+		myLogger.Error(msg)
+		return nil, errors.New(msg)
+	}
+
+	return validity_b, nil
+}
+
+// return current owner(s) of grapes and when they acquired them
+func (t *AgrifoodChaincode) grape_owner(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+	// Check number of arguments
+	if len(args) != 1 {
+		msg := "Incorrect number of arguments. Expecting 1" // UUID
+		myLogger.Error(msg)
+		return nil, errors.New(msg)
+	}
+
+	// get grapesUnit
+	grapesUnit, err := t.getGrapesUnit(stub,args[0])
+	if err != nil {
+		msg := fmt.Sprintf("Error determining grapesUnit: %s", err)
+		myLogger.Error(msg)
+		return nil, errors.New(msg)
+	}
+
+	if len(grapesUnit.Ownership) == 0 {
+		msg := fmt.Sprintf("Grapes %s have no ownership entries", grapesUnit.UUID)
+		myLogger.Error(msg)
+		return nil, errors.New(msg)
+	}
+
+	// a sole owner is returned as a single share of 100%
+	owners_b, err := json.Marshal(getOwnershipShares(grapesUnit))
+	if err != nil {
+		msg := fmt.Sprintf("Error marshalling owners: %s", err)
+		myLogger.Error(msg)
+		return nil, errors.New(msg)
+	}
+
+	return owners_b, nil
+}
+
+// return grape certification
+func (t *AgrifoodChaincode) grape_signatures(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+	// public query function to check accreditation of grapes
+	//myLogger.Info("Get accreditation of grapes..")
+
+	// Check number of arguments
+	if len(args) != 1 {
+		msg := "Incorrect number of arguments. Expecting 1" // UUID
+		myLogger.Error(msg)
+		return nil, errors.New(msg)
+	}
+
+	// get grapesUnit
+	grapesUnit, err := t.getGrapesUnit(stub,args[0])
+	if err != nil {
+		msg := fmt.Sprintf("Error determining grapesUnit: %s", err)
+		myLogger.Error(msg)
+		return nil, errors.New(msg)
+	}
+
+	// serialize signatures
+	grapes_signatures_b, err := json.Marshal(grapesUnit.AccreditationSignatures[0])
+	if err != nil {
+		msg := fmt.Sprintf("Error marshalling grapes certificates: %s", err)
+		myLogger.Error(msg)
+		return nil, errors.New(msg)
+	}
+
+	//myLogger.Info("Return signatures")
+	return grapes_signatures_b,nil
+}
+
+// return signature counts and the latest signature of grapes
+func (t *AgrifoodChaincode) certification_summary(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+	// Check number of arguments
+	if len(args) != 1 {
+		msg := "Incorrect number of arguments. Expecting 1" // UUID
+		myLogger.Error(msg)
+		return nil, errors.New(msg)
+	}
+
+	// get grapesUnit
+	grapesUnit, err := t.getGrapesUnit(stub,args[0])
+	if err != nil {
+		msg := fmt.Sprintf("Error determining grapesUnit: %s", err)
+		myLogger.Error(msg)
+		return nil, errors.New(msg)
+	}
+
+	summary := CertificationSummary{TotalSignatures:len(grapesUnit.AccreditationSignatures)}
+	for _, signature := range grapesUnit.AccreditationSignatures {
+		if !signature.Revoked {
+			summary.ActiveSignatures++
+		}
+		if signature.Issued.After(summary.LatestIssued) {
+			summary.LatestIssued = signature.Issued
+			summary.LatestIssuer = signature.Issuer
+		}
+	}
+
+	summary_b, err := json.Marshal(summary)
+	if err != nil {
+		msg := fmt.Sprintf("Error marshalling certification summary: %s", err)
+		myLogger.Error(msg)
+		return nil, errors.New(msg)
+	}
+
+	return summary_b, nil
+}
+
+// return all events on grapes in chronological order
+func (t *AgrifoodChaincode) grape_timeline(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+	// Check number of arguments
+	if len(args) != 1 {
+		msg := "Incorrect number of arguments. Expecting 1" // UUID
+		myLogger.Error(msg)
+		return nil, errors.New(msg)
+	}
+
+	// get grapesUnit
+	grapesUnit, err := t.getGrapesUnit(stub,args[0])
+	if err != nil {
+		msg := fmt.Sprintf("Error determining grapesUnit: %s", err)
+		myLogger.Error(msg)
+		return nil, errors.New(msg)
+	}
+
+	var timeline timelineEvents
+
+	// first ownership entry is the creation, following entries are transfers
+	for i, entry := range grapesUnit.Ownership {
+		event := TimelineEvent{Type:"transferred", Timestamp:entry.Timestamp, PartyID:entry.PartyID}
+		if i == 0 {
+			event.Type = "created"
+		}
+		timeline = append(timeline, event)
+	}
+
+	for _, signature := range grapesUnit.AccreditationSignatures {
+		timeline = append(timeline, TimelineEvent{Type:"certified", Timestamp:signature.Issued, PartyID:signature.Issuer, AccreditationID:signature.AccreditationID})
+		if signature.Revoked {
+			timeline = append(timeline, TimelineEvent{Type:"signature_revoked", Timestamp:signature.RevocationTimestamp, PartyID:signature.Issuer, AccreditationID:signature.AccreditationID})
+		}
+	}
+
+	if isRetired(grapesUnit) {
+		owner := grapesUnit.Ownership[len(grapesUnit.Ownership)-1].PartyID
+		timeline = append(timeline, TimelineEvent{Type:"retired", Timestamp:grapesUnit.Retired, PartyID:owner})
+	}
+
+	// stable sort keeps creation before certifications at the same instant
+	sort.Stable(timeline)
+
+	timeline_b, err := json.Marshal(timeline)
+	if err != nil {
+		msg := fmt.Sprintf("Error marshalling grapes timeline: %s", err)
+		myLogger.Error(msg)
+		return nil, errors.New(msg)
+	}
+
+	return timeline_b, nil
+}
+
+// return what changed on grapes between two points in time
+func (t *AgrifoodChaincode) grape_diff(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+	// Check number of arguments
+	if len(args) != 3 {
+		msg := "Incorrect number of arguments. Expecting 3" // UUID, from timestamp, to timestamp
+		myLogger.Error(msg)
+		return nil, errors.New(msg)
+	}
+
+	// get grapesUnit
+	grapesUnit, err := t.getGrapesUnit(stub,args[0])
+	if err != nil {
+		msg := fmt.Sprintf("Error determining grapesUnit: %s", err)
+		myLogger.Error(msg)
+		return nil, errors.New(msg)
+	}
+
+	diff := GrapesDiff{UUID:grapesUnit.UUID}
+	diff.From, err = time.Parse(time.RFC3339, args[1])
+	if err != nil {
+		msg := "Error parsing time (from)"
+		myLogger.Error(msg)
+		return nil, errors.New(msg)
+	}
+
+	diff.To, err = time.Parse(time.RFC3339, args[2])
+	if err != nil {
+		msg := "Error parsing time (to)"
+		myLogger.Error(msg)
+		return nil, errors.New(msg)
+	}
+
+	if diff.To.Before(diff.From) {
+		msg := "to timestamp needs to be after from timestamp"
+		myLogger.Error(msg)
+		return nil, errors.New(msg)
+	}
+
+	history, err := t.getGrapesHistory(stub, grapesUnit.UUID)
+	if err != nil {
+		msg := fmt.Sprintf("Error determining grapes history: %s", err)
+		myLogger.Error(msg)
+		return nil, errors.New(msg)
+	}
+
+	// states written by the last transactions up to both points in time
+	var before, after ProduceUnit
+	for _, entry := range history {
+		if !entry.Timestamp.After(diff.From) {
+			before = entry.Value
+		}
+		if !entry.Timestamp.After(diff.To) {
+			after = entry.Value
+		}
+	}
+
+	if len(before.Ownership) > 0 {
+		diff.OwnerBefore = before.Ownership[len(before.Ownership)-1].PartyID
+	}
+	if len(after.Ownership) > 0 {
+		diff.OwnerAfter = after.Ownership[len(after.Ownership)-1].PartyID
+	}
+
+	// ownership entries and signatures are only appended, so anything beyond the earlier state is new
+	if len(after.Ownership) > len(before.Ownership) {
+		diff.Transfers = after.Ownership[len(before.Ownership):]
+	}
+
+	for i, signature := range after.AccreditationSignatures {
+		if i >= len(before.AccreditationSignatures) {
+			diff.SignaturesAdded = append(diff.SignaturesAdded, signature)
+		}
+		if signature.Revoked && (i >= len(before.AccreditationSignatures) || !before.AccreditationSignatures[i].Revoked) {
+			diff.SignaturesRevoked = append(diff.SignaturesRevoked, signature)
+		}
+	}
+
+	diff_b, err := json.Marshal(diff)
+	if err != nil {
+		msg := fmt.Sprintf("Error marshalling grapes diff: %s", err)
+		myLogger.Error(msg)
+		return nil, errors.New(msg)
+	}
+
+	return diff_b, nil
+}
+
+// return signatures on grapes that are currently valid
+func (t *AgrifoodChaincode) current_certifications(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+	// Check number of arguments
+	if len(args) != 1 {
+		msg := "Incorrect number of arguments. Expecting 1" // UUID
+		myLogger.Error(msg)
+		return nil, errors.New(msg)
+	}
+
+	// get grapesUnit
+	grapesUnit, err := t.getGrapesUnit(stub,args[0])
+	if err != nil {
+		msg := fmt.Sprintf("Error determining grapesUnit: %s", err)
+		myLogger.Error(msg)
+		return nil, errors.New(msg)
+	}
+
+	signatures, err := t.getValidSignatures(stub, grapesUnit)
+	if err != nil {
+		msg := fmt.Sprintf("Error determining valid signatures: %s", err)
+		myLogger.Error(msg)
+		return nil, errors.New(msg)
+	}
+
+	signatures_b, err := json.Marshal(signatures)
+	if err != nil {
+		msg := fmt.Sprintf("Error marshalling grapes signatures: %s", err)
+		myLogger.Error(msg)
+		return nil, errors.New(msg)
+	}
+
+	return signatures_b, nil
+}
+
+// return signing authorizations of party for certificate
+func (t *AgrifoodChaincode) signer_certs(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+	// public query function to return signing authorizations of a farm
+	//myLogger.Info("Get signing authorizations of a farm..")
+
+	// Check number of arguments
+	if len(args) != 1 {
+		msg := "Incorrect number of arguments. Expecting 1" // farmID
+		myLogger.Error(msg)
+		return nil, errors.New(msg)
+	}
+
+	// get party
+	party, err := t.getParty(stub, args[0])
+	if err != nil {
+		msg := fmt.Sprintf("Error determining party: %s", err)
+		myLogger.Error(msg)
+		return nil, errors.New(msg)
+	}
+
+
+	all_auths, err := t.getSigningAuthorizations(stub)
+	if err != nil {
+		msg := fmt.Sprintf("Error determining grapesUnit: %s", err)
+		myLogger.Error(msg)
+		return nil, errors.New(msg)
+	}
+
+	now, err := txTime(stub)
+	if err != nil {
+		return nil, err
+	}
+
+	var party_auths []SignerAuthorization
+	for _,auth := range all_auths {
+		if auth.AuthorizedParty == party.ID {
+			valid := !auth.Revoked && !isExpired(auth.Expires, now)
+			party_auths = append(party_auths,SignerAuthorization{SigningAuthorization:auth,Valid:valid})
+		}
+	}
+
+	party_auths_b, err := json.Marshal(party_auths)
+	if err != nil {
+		msg := fmt.Sprintf("Error marshalling party authorizations: %s", err)
+		myLogger.Error(msg)
+		return nil, errors.New(msg)
+	}
+
+	return party_auths_b, nil
+}
+
+// return signing authorizations (including revoked ones) granted under an accreditation
+func (t *AgrifoodChaincode) accreditation_signers(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+	// Check number of arguments
+	if len(args) != 1 {
+		msg := "Incorrect number of arguments. Expecting 1" // accreditationID
+		myLogger.Error(msg)
+		return nil, errors.New(msg)
+	}
+
+	accreditation, err := t.getSigningAccreditation(stub, args[0])
+	if err != nil {
+		msg := fmt.Sprintf("Error retrieving accreditation: %s", err)
+		myLogger.Error(msg)
+		return nil, errors.New(msg)
+	}
+
+	authorizations, err := t.getSigningAuthorizations(stub)
+	if err != nil {
+		msg := fmt.Sprintf("Error retrieving authorizations: %s", err)
+		myLogger.Error(msg)
+		return nil, errors.New(msg)
+	}
+
+	var signers []SigningAuthorization
+	for _, auth := range authorizations {
+		if auth.AccreditationID == accreditation.ID {
+			signers = append(signers, auth)
+		}
+	}
+
+	signers_b, err := json.Marshal(signers)
+	if err != nil {
+		msg := fmt.Sprintf("Error marshalling signers: %s", err)
+		myLogger.Error(msg)
+		return nil, errors.New(msg)
+	}
+
+	return signers_b, nil
+}
+
+// return all created accreditations of party
+func (t *AgrifoodChaincode) get_party_accreditations(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+	// Check number of arguments
+	if len(args) != 1 {
+		msg := "Incorrect number of arguments. Expecting 1" // party
+		myLogger.Error(msg)
+		return nil, errors.New(msg)
+	}
+
+	party, err := t.getParty(stub, args[0])
+	if err != nil {
+		msg := fmt.Sprintf("Error retrieving party: %s", err)
+		myLogger.Error(msg)
+		return nil, errors.New(msg)
+	}
+
+	if party.Role != roleAccreditationBody {
+		msg := fmt.Sprintf("Supplied party is no AccreditationBody: %s", err)
+		myLogger.Error(msg)
+		return nil, errors.New(msg)
+	}
+
+	myLogger.Infof("Find accreditations created by %s", party.ID)
+
+	accreditations, err := t.getSigningAccreditations(stub)
+	if err != nil {
+		msg := fmt.Sprintf("Error retrieving accreditations: %s", err)
+		myLogger.Error(msg)
+		return nil, errors.New(msg)
+	}
+
+	var party_accreditations []SigningAccreditation
+	for _,accr := range accreditations {
+		if accr.AccreditationBody == party.ID {
+			party_accreditations = append(party_accreditations,accr)
+		}
+	}
+
+	party_accreditations_b, err := json.Marshal(party_accreditations)
+	if err != nil {
+		msg := fmt.Sprintf("Error marshalling party_accreditations: %s", err)
+		myLogger.Error(msg)
+		return nil, errors.New(msg)
+	}
+
+	myLogger.Infof("Accreditations from party: %s",string(party_accreditations_b[:]))
+	return party_accreditations_b, nil
+}
+
+// return all accreditations issued to party
+func (t *AgrifoodChaincode) get_issued_accreditations(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+	// Check number of arguments
+	if len(args) != 1 {
+		msg := "Incorrect number of arguments. Expecting 1" // party
+		myLogger.Error(msg)
+		return nil, errors.New(msg)
+	}
+
+	party, err := t.getParty(stub, args[0])
+	if err != nil {
+		msg := fmt.Sprintf("Error retrieving party: %s", err)
+		myLogger.Error(msg)
+		return nil, errors.New(msg)
+	}
+
+	if party.Role != roleCertificationBody {
+		msg := fmt.Sprintf("Supplied party is no CertificationBody: %s", err)
+		myLogger.Error(msg)
+		return nil, errors.New(msg)
+	}
+
+	myLogger.Infof("Find accreditations issued to %s", party.ID)
+
+	accreditations, err := t.getSigningAccreditations(stub)
+	if err != nil {
+		msg := fmt.Sprintf("Error retrieving accreditations: %s", err)
+		myLogger.Error(msg)
+		return nil, errors.New(msg)
+	}
+
+	var issued_accreditations []SigningAccreditation
+	for _,accr := range accreditations {
+		if accr.CertificationBody == party.ID {
+			issued_accreditations = append(issued_accreditations,accr)
+		}
+	}
+
+	party_accreditations_b, err := json.Marshal(issued_accreditations)
+	if err != nil {
+		msg := fmt.Sprintf("Error marshalling party_accreditations: %s", err)
+		myLogger.Error(msg)
+		return nil, errors.New(msg)
+	}
+
+	myLogger.Infof("Accreditations issued to %s: %s",party.ID,string(party_accreditations_b[:]))
+	return party_accreditations_b, nil
+}
+
+// return specific accreditation
+func (t *AgrifoodChaincode) get_accreditation(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+	// Check number of arguments
+	if len(args) != 1 {
+		msg := "Incorrect number of arguments. Expecting 1" // accreditationID
+		myLogger.Error(msg)
+		return nil, errors.New(msg)
+	}
+
+	accreditation, err := t.getSigningAccreditation(stub, args[0])
+	if err != nil {
+		msg := fmt.Sprintf("Error retrieving accreditation: %s", err)
+		myLogger.Error(msg)
+		return nil, errors.New(msg)
+	}
+
+	accreditation_b, err := json.Marshal(accreditation)
+	if err != nil {
+		msg := fmt.Sprintf("Error marshalling accreditation: %s", err)
+		myLogger.Error(msg)
+		return nil, errors.New(msg)
+	}
+
+	return accreditation_b,nil
+}
+
+// return all accreditaitons
+func (t *AgrifoodChaincode) get_accreditations(stub shim.ChaincodeStubInterface) ([]byte, error) {
+	// get accreditations
+	accreditations, err := t.getSigningAccreditations(stub)
+	if err != nil {
+		msg := fmt.Sprintf("Error retrieving accreditation: %s", err)
+		myLogger.Error(msg)
+		return nil, errors.New(msg)
+	}
+
+	accreditations_b, err := json.Marshal(accreditations)
+	if err != nil {
+		msg := fmt.Sprintf("Error marshalling accreditations: %s", err)
+		myLogger.Error(msg)
+		return nil, errors.New(msg)
+	}
+
+	return accreditations_b,nil
+}
+
+// return all authorizations issued by party
+func (t *AgrifoodChaincode) get_issued_authorizations(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+	// Check number of arguments
+	if len(args) != 1 {
+		msg := "Incorrect number of arguments. Expecting 1" // party
+		myLogger.Error(msg)
+		return nil, errors.New(msg)
+	}
+
+	cb, err := t.getParty(stub, args[0])
+	if err != nil {
+		msg := fmt.Sprintf("Error retrieving party: %s", err)
+		myLogger.Error(msg)
+		return nil, errors.New(msg)
+	}
+
+	if cb.Role != roleCertificationBody {
+		msg := fmt.Sprintf("Supplied party is no CertificationBody: %s", err)
+		myLogger.Error(msg)
+		return nil, errors.New(msg)
+	}
+
+	myLogger.Infof("Find signing authorizations issued by %s", cb.ID)
+
+	authorizations, err := t.getSigningAuthorizations(stub)
+	if err != nil {
+		msg := fmt.Sprintf("Error retrieving accreditations: %s", err)
+		myLogger.Error(msg)
+		return nil, errors.New(msg)
+	}
+
+	var issued_authorizations []SigningAuthorization
+	for _, auth := range authorizations {
+		if auth.CertifyingParty == cb.ID {
+			issued_authorizations = append(issued_authorizations, auth)
+		}
+	}
+
+	issued_authorizations_b, err := json.Marshal(issued_authorizations)
+	if err != nil {
+		msg := fmt.Sprintf("Error marshalling issued_authorizations: %s", err)
+		myLogger.Error(msg)
+		return nil, errors.New(msg)
+	}
+
+	myLogger.Infof("Accreditations issued by %s: %s", cb.ID,string(issued_authorizations_b[:]))
+	return issued_authorizations_b, nil
+}
+
+// return all authorizations granted to party
+func (t *AgrifoodChaincode) get_granted_authorizations(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+	// Check number of arguments
+	if len(args) != 1 {
+		msg := "Incorrect number of arguments. Expecting 1" // party
+		myLogger.Error(msg)
+		return nil, errors.New(msg)
+	}
+
+	farm, err := t.getParty(stub, args[0])
+	if err != nil {
+		msg := fmt.Sprintf("Error retrieving party: %s", err)
+		myLogger.Error(msg)
+		return nil, errors.New(msg)
+	}
+
+	if farm.Role != roleFarm {
+		msg := fmt.Sprintf("Supplied party is no Farm: %s", err)
+		myLogger.Error(msg)
+		return nil, errors.New(msg)
+	}
+
+	myLogger.Infof("Find signing authorizations issued to %s", farm.ID)
+
+	authorizations, err := t.getSigningAuthorizations(stub)
+	if err != nil {
+		msg := fmt.Sprintf("Error retrieving accreditations: %s", err)
+		myLogger.Error(msg)
+		return nil, errors.New(msg)
+	}
+
+	var granted_authorizations []SigningAuthorization
+	for _, auth := range authorizations {
+		if auth.AuthorizedParty == farm.ID {
+			granted_authorizations = append(granted_authorizations, auth)
 		}
-		// append to array
-		grapes = append(grapes, grapeUnit)
 	}
 
-	// serialize grapes
-	grapes_b, err := json.Marshal(grapes)
+	granted_authorizations_b, err := json.Marshal(granted_authorizations)
 	if err != nil {
-		msg := "Error marshalling grapes"
+		msg := fmt.Sprintf("Error marshalling granted_authorizations: %s", err)
 		myLogger.Error(msg)
-		return errors.New(msg)
+		return nil, errors.New(msg)
+	}
+
+	myLogger.Infof("Accreditations issued to %s: %s", farm.ID,string(granted_authorizations_b[:]))
+	return granted_authorizations_b, nil
+}
+
+// return specific signing authorization (by accreditation ID and authorized party ID)
+func (t *AgrifoodChaincode) get_granted_authorization(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+	// Check number of arguments
+	if len(args) != 2 {
+		msg := "Incorrect number of arguments. Expecting 2" // accreditationID, authorizedParty
+		myLogger.Error(msg)
+		return nil, errors.New(msg)
 	}
 
-	// save serialized grapes
-	err = stub.PutState("GrapeUnits", grapes_b)
+	auth_party, err := t.getParty(stub,args[1])
 	if err != nil {
-		msg := "Error saving GrapeUnits"
+		msg := fmt.Sprintf("Error retrieving party: %s", err)
 		myLogger.Error(msg)
-		return errors.New(msg)
+		return nil, errors.New(msg)
 	}
 
-	return nil
+	authorization, err := t.getSigningAuthorization(stub, args[0],auth_party.ID)
+	if err != nil {
+		msg := fmt.Sprintf("Error retrieving authorization: %s", err)
+		myLogger.Error(msg)
+		return nil, errors.New(msg)
+	}
+
+	authorization_b, err := json.Marshal(authorization)
+	if err != nil {
+		msg := fmt.Sprintf("Error marshalling authorization: %s", err)
+		myLogger.Error(msg)
+		return nil, errors.New(msg)
+	}
+
+	return authorization_b,nil
 }
 
-// save signing authorization to world-state
-func (t *AgrifoodChaincode) saveSigningAuthorization(stub shim.ChaincodeStubInterface, signingAuth SigningAuthorization, new bool) error {
-	signing_auths, err := t.getSigningAuthorizations(stub)
+// return all authorizations
+func (t *AgrifoodChaincode) get_authorizations(stub shim.ChaincodeStubInterface) ([]byte, error) {
+	authorizations, err := t.getSigningAuthorizations(stub)
 	if err != nil {
-		msg := fmt.Sprintf("Error retrieving signing authorizations: %s", err)
+		msg := fmt.Sprintf("Error retrieving accreditations: %s", err)
 		myLogger.Error(msg)
-		return errors.New(msg)
+		return nil, errors.New(msg)
 	}
 
-	if !new { //update
-		// set signing authorizations
-		for i, v := range signing_auths {
-			if v.AuthorizedParty == signingAuth.AuthorizedParty && v.AccreditationID == signingAuth.AccreditationID {
-				signing_auths[i] = signingAuth
-			}
+	authorizations_b, err := json.Marshal(authorizations)
+	if err != nil {
+		msg := fmt.Sprintf("Error marshalling granted_authorizations: %s", err)
+		myLogger.Error(msg)
+		return nil, errors.New(msg)
+	}
+
+	myLogger.Infof("Return all authorizations")
+	return authorizations_b, nil
+}
+
+// return all accreditations and authorizations expiring on a calendar day (UTC)
+func (t *AgrifoodChaincode) expiring_on(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+	// Check number of arguments
+	if len(args) != 1 {
+		msg := "Incorrect number of arguments. Expecting 1" // date
+		myLogger.Error(msg)
+		return nil, errors.New(msg)
+	}
+
+	date, err := time.Parse(time.RFC3339, args[0])
+	if err != nil {
+		msg := "Error parsing time"
+		myLogger.Error(msg)
+		return nil, errors.New(msg)
+	}
+
+	// calendar day of date
+	date = date.UTC()
+	start := time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, time.UTC)
+	end := start.AddDate(0, 0, 1)
+
+	accreditations, err := t.getSigningAccreditations(stub)
+	if err != nil {
+		msg := fmt.Sprintf("Error retrieving accreditations: %s", err)
+		myLogger.Error(msg)
+		return nil, errors.New(msg)
+	}
+
+	authorizations, err := t.getSigningAuthorizations(stub)
+	if err != nil {
+		msg := fmt.Sprintf("Error retrieving authorizations: %s", err)
+		myLogger.Error(msg)
+		return nil, errors.New(msg)
+	}
+
+	var expiring ExpiringEntries
+	for _, accr := range accreditations {
+		if !accr.Expires.Before(start) && accr.Expires.Before(end) {
+			expiring.Accreditations = append(expiring.Accreditations, accr)
 		}
-	} else { // save new
-		// verify uniqueness
-		for _, v := range signing_auths {
-			if v.AuthorizedParty == signingAuth.AuthorizedParty && v.AccreditationID == signingAuth.AccreditationID {
-				msg := "Error: sighing authorization needs to be unique"
-				myLogger.Error(msg)
-				return errors.New(msg)
-			}
+	}
+
+	for _, auth := range authorizations {
+		if !auth.Expires.Before(start) && auth.Expires.Before(end) {
+			expiring.Authorizations = append(expiring.Authorizations, auth)
 		}
-		// append to array
-		signing_auths = append(signing_auths, signingAuth)
 	}
 
-	// serialize authorizations
-	signing_auths_b, err := json.Marshal(signing_auths)
+	expiring_b, err := json.Marshal(expiring)
 	if err != nil {
-		msg := "Error marshalling signing_auths"
+		msg := fmt.Sprintf("Error marshalling expiring entries: %s", err)
 		myLogger.Error(msg)
-		return errors.New(msg)
+		return nil, errors.New(msg)
 	}
 
-	// save serialized auths
-	err = stub.PutState("SigningAuthorizations", signing_auths_b)
+	myLogger.Infof("Return entries expiring on %s", start.Format("2006-01-02"))
+	return expiring_b, nil
+}
+
+// return signing authorizations referencing unknown accreditations or parties, or non-farm parties
+func (t *AgrifoodChaincode) validate_authorizations(stub shim.ChaincodeStubInterface) ([]byte, error) {
+	isAdmin, err := t.verifyAdmin(stub)
 	if err != nil {
-		msg := "Error saving SigningAuthorizations"
+		msg := fmt.Sprintf("Error verifying caller status: %s", err)
 		myLogger.Error(msg)
-		return errors.New(msg)
+		return nil, errors.New(msg)
 	}
 
-	return nil
-}
+	if !isAdmin {
+		msg := "Caller is not an admin"
+		myLogger.Error(msg)
+		return nil, errors.New(msg)
+	}
 
-// save signing certificate to world-state
-func (t *AgrifoodChaincode) saveSigningAccreditation(stub shim.ChaincodeStubInterface, signingAccreditation SigningAccreditation, new bool) error {
-	signing_accreditations, err := t.getSigningAccreditations(stub)
+	authorizations, err := t.getSigningAuthorizations(stub)
 	if err != nil {
-		msg := fmt.Sprintf("Error retrieving signing accreditations: %s", err)
+		msg := fmt.Sprintf("Error retrieving authorizations: %s", err)
 		myLogger.Error(msg)
-		return errors.New(msg)
+		return nil, errors.New(msg)
 	}
 
-	if !new { //update
-		// set new signing accreditation state
-		for i, v := range signing_accreditations {
-			if v.ID == signingAccreditation.ID {
-				signing_accreditations[i] = signingAccreditation
-			}
+	accreditations, err := t.getSigningAccreditations(stub)
+	if err != nil {
+		msg := fmt.Sprintf("Error retrieving accreditations: %s", err)
+		myLogger.Error(msg)
+		return nil, errors.New(msg)
+	}
+
+	parties, err := t.getParties(stub)
+	if err != nil {
+		msg := fmt.Sprintf("Error retrieving parties: %s", err)
+		myLogger.Error(msg)
+		return nil, errors.New(msg)
+	}
+
+	known_accreditations := make(map[string]bool)
+	for _, accr := range accreditations {
+		known_accreditations[accr.ID] = true
+	}
+
+	party_roles := make(map[string]string)
+	for _, party := range parties {
+		party_roles[party.ID] = party.Role
+	}
+
+	var problems []AuthorizationProblem
+	for _, auth := range authorizations {
+		problem := AuthorizationProblem{Authorization:auth}
+
+		if !known_accreditations[auth.AccreditationID] {
+			problem.Problems = append(problem.Problems, fmt.Sprintf("Unknown accreditation %s", auth.AccreditationID))
 		}
-	} else { // save new
-		// verify uniqueness
-		for _, v := range signing_accreditations {
-			if v.ID == signingAccreditation.ID {
-				msg := "Error: accreditation ID needs to be unique"
-				myLogger.Error(msg)
-				return errors.New(msg)
-			}
+
+		role, known := party_roles[auth.AuthorizedParty]
+		if !known {
+			problem.Problems = append(problem.Problems, fmt.Sprintf("Unknown authorized party %s", auth.AuthorizedParty))
+		} else if role != roleFarm {
+			problem.Problems = append(problem.Problems, fmt.Sprintf("Authorized party %s is no Farm but %s", auth.AuthorizedParty, role))
 		}
-		// append to array
-		signing_accreditations = append(signing_accreditations, signingAccreditation)
+
+		if len(problem.Problems) > 0 {
+			problems = append(problems, problem)
+		}
+	}
+
+	problems_b, err := json.Marshal(problems)
+	if err != nil {
+		msg := fmt.Sprintf("Error marshalling authorization problems: %s", err)
+		myLogger.Error(msg)
+		return nil, errors.New(msg)
+	}
+
+	myLogger.Infof("Found %d inconsistent authorizations", len(problems))
+	return problems_b, nil
+}
+
+// return all grape assets created by party
+func (t *AgrifoodChaincode) get_created_grapes(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+	// Check number of arguments
+	if len(args) != 1 {
+		msg := "Incorrect number of arguments. Expecting 1" // party
+		myLogger.Error(msg)
+		return nil, errors.New(msg)
+	}
+
+	farm, err := t.getParty(stub, args[0])
+	if err != nil {
+		msg := fmt.Sprintf("Error retrieving party: %s", err)
+		myLogger.Error(msg)
+		return nil, errors.New(msg)
+	}
+
+	if farm.Role != roleFarm {
+		msg := fmt.Sprintf("Supplied party is no Farm: %s", err)
+		myLogger.Error(msg)
+		return nil, errors.New(msg)
+	}
+
+	myLogger.Infof("Find all grape assets created by party %s", farm.ID)
+
+	party_grapes, err := t.getGrapesByProducer(stub, farm.ID)
+	if err != nil {
+		msg := fmt.Sprintf("Error retrieving grapes: %s", err)
+		myLogger.Error(msg)
+		return nil, errors.New(msg)
+	}
+
+	party_grapes_b, err := json.Marshal(party_grapes)
+	if err != nil {
+		msg := fmt.Sprintf("Error marshalling party_grapes: %s", err)
+		myLogger.Error(msg)
+		return nil, errors.New(msg)
+	}
+
+	myLogger.Infof("Return grapes created by %s", farm.ID)
+	return party_grapes_b,nil
+}
+
+// return all grape assets owned by party
+func (t *AgrifoodChaincode) get_own_grapes(stub shim.ChaincodeStubInterface) ([]byte, error) {
+
+	party, err := t.assertCallerRole(stub, roleFarm, roleTrader, roleWinery)
+	if err != nil {
+		return nil, err
+	}
+
+	myLogger.Infof("Find all grape assets owned by party %s", party.ID)
+
+	grapes, err := t.getGrapes(stub)
+	if err != nil {
+		msg := fmt.Sprintf("Error retrieving grapes: %s", err)
+		myLogger.Error(msg)
+		return nil, errors.New(msg)
+	}
+
+	var party_grapes []ProduceUnit
+	for _,unit := range grapes {
+		if ownsShare(unit, party.ID) {
+			party_grapes = append(party_grapes,unit)
+		}
+	}
+
+	party_grapes_b, err := json.Marshal(party_grapes)
+	if err != nil {
+		msg := fmt.Sprintf("Error marshalling party_grapes: %s", err)
+		myLogger.Error(msg)
+		return nil, errors.New(msg)
 	}
 
-	// serialize accreditations
-	signing_accreditations_b, err := json.Marshal(signing_accreditations)
+	myLogger.Infof("Return grapes owned by %s", party.ID)
+	return party_grapes_b,nil
+}
+
+func (t *AgrifoodChaincode) get_all_grapes(stub shim.ChaincodeStubInterface) ([]byte, error) {
+	grapes, err := t.getGrapes(stub)
 	if err != nil {
-		msg := "Error marshalling signing_accreditations"
+		msg := fmt.Sprintf("Error retrieving grapes: %s", err)
 		myLogger.Error(msg)
-		return errors.New(msg)
+		return nil, errors.New(msg)
 	}
 
-	// save serialized signing accreditations
-	err = stub.PutState("SigningAccreditations", signing_accreditations_b)
+	grapes_b, err := json.Marshal(grapes)
 	if err != nil {
-		msg := "Error saving SigningAccreditations"
+		msg := fmt.Sprintf("Error marshalling grapes: %s", err)
 		myLogger.Error(msg)
-		return errors.New(msg)
+		return nil, errors.New(msg)
 	}
 
-	return nil
+	myLogger.Infof("Return all grapes")
+	return grapes_b,nil
 }
 
-// save party to world-state
-func (t *AgrifoodChaincode) saveParty(stub shim.ChaincodeStubInterface, party Party, new bool) error {
-	parties, err := t.getParties(stub)
+// return grapes produced by farm that it can currently certify and that are not yet validly certified
+func (t *AgrifoodChaincode) certifiable_grapes(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+	// Check number of arguments
+	if len(args) != 1 {
+		msg := "Incorrect number of arguments. Expecting 1" // party
+		myLogger.Error(msg)
+		return nil, errors.New(msg)
+	}
+
+	farm, err := t.getParty(stub, args[0])
 	if err != nil {
-		msg := fmt.Sprintf("Error retrieving parties: %s", err)
+		msg := fmt.Sprintf("Error retrieving party: %s", err)
 		myLogger.Error(msg)
-		return errors.New(msg)
+		return nil, errors.New(msg)
 	}
 
-	if new {
-		// verify uniqueness
-		for _, v := range parties {
-			if v.ID == party.ID {
-				msg := "Error: Party ID needs to be unique"
-				myLogger.Error(msg)
-				return errors.New(msg)
-			}
-		}
-		// append to array
-		parties = append(parties, party)
-	} else {
-		// set new party state
-		for i, p := range parties {
-			if p.ID == party.ID {
-				parties[i] = party
-			}
-		}
+	if farm.Role != roleFarm {
+		msg := fmt.Sprintf("Supplied party is no Farm: %s", farm.ID)
+		myLogger.Error(msg)
+		return nil, errors.New(msg)
 	}
 
-	// serialize parties
-	parties_b, err := json.Marshal(parties)
+	now, err := txTime(stub)
 	if err != nil {
-		msg := "Error marshalling parties"
+		msg := fmt.Sprintf("Error determining transaction time: %s", err)
 		myLogger.Error(msg)
-		return errors.New(msg)
+		return nil, errors.New(msg)
 	}
 
-	// save serialized parties
-	err = stub.PutState("Parties", parties_b)
+	accreditations, err := t.getSigningAccreditations(stub)
 	if err != nil {
-		msg := "Error saving parties"
+		msg := fmt.Sprintf("Error retrieving accreditations: %s", err)
 		myLogger.Error(msg)
-		return errors.New(msg)
+		return nil, errors.New(msg)
 	}
 
-	return nil
-}
+	// currently valid accreditations
+	valid_accreditations := make(map[string]SigningAccreditation)
+	for _, accr := range accreditations {
+		if !accr.Revoked && !isExpired(accr.Expires, now) {
+			valid_accreditations[accr.ID] = accr
+		}
+	}
 
-// Add certificate to admin array
-func (t *AgrifoodChaincode) addAdminCert(stub shim.ChaincodeStubInterface, cert_encoded string) error {
-	// Get current array of admin certs
-	certs, err := t.getAdminCerts(stub)
+	authorizations, err := t.getSigningAuthorizations(stub)
 	if err != nil {
-		msg := fmt.Sprintf("Error retrieving certs: %s", err)
-		myLogger.Errorf(msg)
-		return errors.New(msg)
+		msg := fmt.Sprintf("Error retrieving authorizations: %s", err)
+		myLogger.Error(msg)
+		return nil, errors.New(msg)
 	}
 
-	// append certificate to array
-	certs = append(certs, cert_encoded)
+	// accreditations the farm can currently sign with
+	var farm_accreditations []SigningAccreditation
+	for _, auth := range authorizations {
+		if auth.AuthorizedParty != farm.ID || auth.Revoked || isExpired(auth.Expires, now) {
+			continue
+		}
 
-	// Serialize array of certificates
-	certs_serialized, err := json.Marshal(certs)
-	if err != nil {
-		msg := fmt.Sprintf("Failed reserializing certs: %s", err)
-		myLogger.Errorf(msg)
-		return errors.New(msg)
+		if accr, ok := valid_accreditations[auth.AccreditationID]; ok {
+			farm_accreditations = append(farm_accreditations, accr)
+		}
 	}
 
-	// Save serialized array of certificates
-	save_err := stub.PutState("AdminCerts", certs_serialized)
-	if save_err != nil {
-		msg := fmt.Sprintf("Failed saving new AdminCerts: %s", err)
-		myLogger.Errorf(msg)
-		return errors.New(msg)
+	grapes, err := t.getGrapes(stub)
+	if err != nil {
+		msg := fmt.Sprintf("Error retrieving grapes: %s", err)
+		myLogger.Error(msg)
+		return nil, errors.New(msg)
 	}
-	myLogger.Debugf("Updated admincerts: %s", string(certs_serialized[:]))
 
-	return nil
-}
+	var certifiable_grapes []ProduceUnit
+	for _, unit := range grapes {
+		if unit.Producer != farm.ID || isRetired(unit) {
+			continue
+		}
 
-/*
-Query section
-*/
-func (t *AgrifoodChaincode) Query(stub shim.ChaincodeStubInterface, function string, args []string) ([]byte, error) {
-	//myLogger.Debug("Query Chaincode...")
+		// skip grapes that already carry a valid signature
+		certified := false
+		for _, signature := range unit.AccreditationSignatures {
+			if _, ok := valid_accreditations[signature.AccreditationID]; ok && !signature.Revoked {
+				certified = true
+				break
+			}
+		}
+		if certified {
+			continue
+		}
 
-	// Handle different functions
-	if function == "get_roles" {
-		return t.get_roles(stub)
-	} else if function == "get_caller_role" {
-		return t.get_caller_role(stub)
-	}  else if function == "get_role_parties" {
-		return t.get_role_parties(stub, args)
-	} else if function == "grape_ownership_trail" {
-		return t.grape_ownership_trail(stub, args)
-	}  else if function == "grape_signatures" {
-		return t.grape_signatures(stub, args)
-	} else if function == "signer_certs" {
-		return t.signer_certs(stub, args)
-	} else if function == "get_party_accreditations" {
-		return t.get_party_accreditations(stub, args)
-	} else if function == "get_issued_accreditations" {
-		return t.get_issued_accreditations(stub, args)
-	} else if function == "get_issued_authorizations" {
-		return t.get_issued_authorizations(stub, args)
-	} else if function == "get_accreditation" {
-		return t.get_accreditation(stub, args)
-	} else if function == "get_accreditations" {
-		return t.get_accreditations(stub)
-	} else if function == "get_granted_authorizations" {
-		return t.get_granted_authorizations(stub, args)
-	} else if function == "get_granted_authorization" {
-		return t.get_granted_authorization(stub, args)
-	}  else if function == "get_authorizations" {
-		return t.get_authorizations(stub)
-	} else if function == "get_created_grapes" {
-		return t.get_created_grapes(stub, args)
-	} else if function == "get_own_grapes" {
-		return t.get_own_grapes(stub)
-	} else if function == "get_all_grapes" {
-		return t.get_all_grapes(stub)
+		for _, accr := range farm_accreditations {
+			if inAccreditationScope(accr, unit.CropType) {
+				certifiable_grapes = append(certifiable_grapes, unit)
+				break
+			}
+		}
 	}
 
-	myLogger.Errorf("Received unknown query function: %s", function)
-	return nil, errors.New("Received unknown query function")
-}
-
-// get available roles
-func (t *AgrifoodChaincode) get_roles(stub shim.ChaincodeStubInterface) ([]byte, error) {
-	// Return available roles
-	//myLogger.Info("Get roles..")
-
-	isAdmin, err := t.verifyAdmin(stub)
+	certifiable_grapes_b, err := json.Marshal(certifiable_grapes)
 	if err != nil {
-		msg := fmt.Sprintf("Error verifying caller status: %s", err)
+		msg := fmt.Sprintf("Error marshalling certifiable_grapes: %s", err)
 		myLogger.Error(msg)
 		return nil, errors.New(msg)
 	}
 
-	if !isAdmin {
-		msg := "Caller is not an admin"
-		myLogger.Error(msg)
-		return nil, errors.New(msg)
-	}
+	myLogger.Infof("Return grapes certifiable by %s", farm.ID)
+	return certifiable_grapes_b, nil
+}
 
-	roles_b, err := json.Marshal(t.roles)
+// return signatures on grapes whose issuer's signing authorization is now revoked
+func (t *AgrifoodChaincode) certified_under_revoked_authority(stub shim.ChaincodeStubInterface) ([]byte, error) {
+	grapes, err := t.getGrapes(stub)
 	if err != nil {
-		msg := fmt.Sprintf("Error marshalling roles: %s", err)
+		msg := fmt.Sprintf("Error retrieving grapes: %s", err)
 		myLogger.Error(msg)
 		return nil, errors.New(msg)
 	}
 
-	//myLogger.Info("Return roles")
-	return roles_b,nil
-}
-
-// return the role of a caller
-func (t *AgrifoodChaincode) get_caller_role(stub shim.ChaincodeStubInterface) ([]byte, error) {
-	//myLogger.Info("get caller admin status and role")
-
-	isAdmin, err := t.verifyAdmin(stub)
+	authorizations, err := t.getSigningAuthorizations(stub)
 	if err != nil {
-		msg := fmt.Sprintf("Error admin status of caller: %s", err)
+		msg := fmt.Sprintf("Error retrieving authorizations: %s", err)
 		myLogger.Error(msg)
 		return nil, errors.New(msg)
 	}
 
-	party, err := t.getCallerParty(stub)
-	party_role := "no role"
-	if err == nil {
-		party_role = party.Role
+	// revoked authorizations by accreditation and authorized party
+	revoked := make(map[string]SigningAuthorization)
+	for _, auth := range authorizations {
+		if auth.Revoked {
+			revoked[auth.AccreditationID + "/" + auth.AuthorizedParty] = auth
+		}
 	}
 
-	role := CallerRole{Admin: isAdmin, Role:party_role}
+	var offenders []RevokedAuthoritySignature
+	for _, unit := range grapes {
+		for _, signature := range unit.AccreditationSignatures {
+			if auth, ok := revoked[signature.AccreditationID + "/" + signature.Issuer]; ok {
+				offenders = append(offenders, RevokedAuthoritySignature{UUID:unit.UUID, Signature:signature, Authorization:auth})
+			}
+		}
+	}
 
-	role_b, err := json.Marshal(role)
+	offenders_b, err := json.Marshal(offenders)
 	if err != nil {
-		msg := fmt.Sprintf("Error marshalling caller role: %s", err)
+		msg := fmt.Sprintf("Error marshalling offenders: %s", err)
 		myLogger.Error(msg)
 		return nil, errors.New(msg)
 	}
 
-	//myLogger.Infof("Caller role: %s",string(role_b[:]))
-	return role_b,nil
+	myLogger.Infof("Found %d signatures under revoked authority", len(offenders))
+	return offenders_b, nil
 }
 
-// return all parties of a role
-func (t *AgrifoodChaincode) get_role_parties(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
-
-	// Check number of arguments
-	if len(args) != 1 {
-		msg := "Incorrect number of arguments. Expecting 1" // farmID
+// return all grape assets whose producer is no longer a farm
+func (t *AgrifoodChaincode) get_non_farm_grapes(stub shim.ChaincodeStubInterface) ([]byte, error) {
+	grapes, err := t.getGrapes(stub)
+	if err != nil {
+		msg := fmt.Sprintf("Error retrieving grapes: %s", err)
 		myLogger.Error(msg)
 		return nil, errors.New(msg)
 	}
@@ -1356,31 +6164,36 @@ func (t *AgrifoodChaincode) get_role_parties(stub shim.ChaincodeStubInterface, a
 		return nil, errors.New(msg)
 	}
 
-	var role_parties []string
-	for _,party := range parties {
-		if party.Role == args[0] {
-			role_parties = append(role_parties,party.ID)
+	// current role of each party
+	party_roles := make(map[string]string)
+	for _, party := range parties {
+		party_roles[party.ID] = party.Role
+	}
+
+	// unknown producers have no role and are reported as well
+	var non_farm_grapes []ProduceUnit
+	for _, unit := range grapes {
+		if party_roles[unit.Producer] != roleFarm {
+			non_farm_grapes = append(non_farm_grapes, unit)
 		}
 	}
 
-	role_parties_b, err := json.Marshal(role_parties)
+	non_farm_grapes_b, err := json.Marshal(non_farm_grapes)
 	if err != nil {
-		msg := fmt.Sprintf("Error marshalling role_parties: %s", err)
+		msg := fmt.Sprintf("Error marshalling non_farm_grapes: %s", err)
 		myLogger.Error(msg)
 		return nil, errors.New(msg)
 	}
 
-	return role_parties_b, nil
+	myLogger.Infof("Return grapes produced by parties that are no Farm")
+	return non_farm_grapes_b,nil
 }
 
-// return grape provenance
-func (t *AgrifoodChaincode) grape_ownership_trail(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
-	// public query function to check ownership trail of grapes
-	//myLogger.Info("Get ownership trail of grapes..")
-
+// return compliance of grapes with the minimum signature policy
+func (t *AgrifoodChaincode) compliant(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
 	// Check number of arguments
 	if len(args) != 1 {
-		msg := "Incorrect number of arguments. Expecting 3" // UUID
+		msg := "Incorrect number of arguments. Expecting 1" // UUID
 		myLogger.Error(msg)
 		return nil, errors.New(msg)
 	}
@@ -1393,26 +6206,28 @@ func (t *AgrifoodChaincode) grape_ownership_trail(stub shim.ChaincodeStubInterfa
 		return nil, errors.New(msg)
 	}
 
-	// serialize ownership trail of grapes
-	grapes_ownership_b, err := json.Marshal(grapesUnit.Ownership)
+	compliance, err := t.getCompliance(stub, grapesUnit)
 	if err != nil {
-		msg := fmt.Sprintf("Error marshalling grapes ownership trail: %s", err)
+		msg := fmt.Sprintf("Error determining compliance: %s", err)
 		myLogger.Error(msg)
 		return nil, errors.New(msg)
 	}
 
-	//myLogger.Info("Return provenance")
-	return grapes_ownership_b, nil
-}
+	compliance_b, err := json.Marshal(compliance)
+	if err != nil {
+		msg := fmt.Sprintf("Error marshalling compliance: %s", err)
+		myLogger.Error(msg)
+		return nil, errors.New(msg)
+	}
 
-// return grape certification
-func (t *AgrifoodChaincode) grape_signatures(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
-	// public query function to check accreditation of grapes
-	//myLogger.Info("Get accreditation of grapes..")
+	return compliance_b, nil
+}
 
+// return the signing authorization that permitted a signature on grapes
+func (t *AgrifoodChaincode) signature_authorization(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
 	// Check number of arguments
-	if len(args) != 1 {
-		msg := "Incorrect number of arguments. Expecting 1" // UUID
+	if len(args) != 2 {
+		msg := "Incorrect number of arguments. Expecting 2" // UUID, accreditationID
 		myLogger.Error(msg)
 		return nil, errors.New(msg)
 	}
@@ -1425,509 +6240,745 @@ func (t *AgrifoodChaincode) grape_signatures(stub shim.ChaincodeStubInterface, a
 		return nil, errors.New(msg)
 	}
 
-	// serialize signatures
-	grapes_signatures_b, err := json.Marshal(grapesUnit.AccreditationSignatures[0])
+	// find issuer of signature
+	issuer := ""
+	for _, signature := range grapesUnit.AccreditationSignatures {
+		if signature.AccreditationID == args[1] {
+			issuer = signature.Issuer
+			break
+		}
+	}
+
+	if issuer == "" {
+		msg := fmt.Sprintf("No signature of %s on grapes: %s", args[1], grapesUnit.UUID)
+		myLogger.Error(msg)
+		return nil, errors.New(msg)
+	}
+
+	authorization, err := t.getSigningAuthorization(stub, args[1], issuer)
 	if err != nil {
-		msg := fmt.Sprintf("Error marshalling grapes certificates: %s", err)
+		msg := fmt.Sprintf("Error retrieving authorization of %s on %s: %s", issuer, args[1], err)
 		myLogger.Error(msg)
 		return nil, errors.New(msg)
 	}
 
-	//myLogger.Info("Return signatures")
-	return grapes_signatures_b,nil
-}
+	authorization_b, err := json.Marshal(authorization)
+	if err != nil {
+		msg := fmt.Sprintf("Error marshalling authorization: %s", err)
+		myLogger.Error(msg)
+		return nil, errors.New(msg)
+	}
 
-// return signing authorizations of party for certificate
-func (t *AgrifoodChaincode) signer_certs(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
-	// public query function to return signing authorizations of a farm
-	//myLogger.Info("Get signing authorizations of a farm..")
+	return authorization_b, nil
+}
 
+// return lead time statistics between creation and first transfer of grapes, optionally of one producer
+func (t *AgrifoodChaincode) lead_time_stats(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
 	// Check number of arguments
-	if len(args) != 1 {
-		msg := "Incorrect number of arguments. Expecting 1" // farmID
+	if len(args) > 1 {
+		msg := "Incorrect number of arguments. Expecting 0 or 1" // (optional) producer
 		myLogger.Error(msg)
 		return nil, errors.New(msg)
 	}
 
-	// get party
-	party, err := t.getParty(stub, args[0])
+	grapes, err := t.getGrapes(stub)
 	if err != nil {
-		msg := fmt.Sprintf("Error determining party: %s", err)
+		msg := fmt.Sprintf("Error retrieving grapes: %s", err)
 		myLogger.Error(msg)
 		return nil, errors.New(msg)
 	}
 
+	var stats LeadTimeStats
+	var total time.Duration
+	for _, unit := range grapes {
+		if len(args) == 1 && unit.Producer != args[0] {
+			continue
+		}
 
-	all_auths, err := t.getSigningAuthorizations(stub)
-	if err != nil {
-		msg := fmt.Sprintf("Error determining grapesUnit: %s", err)
-		myLogger.Error(msg)
-		return nil, errors.New(msg)
-	}
+		// skip grapes that were never transferred
+		transferred, ok := firstTransferTime(unit)
+		if !ok {
+			continue
+		}
 
-	var party_auths []SigningAuthorization
-	for _,auth := range all_auths {
-		if auth.AuthorizedParty == party.ID {
-			party_auths = append(party_auths,auth)
+		leadTime := transferred.Sub(unit.Ownership[0].Timestamp)
+		if stats.Count == 0 || int64(leadTime.Seconds()) < stats.MinSeconds {
+			stats.MinSeconds = int64(leadTime.Seconds())
+		}
+		if stats.Count == 0 || int64(leadTime.Seconds()) > stats.MaxSeconds {
+			stats.MaxSeconds = int64(leadTime.Seconds())
 		}
+		total += leadTime
+		stats.Count++
 	}
 
-	party_auths_b, err := json.Marshal(party_auths)
+	if stats.Count > 0 {
+		stats.AvgSeconds = int64(total.Seconds()) / int64(stats.Count)
+	}
+
+	stats_b, err := json.Marshal(stats)
 	if err != nil {
-		msg := fmt.Sprintf("Error marshalling party authorizations: %s", err)
+		msg := fmt.Sprintf("Error marshalling lead time stats: %s", err)
 		myLogger.Error(msg)
 		return nil, errors.New(msg)
 	}
 
-	return party_auths_b, nil
+	return stats_b, nil
 }
 
-// return all created accreditations of party
-func (t *AgrifoodChaincode) get_party_accreditations(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+// return the trust path from accreditation body to farm of a signature on grapes
+func (t *AgrifoodChaincode) signature_trust_path(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
 	// Check number of arguments
-	if len(args) != 1 {
-		msg := "Incorrect number of arguments. Expecting 1" // party
+	if len(args) != 2 {
+		msg := "Incorrect number of arguments. Expecting 2" // UUID, accreditationID
 		myLogger.Error(msg)
 		return nil, errors.New(msg)
 	}
 
-	party, err := t.getParty(stub, args[0])
+	// get grapesUnit
+	grapesUnit, err := t.getGrapesUnit(stub,args[0])
 	if err != nil {
-		msg := fmt.Sprintf("Error retrieving party: %s", err)
+		msg := fmt.Sprintf("Error determining grapesUnit: %s", err)
 		myLogger.Error(msg)
 		return nil, errors.New(msg)
 	}
 
-	if party.Role != t.roles[0] {
-		msg := fmt.Sprintf("Supplied party is no AccreditationBody: %s", err)
+	// find signature
+	var signature AccreditationSignature
+	found := false
+	for _, s := range grapesUnit.AccreditationSignatures {
+		if s.AccreditationID == args[1] {
+			signature = s
+			found = true
+			break
+		}
+	}
+
+	if !found {
+		msg := fmt.Sprintf("No signature of %s on grapes: %s", args[1], grapesUnit.UUID)
 		myLogger.Error(msg)
 		return nil, errors.New(msg)
 	}
 
-	myLogger.Infof("Find accreditations created by %s", party.ID)
-
-	accreditations, err := t.getSigningAccreditations(stub)
+	now, err := txTime(stub)
 	if err != nil {
-		msg := fmt.Sprintf("Error retrieving accreditations: %s", err)
+		msg := fmt.Sprintf("Error determining transaction time: %s", err)
 		myLogger.Error(msg)
 		return nil, errors.New(msg)
 	}
 
-	var party_accreditations []SigningAccreditation
-	for _,accr := range accreditations {
-		if accr.AccreditationBody == party.ID {
-			party_accreditations = append(party_accreditations,accr)
-		}
+	// accreditation and its accreditation body
+	accreditationNode := TrustPathNode{Type:"SigningAccreditation", ID:signature.AccreditationID, Valid:true}
+	abNode := TrustPathNode{Type:roleAccreditationBody, Valid:true}
+
+	accreditation, err := t.getSigningAccreditation(stub, signature.AccreditationID)
+	if err != nil {
+		accreditationNode.Valid = false
+		accreditationNode.Reason = "Unknown accreditation"
+		abNode.Valid = false
+		abNode.Reason = "Unknown accreditation"
+	} else {
+		accreditationNode.Valid, accreditationNode.Reason = accreditationValidity(accreditation, now)
+
+		abNode.ID = accreditation.AccreditationBody
+		abNode.Valid, abNode.Reason = t.trustPathPartyValidity(stub, accreditation.AccreditationBody, roleAccreditationBody)
 	}
 
-	party_accreditations_b, err := json.Marshal(party_accreditations)
+	// certification body the accreditation is issued to
+	cbNode := TrustPathNode{Type:roleCertificationBody, ID:accreditation.CertificationBody}
+	if accreditation.CertificationBody == "" {
+		cbNode.Reason = "Accreditation is not issued to a certification body"
+	} else {
+		cbNode.Valid, cbNode.Reason = t.trustPathPartyValidity(stub, accreditation.CertificationBody, roleCertificationBody)
+	}
+
+	// signing authorization of the issuer
+	authNode := TrustPathNode{Type:"SigningAuthorization", ID:signature.AccreditationID + "/" + signature.Issuer, Valid:true}
+	signAuth, err := t.getSigningAuthorization(stub, signature.AccreditationID, signature.Issuer)
 	if err != nil {
-		msg := fmt.Sprintf("Error marshalling party_accreditations: %s", err)
+		authNode.Valid = false
+		authNode.Reason = "Unknown signing authorization"
+	} else if signAuth.Revoked {
+		authNode.Valid = false
+		authNode.Reason = fmt.Sprintf("Revoked at %s", signAuth.RevocationTimestamp)
+	} else if isExpired(signAuth.Expires, now) {
+		authNode.Valid = false
+		authNode.Reason = fmt.Sprintf("Expired at %s", signAuth.Expires)
+	} else if signAuth.CertifyingParty != accreditation.CertificationBody {
+		authNode.Valid = false
+		authNode.Reason = fmt.Sprintf("Granted by %s, who is not the certification body of the accreditation", signAuth.CertifyingParty)
+	}
+
+	// farm that issued the signature
+	farmNode := TrustPathNode{Type:roleFarm, ID:signature.Issuer}
+	farmNode.Valid, farmNode.Reason = t.trustPathPartyValidity(stub, signature.Issuer, roleFarm)
+
+	path := []TrustPathNode{abNode, accreditationNode, cbNode, authNode, farmNode}
+
+	path_b, err := json.Marshal(path)
+	if err != nil {
+		msg := fmt.Sprintf("Error marshalling trust path: %s", err)
 		myLogger.Error(msg)
 		return nil, errors.New(msg)
 	}
 
-	myLogger.Infof("Accreditations from party: %s",string(party_accreditations_b[:]))
-	return party_accreditations_b, nil
+	return path_b, nil
 }
 
-// return all accreditations issued to party
-func (t *AgrifoodChaincode) get_issued_accreditations(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
-	// Check number of arguments
-	if len(args) != 1 {
-		msg := "Incorrect number of arguments. Expecting 1" // party
-		myLogger.Error(msg)
-		return nil, errors.New(msg)
+// check that party of a trust path exists and still has the expected role
+func (t *AgrifoodChaincode) trustPathPartyValidity(stub shim.ChaincodeStubInterface, partyID string, role string) (bool, string) {
+	party, err := t.getParty(stub, partyID)
+	if err != nil {
+		return false, "Unknown party"
 	}
 
-	party, err := t.getParty(stub, args[0])
+	if party.Role != role {
+		return false, fmt.Sprintf("Party is no %s but %s", role, party.Role)
+	}
+
+	return true, ""
+}
+
+// determine compliance of grapes with the minimum signature policy
+func (t *AgrifoodChaincode) getCompliance(stub shim.ChaincodeStubInterface, grapesUnit ProduceUnit) (Compliance, error) {
+	minSignatures, err := t.getMinSignatures(stub)
 	if err != nil {
-		msg := fmt.Sprintf("Error retrieving party: %s", err)
-		myLogger.Error(msg)
-		return nil, errors.New(msg)
+		return Compliance{}, err
 	}
 
-	if party.Role != t.roles[1] {
-		msg := fmt.Sprintf("Supplied party is no CertificationBody: %s", err)
-		myLogger.Error(msg)
-		return nil, errors.New(msg)
+	signatures, err := t.getValidSignatures(stub, grapesUnit)
+	if err != nil {
+		return Compliance{}, err
 	}
 
-	myLogger.Infof("Find accreditations issued to %s", party.ID)
+	// count distinct accreditations with a valid signature
+	valid := make(map[string]bool)
+	for _, signature := range signatures {
+		valid[signature.AccreditationID] = true
+	}
 
-	accreditations, err := t.getSigningAccreditations(stub)
+	compliance := Compliance{UUID:grapesUnit.UUID, ValidSignatures:len(valid), RequiredSignatures:minSignatures}
+	compliance.Compliant = compliance.ValidSignatures >= compliance.RequiredSignatures
+
+	return compliance, nil
+}
+
+// get unrevoked signatures on grapes whose accreditation is unrevoked and unexpired
+func (t *AgrifoodChaincode) getValidSignatures(stub shim.ChaincodeStubInterface, grapesUnit ProduceUnit) ([]AccreditationSignature, error) {
+	now, err := txTime(stub)
 	if err != nil {
-		msg := fmt.Sprintf("Error retrieving accreditations: %s", err)
-		myLogger.Error(msg)
-		return nil, errors.New(msg)
+		return nil, err
 	}
 
-	var issued_accreditations []SigningAccreditation
-	for _,accr := range accreditations {
-		if accr.CertificationBody == party.ID {
-			issued_accreditations = append(issued_accreditations,accr)
+	var signatures []AccreditationSignature
+	for _, signature := range grapesUnit.AccreditationSignatures {
+		if signature.Revoked {
+			continue
 		}
-	}
 
-	party_accreditations_b, err := json.Marshal(issued_accreditations)
-	if err != nil {
-		msg := fmt.Sprintf("Error marshalling party_accreditations: %s", err)
-		myLogger.Error(msg)
-		return nil, errors.New(msg)
+		accreditation, err := t.getSigningAccreditation(stub, signature.AccreditationID)
+		if err != nil || accreditation.Revoked || isExpired(accreditation.Expires, now) {
+			continue
+		}
+
+		signatures = append(signatures, signature)
 	}
 
-	myLogger.Infof("Accreditations issued to %s: %s",party.ID,string(party_accreditations_b[:]))
-	return party_accreditations_b, nil
+	return signatures, nil
 }
 
-// return specific accreditation
-func (t *AgrifoodChaincode) get_accreditation(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
-	// Check number of arguments
-	if len(args) != 1 {
-		msg := "Incorrect number of arguments. Expecting 1" // accreditationID
+// get minimum number of signatures required, no minimum when not set
+func (t *AgrifoodChaincode) getMinSignatures(stub shim.ChaincodeStubInterface) (int, error) {
+	min_b, err := stub.GetState(minSignaturesKey)
+	if err != nil {
+		msg := fmt.Sprintf("Error getting MinSignatures from storage: %s", err)
 		myLogger.Error(msg)
-		return nil, errors.New(msg)
+		return 0, errors.New(msg)
 	}
 
-	accreditation, err := t.getSigningAccreditation(stub, args[0])
-	if err != nil {
-		msg := fmt.Sprintf("Error retrieving accreditation: %s", err)
-		myLogger.Error(msg)
-		return nil, errors.New(msg)
+	if len(min_b) == 0 {
+		return 0, nil
 	}
 
-	accreditation_b, err := json.Marshal(accreditation)
+	minSignatures, err := strconv.Atoi(string(min_b))
 	if err != nil {
-		msg := fmt.Sprintf("Error marshalling accreditation: %s", err)
+		msg := "Error parsing MinSignatures"
 		myLogger.Error(msg)
-		return nil, errors.New(msg)
+		return 0, errors.New(msg)
 	}
 
-	return accreditation_b,nil
+	return minSignatures, nil
 }
 
-// return all accreditaitons
-func (t *AgrifoodChaincode) get_accreditations(stub shim.ChaincodeStubInterface) ([]byte, error) {
-	// get accreditations
-	accreditations, err := t.getSigningAccreditations(stub)
+// set chaincode event with JSON payload
+func setEvent(stub shim.ChaincodeStubInterface, name string, payload interface{}) error {
+	payload_b, err := json.Marshal(payload)
 	if err != nil {
-		msg := fmt.Sprintf("Error retrieving accreditation: %s", err)
+		msg := fmt.Sprintf("Error marshalling %s event: %s", name, err)
 		myLogger.Error(msg)
-		return nil, errors.New(msg)
+		return errors.New(msg)
 	}
 
-	accreditations_b, err := json.Marshal(accreditations)
+	err = stub.SetEvent(name, payload_b)
 	if err != nil {
-		msg := fmt.Sprintf("Error marshalling accreditations: %s", err)
+		msg := fmt.Sprintf("Error setting %s event: %s", name, err)
 		myLogger.Error(msg)
-		return nil, errors.New(msg)
+		return errors.New(msg)
 	}
 
-	return accreditations_b,nil
+	return nil
 }
 
-// return all authorizations issued by party
-func (t *AgrifoodChaincode) get_issued_authorizations(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
-	// Check number of arguments
-	if len(args) != 1 {
-		msg := "Incorrect number of arguments. Expecting 1" // party
+// get transaction timestamp, which is identical on all endorsing peers
+func txTime(stub shim.ChaincodeStubInterface) (time.Time, error) {
+	ts, err := stub.GetTxTimestamp()
+	if err != nil {
+		msg := fmt.Sprintf("Error getting transaction timestamp: %s", err)
 		myLogger.Error(msg)
-		return nil, errors.New(msg)
+		return time.Time{}, errors.New(msg)
 	}
 
-	cb, err := t.getParty(stub, args[0])
+	return time.Unix(ts.Seconds, int64(ts.Nanos)).UTC(), nil
+}
+
+// get whether roles are taken from certificate attributes, false if not stored at Init
+func getCertRoles(stub shim.ChaincodeStubInterface) (bool, error) {
+	cert_roles_b, err := stub.GetState(certRolesKey)
 	if err != nil {
-		msg := fmt.Sprintf("Error retrieving party: %s", err)
+		msg := fmt.Sprintf("Error getting role source from storage: %s", err)
 		myLogger.Error(msg)
-		return nil, errors.New(msg)
+		return false, errors.New(msg)
 	}
 
-	if cb.Role != t.roles[1] {
-		msg := fmt.Sprintf("Supplied party is no CertificationBody: %s", err)
-		myLogger.Error(msg)
-		return nil, errors.New(msg)
+	if len(cert_roles_b) == 0 {
+		return false, nil
 	}
 
-	myLogger.Infof("Find signing authorizations issued by %s", cb.ID)
+	return strconv.ParseBool(string(cert_roles_b))
+}
 
-	authorizations, err := t.getSigningAuthorizations(stub)
+// get roles stored at Init, built-in roles if none were stored
+func getRoles(stub shim.ChaincodeStubInterface) ([]string, error) {
+	roles_b, err := stub.GetState(rolesKey)
 	if err != nil {
-		msg := fmt.Sprintf("Error retrieving accreditations: %s", err)
+		msg := fmt.Sprintf("Error getting roles from storage: %s", err)
 		myLogger.Error(msg)
 		return nil, errors.New(msg)
 	}
 
-	var issued_authorizations []SigningAuthorization
-	for _, auth := range authorizations {
-		if auth.CertifyingParty == cb.ID {
-			issued_authorizations = append(issued_authorizations, auth)
-		}
+	if len(roles_b) == 0 {
+		return defaultRoles, nil
 	}
 
-	issued_authorizations_b, err := json.Marshal(issued_authorizations)
+	var roles []string
+	err = json.Unmarshal(roles_b, &roles)
 	if err != nil {
-		msg := fmt.Sprintf("Error marshalling issued_authorizations: %s", err)
+		msg := fmt.Sprintf("Error parsing roles: %s", err)
 		myLogger.Error(msg)
 		return nil, errors.New(msg)
 	}
 
-	myLogger.Infof("Accreditations issued by %s: %s", cb.ID,string(issued_authorizations_b[:]))
-	return issued_authorizations_b, nil
+	return roles, nil
 }
 
-// return all authorizations granted to party
-func (t *AgrifoodChaincode) get_granted_authorizations(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
-	// Check number of arguments
-	if len(args) != 1 {
-		msg := "Incorrect number of arguments. Expecting 1" // party
-		myLogger.Error(msg)
-		return nil, errors.New(msg)
+// check if accreditation is usable at time now, with the reason if it is not
+func accreditationValidity(accreditation SigningAccreditation, now time.Time) (bool, string) {
+	if accreditation.Revoked && accreditation.RevocationReason != "" {
+		return false, fmt.Sprintf("Revoked at %s: %s", accreditation.RevocationTimestamp, accreditation.RevocationReason)
+	}
+	if accreditation.Revoked {
+		return false, fmt.Sprintf("Revoked at %s", accreditation.RevocationTimestamp)
 	}
+	if isExpired(accreditation.Expires, now) {
+		return false, fmt.Sprintf("Expired at %s", accreditation.Expires)
+	}
+	return true, ""
+}
 
-	farm, err := t.getParty(stub, args[0])
+// verify caller-supplied timestamp is within timestampWindow of the transaction time
+func verifyTimestamp(stub shim.ChaincodeStubInterface, timestamp time.Time) error {
+	now, err := txTime(stub)
 	if err != nil {
-		msg := fmt.Sprintf("Error retrieving party: %s", err)
-		myLogger.Error(msg)
-		return nil, errors.New(msg)
+		return err
 	}
 
-	if farm.Role != t.roles[2] {
-		msg := fmt.Sprintf("Supplied party is no Farm: %s", err)
+	if timestamp.Before(now.Add(-timestampWindow)) || timestamp.After(now.Add(timestampWindow)) {
+		msg := fmt.Sprintf("Timestamp %s is not within %s of transaction time %s", timestamp.Format(time.RFC3339), timestampWindow, now.Format(time.RFC3339))
 		myLogger.Error(msg)
-		return nil, errors.New(msg)
+		return errors.New(msg)
 	}
 
-	myLogger.Infof("Find signing authorizations issued to %s", farm.ID)
-
-	authorizations, err := t.getSigningAuthorizations(stub)
-	if err != nil {
-		msg := fmt.Sprintf("Error retrieving accreditations: %s", err)
-		myLogger.Error(msg)
-		return nil, errors.New(msg)
-	}
+	return nil
+}
 
-	var granted_authorizations []SigningAuthorization
-	for _, auth := range authorizations {
-		if auth.AuthorizedParty == farm.ID {
-			granted_authorizations = append(granted_authorizations, auth)
-		}
-	}
+// check if expiration date has passed at time now, allowing for clock skew
+func isExpired(expires time.Time, now time.Time) bool {
+	return expires.Add(clockSkew).Before(now)
+}
 
-	granted_authorizations_b, err := json.Marshal(granted_authorizations)
+// return encoded certificates registered to a party
+func (t *AgrifoodChaincode) party_certs(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+	isAdmin, err := t.verifyAdmin(stub)
 	if err != nil {
-		msg := fmt.Sprintf("Error marshalling granted_authorizations: %s", err)
+		msg := fmt.Sprintf("Error verifying caller status: %s", err)
 		myLogger.Error(msg)
 		return nil, errors.New(msg)
 	}
 
-	myLogger.Infof("Accreditations issued to %s: %s", farm.ID,string(granted_authorizations_b[:]))
-	return granted_authorizations_b, nil
-}
-
-// return specific signing authorization (by accreditation ID and authorized party ID)
-func (t *AgrifoodChaincode) get_granted_authorization(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
-	// Check number of arguments
-	if len(args) != 2 {
-		msg := "Incorrect number of arguments. Expecting 2" // accreditationID, authorizedParty
+	if !isAdmin {
+		msg := "Caller is not an admin"
 		myLogger.Error(msg)
 		return nil, errors.New(msg)
 	}
 
-	auth_party, err := t.getParty(stub,args[1])
-	if err != nil {
-		msg := fmt.Sprintf("Error retrieving party: %s", err)
+	// Check number of arguments
+	if len(args) != 1 {
+		msg := "Incorrect number of arguments. Expecting 1" // party ID
 		myLogger.Error(msg)
 		return nil, errors.New(msg)
 	}
 
-	authorization, err := t.getSigningAuthorization(stub, args[0],auth_party.ID)
+	party, err := t.getParty(stub, args[0])
 	if err != nil {
-		msg := fmt.Sprintf("Error retrieving authorization: %s", err)
+		msg := fmt.Sprintf("Error determining party: %s", err)
 		myLogger.Error(msg)
 		return nil, errors.New(msg)
 	}
 
-	authorization_b, err := json.Marshal(authorization)
+	certs_b, err := json.Marshal(party.Certs)
 	if err != nil {
-		msg := fmt.Sprintf("Error marshalling authorization: %s", err)
+		msg := fmt.Sprintf("Error marshalling certs: %s", err)
 		myLogger.Error(msg)
 		return nil, errors.New(msg)
 	}
 
-	return authorization_b,nil
+	return certs_b, nil
 }
 
-// return all authorizations
-func (t *AgrifoodChaincode) get_authorizations(stub shim.ChaincodeStubInterface) ([]byte, error) {
-	authorizations, err := t.getSigningAuthorizations(stub)
+// return size and number of elements of each collection in world-state
+func (t *AgrifoodChaincode) state_sizes(stub shim.ChaincodeStubInterface) ([]byte, error) {
+	isAdmin, err := t.verifyAdmin(stub)
 	if err != nil {
-		msg := fmt.Sprintf("Error retrieving accreditations: %s", err)
+		msg := fmt.Sprintf("Error verifying caller status: %s", err)
 		myLogger.Error(msg)
 		return nil, errors.New(msg)
 	}
 
-	authorizations_b, err := json.Marshal(authorizations)
-	if err != nil {
-		msg := fmt.Sprintf("Error marshalling granted_authorizations: %s", err)
+	if !isAdmin {
+		msg := "Caller is not an admin"
 		myLogger.Error(msg)
 		return nil, errors.New(msg)
 	}
 
-	myLogger.Infof("Return all authorizations")
-	return authorizations_b, nil
-}
+	keys := []string{signingAccreditationsKey, signingAuthorizationsKey, adminCertsKey}
+
+	var sizes []StateSize
+	for _, key := range keys {
+		value_b, err := stub.GetState(key)
+		if err != nil {
+			msg := fmt.Sprintf("Error getting %s from storage: %s", key, err)
+			myLogger.Error(msg)
+			return nil, errors.New(msg)
+		}
+
+		size := StateSize{Key:key, Bytes:len(value_b)}
+		if len(value_b) > 0 {
+			var elements []json.RawMessage
+			err = json.Unmarshal(value_b, &elements)
+			if err != nil {
+				msg := fmt.Sprintf("Error parsing %s: %s", key, err)
+				myLogger.Error(msg)
+				return nil, errors.New(msg)
+			}
+			size.Count = len(elements)
+		}
+
+		sizes = append(sizes, size)
+	}
+
+	// parties and grape units are stored one per key
+	for _, prefix := range []string{partyKeyPrefix, grapesKeyPrefix} {
+		values, err := getStateByPrefix(stub, prefix)
+		if err != nil {
+			msg := fmt.Sprintf("Error getting %s from storage: %s", prefix, err)
+			myLogger.Error(msg)
+			return nil, errors.New(msg)
+		}
+
+		size := StateSize{Key:prefix, Count:len(values)}
+		for _, value_b := range values {
+			size.Bytes += len(value_b)
+		}
+		sizes = append(sizes, size)
+	}
 
-// return all grape assets created by party
-func (t *AgrifoodChaincode) get_created_grapes(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
-	// Check number of arguments
-	if len(args) != 1 {
-		msg := "Incorrect number of arguments. Expecting 1" // party
+	sizes_b, err := json.Marshal(sizes)
+	if err != nil {
+		msg := fmt.Sprintf("Error marshalling state sizes: %s", err)
 		myLogger.Error(msg)
 		return nil, errors.New(msg)
 	}
 
-	farm, err := t.getParty(stub, args[0])
+	return sizes_b, nil
+}
+
+// return number of successful calls per invoke function
+func (t *AgrifoodChaincode) metrics(stub shim.ChaincodeStubInterface) ([]byte, error) {
+	counters, err := t.getMetrics(stub)
 	if err != nil {
-		msg := fmt.Sprintf("Error retrieving party: %s", err)
+		msg := fmt.Sprintf("Error retrieving metrics: %s", err)
 		myLogger.Error(msg)
 		return nil, errors.New(msg)
 	}
 
-	if farm.Role != t.roles[2] {
-		msg := fmt.Sprintf("Supplied party is no Farm: %s", err)
+	counters_b, err := json.Marshal(counters)
+	if err != nil {
+		msg := fmt.Sprintf("Error marshalling metrics: %s", err)
 		myLogger.Error(msg)
 		return nil, errors.New(msg)
 	}
 
-	myLogger.Infof("Find all grape assets created by party %s", farm.ID)
+	return counters_b, nil
+}
 
-	grapes, err := t.getGrapes(stub)
+// increment call counter of invoke function, each function has its own key so
+// transactions calling different functions don't conflict
+func (t *AgrifoodChaincode) incrementMetric(stub shim.ChaincodeStubInterface, function string) error {
+	count_b, err := stub.GetState(metricKey(function))
 	if err != nil {
-		msg := fmt.Sprintf("Error retrieving grapes: %s", err)
+		msg := fmt.Sprintf("Error getting metrics from storage: %s", err)
 		myLogger.Error(msg)
-		return nil, errors.New(msg)
+		return errors.New(msg)
 	}
 
-	var party_grapes []GrapesUnit
-	for _,unit := range grapes {
-		if unit.Producer == farm.ID { // grapes from this farm
-			party_grapes = append(party_grapes,unit)
+	count := 0
+	if len(count_b) != 0 {
+		count, err = strconv.Atoi(string(count_b))
+		if err != nil {
+			msg := "Error parsing metrics"
+			myLogger.Error(msg)
+			return errors.New(msg)
 		}
 	}
 
-	party_grapes_b, err := json.Marshal(party_grapes)
+	err = stub.PutState(metricKey(function), []byte(strconv.Itoa(count+1)))
 	if err != nil {
-		msg := fmt.Sprintf("Error marshalling party_grapes: %s", err)
+		msg := "Error saving Metrics"
 		myLogger.Error(msg)
-		return nil, errors.New(msg)
+		return errors.New(msg)
 	}
 
-	myLogger.Infof("Return grapes created by %s", farm.ID)
-	return party_grapes_b,nil
+	return nil
 }
 
-// return all grape assets owned by party
-func (t *AgrifoodChaincode) get_own_grapes(stub shim.ChaincodeStubInterface) ([]byte, error) {
-
-	party, err := t.getCallerParty(stub)
+// get call counters per invoke function
+func (t *AgrifoodChaincode) getMetrics(stub shim.ChaincodeStubInterface) (map[string]int, error) {
+	counters_b, err := stub.GetState(metricsKey)
 	if err != nil {
-		msg := fmt.Sprintf("Error retrieving caller party: %s", err)
+		msg := fmt.Sprintf("Error getting metrics from storage: %s", err)
 		myLogger.Error(msg)
 		return nil, errors.New(msg)
 	}
 
-	if party.Role != t.roles[2] && party.Role != t.roles[4] {
-		msg := fmt.Sprintf("Supplied party (%s) is no Farm or Trader: %s", party.ID, err)
+	// counters written before functions got their own keys
+	counters := make(map[string]int)
+	if len(counters_b) != 0 {
+		err = json.Unmarshal(counters_b, &counters)
+		if err != nil {
+			msg := "Error parsing metrics"
+			myLogger.Error(msg)
+			return nil, errors.New(msg)
+		}
+	}
+
+	iter, err := stub.RangeQueryState(metricKeyPrefix, prefixEnd(metricKeyPrefix))
+	if err != nil {
+		msg := fmt.Sprintf("Error getting metrics from storage: %s", err)
 		myLogger.Error(msg)
 		return nil, errors.New(msg)
 	}
+	defer iter.Close()
 
-	myLogger.Infof("Find all grape assets owned by party %s", party.ID)
+	for iter.HasNext() {
+		key, count_b, err := iter.Next()
+		if err != nil {
+			msg := fmt.Sprintf("Error getting metrics from storage: %s", err)
+			myLogger.Error(msg)
+			return nil, errors.New(msg)
+		}
 
-	grapes, err := t.getGrapes(stub)
+		if !strings.HasPrefix(key, metricKeyPrefix) {
+			continue
+		}
+
+		count, err := strconv.Atoi(string(count_b))
+		if err != nil {
+			msg := "Error parsing metrics"
+			myLogger.Error(msg)
+			return nil, errors.New(msg)
+		}
+		counters[strings.TrimPrefix(key, metricKeyPrefix)] += count
+	}
+
+	return counters, nil
+}
+
+// get specific grape unit
+func (t *AgrifoodChaincode) getGrapesUnit(stub shim.ChaincodeStubInterface, uuid string) (ProduceUnit, error) {
+	grapes_b, err := stub.GetState(grapesKey(uuid))
 	if err != nil {
-		msg := fmt.Sprintf("Error retrieving grapes: %s", err)
+		msg := fmt.Sprintf("Error retreiving grapes: %s", err)
 		myLogger.Error(msg)
-		return nil, errors.New(msg)
+		return ProduceUnit{}, errors.New(msg)
 	}
 
-	var party_grapes []GrapesUnit
-	for _,unit := range grapes {
-		current_owner := unit.Ownership[len(unit.Ownership)-1].PartyID
-		if(current_owner == party.ID) {
-			party_grapes = append(party_grapes,unit)
-		}
+	if grapes_b == nil {
+		return ProduceUnit{}, errors.New("Unable to determine ProduceUnit")
 	}
 
-	party_grapes_b, err := json.Marshal(party_grapes)
+	var grapeUnit ProduceUnit
+	err = json.Unmarshal(grapes_b, &grapeUnit)
 	if err != nil {
-		msg := fmt.Sprintf("Error marshalling party_grapes: %s", err)
+		msg := "Error parsing grapes"
 		myLogger.Error(msg)
-		return nil, errors.New(msg)
+		return ProduceUnit{}, errors.New(msg)
 	}
 
-	myLogger.Infof("Return grapes owned by %s", party.ID)
-	return party_grapes_b,nil
+	return grapeUnit, nil
 }
 
-func (t *AgrifoodChaincode) get_all_grapes(stub shim.ChaincodeStubInterface) ([]byte, error) {
-	grapes, err := t.getGrapes(stub)
+// get all states written for a grape unit, oldest first
+func (t *AgrifoodChaincode) getGrapesHistory(stub shim.ChaincodeStubInterface, uuid string) ([]GrapesHistoryEntry, error) {
+	history_b, err := stub.GetState(grapesHistoryKey(uuid))
 	if err != nil {
-		msg := fmt.Sprintf("Error retrieving grapes: %s", err)
+		msg := fmt.Sprintf("Error getting grapes history from storage: %s", err)
 		myLogger.Error(msg)
 		return nil, errors.New(msg)
 	}
 
-	grapes_b, err := json.Marshal(grapes)
+	// history written before entries got their own keys
+	history := []GrapesHistoryEntry{}
+	if len(history_b) != 0 {
+		err = json.Unmarshal(history_b, &history)
+		if err != nil {
+			msg := "Error parsing grapes history"
+			myLogger.Error(msg)
+			return nil, errors.New(msg)
+		}
+	}
+
+	entries_b, err := getStateByPrefix(stub, grapesHistoryEntryKey(uuid, ""))
 	if err != nil {
-		msg := fmt.Sprintf("Error marshalling grapes: %s", err)
+		msg := fmt.Sprintf("Error getting grapes history from storage: %s", err)
 		myLogger.Error(msg)
 		return nil, errors.New(msg)
 	}
 
-	myLogger.Infof("Return all grapes")
-	return grapes_b,nil
+	for _, entry_b := range entries_b {
+		var entry GrapesHistoryEntry
+		err = json.Unmarshal(entry_b, &entry)
+		if err != nil {
+			msg := "Error parsing grapes history"
+			myLogger.Error(msg)
+			return nil, errors.New(msg)
+		}
+		history = append(history, entry)
+	}
+
+	// entries are keyed by transaction ID, order them by transaction time
+	sort.Stable(historyEntries(history))
+
+	return history, nil
 }
 
-// get specific grape unit
-func (t *AgrifoodChaincode) getGrapesUnit(stub shim.ChaincodeStubInterface, uuid string) (GrapesUnit, error) {
-	grapes, err := t.getGrapes(stub)
+// get all grape units
+func (t *AgrifoodChaincode) getGrapes(stub shim.ChaincodeStubInterface) ([]ProduceUnit, error) {
+	// get grapes
+	values, err := getStateByPrefix(stub, grapesKeyPrefix)
 	if err != nil {
-		msg := fmt.Sprintf("Error retreiving grapes: %s", err)
+		msg := fmt.Sprintf("Error getting grapes from storage: %s", err)
 		myLogger.Error(msg)
-		return GrapesUnit{}, errors.New(msg)
+		return nil, errors.New(msg)
 	}
 
-	for _, grapeUnit := range grapes {
-		if grapeUnit.UUID == uuid {
-			return grapeUnit, nil
+	grapes := []ProduceUnit{}
+	for _, grapes_b := range values {
+		var grapeUnit ProduceUnit
+		err = json.Unmarshal(grapes_b, &grapeUnit)
+		if err != nil {
+			msg := "Error parsing grapes"
+			myLogger.Error(msg)
+			return nil, errors.New(msg)
 		}
+		grapes = append(grapes, grapeUnit)
 	}
 
-	return GrapesUnit{}, errors.New("Unable to determine GrapesUnit")
+	return grapes, nil
 }
 
-// get all grape units
-func (t *AgrifoodChaincode) getGrapes(stub shim.ChaincodeStubInterface) ([]GrapesUnit, error) {
-	// get grapes
-	grapes_b, err := stub.GetState("GrapeUnits")
+// get grape units created by producer using the producer index
+func (t *AgrifoodChaincode) getGrapesByProducer(stub shim.ChaincodeStubInterface, producer string) ([]ProduceUnit, error) {
+	uuids_b, err := getStateByPrefix(stub, producerIndexPrefix(producer))
 	if err != nil {
-		msg := fmt.Sprintf("Error getting grapes from storage: %s", err)
+		msg := fmt.Sprintf("Error getting producer index from storage: %s", err)
 		myLogger.Error(msg)
 		return nil, errors.New(msg)
 	}
 
-	var grapes []GrapesUnit
-	err = json.Unmarshal(grapes_b, &grapes)
+	legacy_uuids_b, err := getStateByPrefix(stub, legacyProducerIndexPrefix(producer))
 	if err != nil {
-		msg := "Error parsing grapes"
+		msg := fmt.Sprintf("Error getting producer index from storage: %s", err)
 		myLogger.Error(msg)
 		return nil, errors.New(msg)
 	}
 
+	var uuids []string
+	for _, uuid := range append(uuids_b, legacy_uuids_b...) {
+		uuids = append(uuids, string(uuid))
+	}
+	sort.Strings(uuids)
+
+	grapes := []ProduceUnit{}
+	for _, uuid := range uuids {
+		grapeUnit, err := t.getGrapesUnit(stub, uuid)
+		if err != nil {
+			return nil, err
+		}
+
+		// the legacy prefix of a producer also matches producer IDs extending it
+		if grapeUnit.Producer == producer {
+			grapes = append(grapes, grapeUnit)
+		}
+	}
+
 	return grapes, nil
 }
 
+// get values of all keys starting with prefix, in key order
+func getStateByPrefix(stub shim.ChaincodeStubInterface, prefix string) ([][]byte, error) {
+	iter, err := stub.RangeQueryState(prefix, prefixEnd(prefix))
+	if err != nil {
+		return nil, err
+	}
+	defer iter.Close()
+
+	var values [][]byte
+	for iter.HasNext() {
+		key, value, err := iter.Next()
+		if err != nil {
+			return nil, err
+		}
+
+		// the end key itself may be returned
+		if !strings.HasPrefix(key, prefix) {
+			continue
+		}
+		values = append(values, value)
+	}
+
+	return values, nil
+}
+
 // get specific signing authorization
 func (t *AgrifoodChaincode) getSigningAuthorization(stub shim.ChaincodeStubInterface, accrID string, partyID string) (SigningAuthorization, error) {
 	auths, err := t.getSigningAuthorizations(stub)
@@ -1949,13 +7000,18 @@ func (t *AgrifoodChaincode) getSigningAuthorization(stub shim.ChaincodeStubInter
 // get all signing certificates
 func (t *AgrifoodChaincode) getSigningAuthorizations(stub shim.ChaincodeStubInterface) ([]SigningAuthorization, error) {
 	// get certificates
-	signing_auths_b, err := stub.GetState("SigningAuthorizations")
+	signing_auths_b, err := stub.GetState(signingAuthorizationsKey)
 	if err != nil {
 		msg := fmt.Sprintf("Error getting signing authorizations from storage: %s", err)
 		myLogger.Error(msg)
 		return nil, errors.New(msg)
 	}
 
+	// no authorizations stored yet
+	if len(signing_auths_b) == 0 {
+		return []SigningAuthorization{}, nil
+	}
+
 	var signing_auths []SigningAuthorization
 	err = json.Unmarshal(signing_auths_b, &signing_auths)
 	if err != nil {
@@ -1990,13 +7046,18 @@ func (t *AgrifoodChaincode) getSigningAccreditation(stub shim.ChaincodeStubInter
 // get all signing accreditations
 func (t *AgrifoodChaincode) getSigningAccreditations(stub shim.ChaincodeStubInterface) ([]SigningAccreditation, error) {
 	// get certificates
-	signing_accreditations_b, err := stub.GetState("SigningAccreditations")
+	signing_accreditations_b, err := stub.GetState(signingAccreditationsKey)
 	if err != nil {
 		msg := fmt.Sprintf("Error getting signing accreditations from storage: %s", err)
 		myLogger.Error(msg)
 		return nil, errors.New(msg)
 	}
 
+	// no accreditations stored yet
+	if len(signing_accreditations_b) == 0 {
+		return []SigningAccreditation{}, nil
+	}
+
 	var signing_accreditations []SigningAccreditation
 	err = json.Unmarshal(signing_accreditations_b, &signing_accreditations)
 	if err != nil {
@@ -2010,6 +7071,18 @@ func (t *AgrifoodChaincode) getSigningAccreditations(stub shim.ChaincodeStubInte
 
 // get caller party object
 func (t *AgrifoodChaincode) getCallerParty(stub shim.ChaincodeStubInterface) (Party, error) {
+	// look up party by the caller certificate first, it needs a single signature check
+	party, found, err := t.getIndexedCallerParty(stub)
+	if err != nil {
+		return Party{}, err
+	}
+	if found {
+		if party.Deactivated {
+			return Party{}, errors.New("Party " + party.ID + " is deactivated")
+		}
+		return party, nil
+	}
+
 	// get parties from storage
 	parties, err := t.getParties(stub)
 	if err != nil {
@@ -2027,6 +7100,10 @@ func (t *AgrifoodChaincode) getCallerParty(stub shim.ChaincodeStubInterface) (Pa
 			return Party{}, errors.New(msg)
 		}
 
+		if isParty && party.Deactivated {
+			return Party{}, errors.New("Party " + party.ID + " is deactivated")
+		}
+
 		if isParty {
 			return party, err
 		}
@@ -2035,48 +7112,218 @@ func (t *AgrifoodChaincode) getCallerParty(stub shim.ChaincodeStubInterface) (Pa
 	return Party{}, errors.New("Unknown caller")
 }
 
+// get caller party from the role and party ID attributes of the caller certificate,
+// registered parties keep their details and deactivation but take the role of the certificate
+func (t *AgrifoodChaincode) getAttributeCallerParty(stub shim.ChaincodeStubInterface) (Party, error) {
+	role_b, err := stub.ReadCertAttribute(roleAttribute)
+	if err != nil || len(role_b) == 0 {
+		msg := fmt.Sprintf("Error reading %s attribute of caller certificate", roleAttribute)
+		myLogger.Error(msg)
+		return Party{}, errors.New(msg)
+	}
+
+	partyID_b, err := stub.ReadCertAttribute(partyAttribute)
+	if err != nil || len(partyID_b) == 0 {
+		msg := fmt.Sprintf("Error reading %s attribute of caller certificate", partyAttribute)
+		myLogger.Error(msg)
+		return Party{}, errors.New(msg)
+	}
+
+	// verify role validity
+	role := string(role_b)
+	valid_role := false
+	for _, r := range t.roles {
+		if role == r {
+			valid_role = true
+		}
+	}
+
+	if !valid_role {
+		msg := fmt.Sprintf("Unknown role %s in caller certificate", role)
+		myLogger.Error(msg)
+		return Party{}, errors.New(msg)
+	}
+
+	party, err := t.getParty(stub, string(partyID_b))
+	if err != nil {
+		party = Party{ID:string(partyID_b)}
+	}
+
+	if party.Deactivated {
+		return Party{}, errors.New("Party " + party.ID + " is deactivated")
+	}
+
+	party.Role = role
+	return party, nil
+}
+
+// get caller party through the certificate index, not found for certificates indexed
+// before the index existed or when the caller certificate is not available
+func (t *AgrifoodChaincode) getIndexedCallerParty(stub shim.ChaincodeStubInterface) (Party, bool, error) {
+	cert, err := stub.GetCallerCertificate()
+	if err != nil || len(cert) == 0 {
+		return Party{}, false, nil
+	}
+
+	hash := sha256.Sum256(cert)
+	partyID_b, err := stub.GetState(certIndexKey(hex.EncodeToString(hash[:])))
+	if err != nil {
+		msg := fmt.Sprintf("Error retrieving certificate index: %s", err)
+		myLogger.Error(msg)
+		return Party{}, false, errors.New(msg)
+	}
+	if partyID_b == nil {
+		return Party{}, false, nil
+	}
+
+	// caller needs to prove it holds the certificate
+	ok, err := t.isCaller(stub, cert)
+	if err != nil {
+		msg := "Failed verifying caller"
+		myLogger.Error(msg)
+		return Party{}, false, errors.New(msg)
+	}
+	if !ok {
+		return Party{}, false, nil
+	}
+
+	party, err := t.getParty(stub, string(partyID_b))
+	if err != nil {
+		return Party{}, false, nil
+	}
+
+	return party, true, nil
+}
+
+// get caller party and verify it has one of the allowed roles
+func (t *AgrifoodChaincode) assertCallerRole(stub shim.ChaincodeStubInterface, allowedRoles ...string) (Party, error) {
+	var party Party
+	var err error
+	if t.certRoles {
+		party, err = t.getAttributeCallerParty(stub)
+	} else {
+		party, err = t.getCallerParty(stub)
+	}
+	if err != nil {
+		msg := fmt.Sprintf("Error determining party: %s", err)
+		myLogger.Error(msg)
+		return Party{}, errors.New(msg)
+	}
+
+	myLogger.Debugf("Received party: %s, role:%s", party.ID, party.Role)
+
+	for _, role := range allowedRoles {
+		if party.Role == role {
+			return party, nil
+		}
+	}
+
+	msg := fmt.Sprintf("Caller (%s) is no %s", party.ID, strings.Join(allowedRoles, " or "))
+	myLogger.Error(msg)
+	return Party{}, errors.New(msg)
+}
+
+// verify caller is an admin, otherwise get caller party and verify it has one of the allowed roles
+func (t *AgrifoodChaincode) assertAdminOrCallerRole(stub shim.ChaincodeStubInterface, allowedRoles ...string) (Party, bool, error) {
+	isAdmin, err := t.verifyAdmin(stub)
+	if err != nil {
+		msg := "Failed verifying certificates"
+		myLogger.Error(msg)
+		return Party{}, false, errors.New(msg)
+	}
+
+	if isAdmin {
+		return Party{}, true, nil
+	}
+
+	party, err := t.assertCallerRole(stub, allowedRoles...)
+	if err != nil {
+		return Party{}, false, err
+	}
+
+	return party, false, nil
+}
+
 // cet specific signing certificate
 func (t *AgrifoodChaincode) getParty(stub shim.ChaincodeStubInterface, partyID string) (Party, error) {
-	parties, err := t.getParties(stub)
+	party_b, err := stub.GetState(partyKey(partyID))
 	if err != nil {
 		msg := fmt.Sprintf("Error retreiving parties: %s", err)
 		myLogger.Error(msg)
 		return Party{}, errors.New(msg)
 	}
 
-	for _, party := range parties {
-		if party.ID == partyID {
-			return party, nil
-		}
+	if party_b == nil {
+		return Party{}, errors.New("Unable to determine party")
+	}
+
+	var party Party
+	err = json.Unmarshal(party_b, &party)
+	if err != nil {
+		msg := fmt.Sprintf("Error parsing party: %s", err)
+		myLogger.Error(msg)
+		return Party{}, errors.New(msg)
 	}
 
-	return Party{}, errors.New("Unable to determine party")
+	return party, nil
 }
 
 // get all parties
 func (t *AgrifoodChaincode) getParties(stub shim.ChaincodeStubInterface) ([]Party, error) {
 	// get parties
-	parties_b, err := stub.GetState("Parties")
+	values, err := getStateByPrefix(stub, partyKeyPrefix)
 	if err != nil {
 		msg := fmt.Sprintf("Error getting parties from storage: %s", err)
 		myLogger.Error(msg)
 		return nil, errors.New(msg)
 	}
 
-	var parties []Party
-	err = json.Unmarshal(parties_b, &parties)
+	parties := []Party{}
+	for _, party_b := range values {
+		var party Party
+		err = json.Unmarshal(party_b, &party)
+		if err != nil {
+			msg := fmt.Sprintf("Error parsing parties: %s", err)
+			myLogger.Error(msg)
+			return nil, errors.New(msg)
+		}
+		parties = append(parties, party)
+	}
+	return parties, nil
+}
+
+// get ID of party holding certificate, empty if no party holds it
+func (t *AgrifoodChaincode) getCertOwner(stub shim.ChaincodeStubInterface, cert_encoded string) (string, error) {
+	fingerprint, err := certFingerprint(cert_encoded)
+	if err != nil {
+		return "", err
+	}
+
+	partyID_b, err := stub.GetState(certIndexKey(fingerprint))
 	if err != nil {
-		msg := fmt.Sprintf("Error parsing parties: %s", err)
+		msg := fmt.Sprintf("Error retrieving certificate index: %s", err)
 		myLogger.Error(msg)
-		return nil, errors.New(msg)
+		return "", errors.New(msg)
 	}
-	return parties, nil
+
+	return string(partyID_b), nil
+}
+
+// fingerprint (SHA-256) of encoded certificate
+func certFingerprint(cert_encoded string) (string, error) {
+	cert_decoded, err := base64.StdEncoding.DecodeString(cert_encoded)
+	if err != nil {
+		return "", errors.New("Failed decoding cert")
+	}
+
+	hash := sha256.Sum256(cert_decoded)
+	return hex.EncodeToString(hash[:]), nil
 }
 
 // get admin certificates
 func (t *AgrifoodChaincode) getAdminCerts(stub shim.ChaincodeStubInterface) ([]string, error) {
 	// Get current array of admin certs
-	certsStr, err := stub.GetState("AdminCerts")
+	certsStr, err := stub.GetState(adminCertsKey)
 	if err != nil {
 		msg := fmt.Sprintf("Failed getting AdminCerts value: %s", err)
 		myLogger.Errorf(msg)
@@ -2087,6 +7334,10 @@ func (t *AgrifoodChaincode) getAdminCerts(stub shim.ChaincodeStubInterface) ([]s
 
 	// Parse array of certificates
 	var certs = []string{}
+	if len(certsStr) == 0 {
+		return certs, nil
+	}
+
 	err = json.Unmarshal(certsStr, &certs)
 
 	if err != nil {
@@ -2111,6 +7362,40 @@ func (t *AgrifoodChaincode) verifyAdmin(stub shim.ChaincodeStubInterface) (bool,
 	return t.verifyCaller(stub, certs)
 }
 
+// get certificates of role admins per role
+func (t *AgrifoodChaincode) getRoleAdmins(stub shim.ChaincodeStubInterface) (map[string][]string, error) {
+	roleAdmins_b, err := stub.GetState(roleAdminsKey)
+	if err != nil {
+		msg := fmt.Sprintf("Error getting RoleAdmins from storage: %s", err)
+		myLogger.Error(msg)
+		return nil, errors.New(msg)
+	}
+
+	roleAdmins := make(map[string][]string)
+	if len(roleAdmins_b) == 0 {
+		return roleAdmins, nil
+	}
+
+	err = json.Unmarshal(roleAdmins_b, &roleAdmins)
+	if err != nil {
+		msg := "Error parsing RoleAdmins"
+		myLogger.Error(msg)
+		return nil, errors.New(msg)
+	}
+
+	return roleAdmins, nil
+}
+
+// verify caller is admin of role
+func (t *AgrifoodChaincode) verifyRoleAdmin(stub shim.ChaincodeStubInterface, role string) (bool, error) {
+	roleAdmins, err := t.getRoleAdmins(stub)
+	if err != nil {
+		return false, err
+	}
+
+	return t.verifyCaller(stub, roleAdmins[role])
+}
+
 // verify caller
 func (t *AgrifoodChaincode) verifyCaller(stub shim.ChaincodeStubInterface, certs []string) (bool, error) {
 	// check all identities in array
@@ -0,0 +1,3442 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/ptypes/timestamp"
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+)
+
+// transaction time of the tests
+var testNow = time.Date(2017, 3, 1, 12, 0, 0, 0, time.UTC)
+
+// MockStub with a caller, transaction time and events, which the v0.6 MockStub leaves out.
+// A caller is identified by its certificate, it signs by presenting that certificate as metadata.
+type testStub struct {
+	*shim.MockStub
+	cc      *AgrifoodChaincode
+	caller  []byte            // certificate of the caller
+	attrs   map[string]string // attributes of the caller certificate
+	now     time.Time         // transaction time
+	txs     int
+	event   string // event set by the last transaction
+	payload []byte
+	failKey string   // PutState of this key fails
+	written []string // keys written by the last transaction
+
+	verifications int // signatures verified
+}
+
+func (s *testStub) GetCallerCertificate() ([]byte, error) { return s.caller, nil }
+func (s *testStub) GetCallerMetadata() ([]byte, error)    { return s.caller, nil }
+func (s *testStub) GetPayload() ([]byte, error)           { return []byte{}, nil }
+func (s *testStub) GetBinding() ([]byte, error)           { return []byte{}, nil }
+
+func (s *testStub) VerifySignature(certificate, signature, message []byte) (bool, error) {
+	s.verifications++
+	return len(certificate) > 0 && bytes.Equal(certificate, signature), nil
+}
+
+func (s *testStub) ReadCertAttribute(attributeName string) ([]byte, error) {
+	value, ok := s.attrs[attributeName]
+	if !ok {
+		return nil, errors.New("attribute not found: " + attributeName)
+	}
+	return []byte(value), nil
+}
+
+func (s *testStub) GetTxTimestamp() (*timestamp.Timestamp, error) {
+	return &timestamp.Timestamp{Seconds: s.now.Unix(), Nanos: int32(s.now.Nanosecond())}, nil
+}
+
+func (s *testStub) SetEvent(name string, payload []byte) error {
+	s.event, s.payload = name, payload
+	return nil
+}
+
+func (s *testStub) PutState(key string, value []byte) error {
+	if s.failKey != "" && key == s.failKey {
+		return errors.New("mock failure")
+	}
+	s.written = append(s.written, key)
+	return s.MockStub.PutState(key, value)
+}
+
+// run a transaction as caller
+func (s *testStub) transact(caller string, f func() ([]byte, error)) ([]byte, error) {
+	s.txs++
+	txid := fmt.Sprintf("tx%d", s.txs)
+	s.caller = []byte(caller)
+	s.event, s.payload, s.written = "", nil, nil
+	s.MockTransactionStart(txid)
+	defer s.MockTransactionEnd(txid)
+	return f()
+}
+
+func (s *testStub) init(args ...string) ([]byte, error) {
+	return s.transact("admin", func() ([]byte, error) { return s.cc.Init(s, "init", args) })
+}
+
+func (s *testStub) invoke(caller string, function string, args ...string) ([]byte, error) {
+	return s.transact(caller, func() ([]byte, error) { return s.cc.Invoke(s, function, args) })
+}
+
+func (s *testStub) query(caller string, function string, args ...string) ([]byte, error) {
+	s.caller = []byte(caller)
+	return s.cc.Query(s, function, args)
+}
+
+// new chaincode with an admin and no parties
+func newTestStub(t testing.TB) *testStub {
+	cc := new(AgrifoodChaincode)
+	s := &testStub{MockStub: shim.NewMockStub("agrifood", cc), cc: cc, now: testNow}
+	_, err := s.init(encodeCert("admin"))
+	if err != nil {
+		t.Fatalf("Init failed: %s", err)
+	}
+	return s
+}
+
+// new chaincode with one party per role, each party signs with a certificate named after it
+func newTestNetwork(t *testing.T) *testStub {
+	s := newTestStub(t)
+	parties := [][]string{
+		{"ab", "AccreditationBody"},
+		{"ab2", "AccreditationBody"},
+		{"cb", "CertificationBody"},
+		{"cb2", "CertificationBody"},
+		{"farm", "Farm"},
+		{"farm2", "Farm"},
+		{"auditor", "Auditor"},
+		{"trader", "Trader"},
+		{"winery", "Winery"},
+	}
+	for _, party := range parties {
+		mustInvoke(t, s, "admin", "add_party", party[0], party[1], encodeCert(party[0]))
+	}
+	return s
+}
+
+// add accreditation issued to cb, authorizing farm to certify with it
+func accredit(t *testing.T, s *testStub, id string, args ...string) {
+	mustInvoke(t, s, "ab", "add_signing_accreditation", append([]string{id, "Organic", ts(-time.Hour), ts(365 * 24 * time.Hour)}, args...)...)
+	mustInvoke(t, s, "ab", "issue_signing_accreditation", id, "cb")
+	mustInvoke(t, s, "cb", "grant_signing_authority", id, "farm", ts(180*24*time.Hour))
+}
+
+func encodeCert(name string) string {
+	return base64.StdEncoding.EncodeToString([]byte(name))
+}
+
+// canonical RFC4122 UUID numbered n
+func testUUID(n int) string {
+	return fmt.Sprintf("00000000-0000-4000-8000-%012d", n)
+}
+
+// timestamp d from the transaction time
+func ts(d time.Duration) string {
+	return testNow.Add(d).Format(time.RFC3339)
+}
+
+func mustInvoke(t testing.TB, s *testStub, caller string, function string, args ...string) []byte {
+	result, err := s.invoke(caller, function, args...)
+	if err != nil {
+		t.Fatalf("%s by %s failed: %s", function, caller, err)
+	}
+	return result
+}
+
+func mustQuery(t *testing.T, s *testStub, function string, args ...string) []byte {
+	result, err := s.query("", function, args...)
+	if err != nil {
+		t.Fatalf("%s failed: %s", function, err)
+	}
+	return result
+}
+
+func mustAdminQuery(t *testing.T, s *testStub, function string, args ...string) []byte {
+	result, err := s.query("admin", function, args...)
+	if err != nil {
+		t.Fatalf("%s failed: %s", function, err)
+	}
+	return result
+}
+
+func expectError(t *testing.T, err error, contains string) {
+	if err == nil {
+		t.Fatalf("expected error containing %q, got none", contains)
+	}
+	if !strings.Contains(err.Error(), contains) {
+		t.Fatalf("expected error containing %q, got %q", contains, err)
+	}
+}
+
+func getTestGrapes(t *testing.T, s *testStub, uuid string) ProduceUnit {
+	unit, err := s.cc.getGrapesUnit(s, uuid)
+	if err != nil {
+		t.Fatalf("Error retrieving grapes: %s", err)
+	}
+	return unit
+}
+
+func getTestAccreditation(t *testing.T, s *testStub, id string) SigningAccreditation {
+	accreditation, err := s.cc.getSigningAccreditation(s, id)
+	if err != nil {
+		t.Fatalf("Error retrieving accreditation: %s", err)
+	}
+	return accreditation
+}
+
+// change a stored party, as the admin functions of later versions would
+func updateTestParty(t *testing.T, s *testStub, id string, update func(*Party)) {
+	party, err := s.cc.getParty(s, id)
+	if err != nil {
+		t.Fatalf("Error retrieving party: %s", err)
+	}
+	update(&party)
+	_, err = s.transact("admin", func() ([]byte, error) { return nil, s.cc.saveParty(s, party, false) })
+	if err != nil {
+		t.Fatalf("Error saving party: %s", err)
+	}
+}
+
+func TestCertifyInScope(t *testing.T) {
+	s := newTestNetwork(t)
+	accredit(t, s, "accr", `["grapes"]`)
+	mustInvoke(t, s, "farm", "create_grapes", testUUID(1), ts(0), "100")
+
+	mustInvoke(t, s, "farm", "certify_grapes", testUUID(1), "accr", ts(0))
+
+	if n := len(getTestGrapes(t, s, testUUID(1)).AccreditationSignatures); n != 1 {
+		t.Fatalf("expected 1 signature, got %d", n)
+	}
+}
+
+func TestCertifyOutOfScope(t *testing.T) {
+	s := newTestNetwork(t)
+	accredit(t, s, "accr", `["olives"]`)
+	mustInvoke(t, s, "farm", "create_grapes", testUUID(1), ts(0), "100")
+
+	_, err := s.invoke("farm", "certify_grapes", testUUID(1), "accr", ts(0))
+	expectError(t, err, "not within scope")
+
+	if n := len(getTestGrapes(t, s, testUUID(1)).AccreditationSignatures); n != 0 {
+		t.Fatalf("expected no signatures, got %d", n)
+	}
+}
+
+func TestSetAccreditationScope(t *testing.T) {
+	s := newTestNetwork(t)
+	accredit(t, s, "accr", `["olives"]`)
+	mustInvoke(t, s, "farm", "create_grapes", testUUID(1), ts(0), "100")
+
+	_, err := s.invoke("ab2", "set_accreditation_scope", "accr", `["grapes"]`)
+	expectError(t, err, "is not the issuer")
+
+	mustInvoke(t, s, "ab", "set_accreditation_scope", "accr", `["olives","grapes"]`)
+	mustInvoke(t, s, "farm", "certify_grapes", testUUID(1), "accr", ts(0))
+}
+
+// trust path of the signature of farm on grapes certified under accr
+func trustPath(t *testing.T, s *testStub) []TrustPathNode {
+	var path []TrustPathNode
+	err := json.Unmarshal(mustQuery(t, s, "signature_trust_path", testUUID(1), "accr"), &path)
+	if err != nil {
+		t.Fatalf("Error parsing trust path: %s", err)
+	}
+	return path
+}
+
+func TestSignatureTrustPathComplete(t *testing.T) {
+	s := newTestNetwork(t)
+	accredit(t, s, "accr")
+	mustInvoke(t, s, "farm", "create_grapes", testUUID(1), ts(0), "100")
+	mustInvoke(t, s, "farm", "certify_grapes", testUUID(1), "accr", ts(0))
+
+	path := trustPath(t, s)
+	ids := []string{"ab", "accr", "cb", "accr/farm", "farm"}
+	if len(path) != len(ids) {
+		t.Fatalf("expected %d nodes, got %d", len(ids), len(path))
+	}
+	for i, node := range path {
+		if node.ID != ids[i] || !node.Valid {
+			t.Fatalf("expected valid node %s, got %+v", ids[i], node)
+		}
+	}
+}
+
+func TestSignatureTrustPathBrokenAtCertificationBody(t *testing.T) {
+	s := newTestNetwork(t)
+	accredit(t, s, "accr")
+	mustInvoke(t, s, "farm", "create_grapes", testUUID(1), ts(0), "100")
+	mustInvoke(t, s, "farm", "certify_grapes", testUUID(1), "accr", ts(0))
+	updateTestParty(t, s, "cb", func(party *Party) { party.Role = "Auditor" })
+
+	path := trustPath(t, s)
+	if path[2].Valid || path[2].Reason != "Party is no CertificationBody but Auditor" {
+		t.Fatalf("expected invalid certification body, got %+v", path[2])
+	}
+	if !path[0].Valid || !path[4].Valid {
+		t.Fatalf("expected the rest of the path to stay valid, got %+v", path)
+	}
+}
+
+func TestRevokeSigningAuthorityByGrantor(t *testing.T) {
+	s := newTestNetwork(t)
+	accredit(t, s, "accr")
+
+	_, err := s.invoke("cb2", "revoke_signing_authority", "accr", "farm", ts(0))
+	expectError(t, err, "is not the grantor")
+
+	mustInvoke(t, s, "cb", "revoke_signing_authority", "accr", "farm", ts(0))
+	auth, err := s.cc.getSigningAuthorization(s, "accr", "farm")
+	if err != nil || !auth.Revoked {
+		t.Fatalf("expected revoked authorization, got %+v (%v)", auth, err)
+	}
+}
+
+func TestRevokeSigningAuthorityByAuditor(t *testing.T) {
+	s := newTestNetwork(t)
+	accredit(t, s, "accr")
+
+	mustInvoke(t, s, "auditor", "revoke_signing_authority", "accr", "farm", ts(0))
+	auth, err := s.cc.getSigningAuthorization(s, "accr", "farm")
+	if err != nil || !auth.Revoked {
+		t.Fatalf("expected revoked authorization, got %+v (%v)", auth, err)
+	}
+}
+
+func TestRevokeExpiredAccreditations(t *testing.T) {
+	s := newTestNetwork(t)
+	// accreditations can only be added before they expire
+	s.now = testNow.Add(-48 * time.Hour)
+	mustInvoke(t, s, "ab", "add_signing_accreditation", "expired", "Organic", ts(-48*time.Hour), ts(-24*time.Hour))
+	s.now = testNow
+	mustInvoke(t, s, "ab", "add_signing_accreditation", "active", "Organic", ts(-48*time.Hour), ts(24*time.Hour))
+
+	_, err := s.invoke("farm", "revoke_expired_accreditations", ts(0))
+	expectError(t, err, "Caller (farm) is no Auditor")
+
+	mustInvoke(t, s, "auditor", "revoke_expired_accreditations", ts(0))
+
+	if expired := getTestAccreditation(t, s, "expired"); !expired.Revoked || expired.RevocationTimestamp.Format(time.RFC3339) != ts(0) {
+		t.Fatalf("expected expired accreditation to be revoked at %s, got %+v", ts(0), expired)
+	}
+	if active := getTestAccreditation(t, s, "active"); active.Revoked {
+		t.Fatalf("expected active accreditation to be untouched, got %+v", active)
+	}
+
+	mustInvoke(t, s, "admin", "revoke_expired_accreditations", ts(0))
+}
+
+// UUIDs of a JSON array of grape units
+func grapesUUIDs(t *testing.T, grapes_b []byte) []string {
+	var grapes []struct{ UUID string }
+	err := json.Unmarshal(grapes_b, &grapes)
+	if err != nil {
+		t.Fatalf("Error parsing grapes: %s", err)
+	}
+	uuids := []string{}
+	for _, unit := range grapes {
+		uuids = append(uuids, unit.UUID)
+	}
+	return uuids
+}
+
+func TestNonFarmGrapes(t *testing.T) {
+	s := newTestNetwork(t)
+	mustInvoke(t, s, "farm", "create_grapes", testUUID(1), ts(0), "100")
+	mustInvoke(t, s, "farm2", "create_grapes", testUUID(2), ts(0), "100")
+
+	if uuids := grapesUUIDs(t, mustQuery(t, s, "get_non_farm_grapes")); len(uuids) != 0 {
+		t.Fatalf("expected no grapes, got %v", uuids)
+	}
+
+	updateTestParty(t, s, "farm2", func(party *Party) { party.Role = "Trader" })
+
+	if uuids := grapesUUIDs(t, mustQuery(t, s, "get_non_farm_grapes")); len(uuids) != 1 || uuids[0] != testUUID(2) {
+		t.Fatalf("expected grapes of farm2, got %v", uuids)
+	}
+}
+
+func getTestCompliance(t *testing.T, s *testStub, uuid string) Compliance {
+	var compliance Compliance
+	err := json.Unmarshal(mustQuery(t, s, "compliant", uuid), &compliance)
+	if err != nil {
+		t.Fatalf("Error parsing compliance: %s", err)
+	}
+	return compliance
+}
+
+func TestMinimumSignaturesNotMet(t *testing.T) {
+	s := newTestNetwork(t)
+	accredit(t, s, "accr")
+	mustInvoke(t, s, "admin", "set_min_signatures", "2")
+	mustInvoke(t, s, "farm", "create_grapes", testUUID(1), ts(0), "100")
+	mustInvoke(t, s, "farm", "certify_grapes", testUUID(1), "accr", ts(0))
+
+	if compliance := getTestCompliance(t, s, testUUID(1)); compliance.Compliant || compliance.ValidSignatures != 1 {
+		t.Fatalf("expected 1 of 2 signatures, got %+v", compliance)
+	}
+
+	_, err := s.invoke("farm", "transfer_grapes", testUUID(1), "trader", ts(time.Minute))
+	expectError(t, err, "2 required before first transfer")
+}
+
+func TestMinimumSignaturesMet(t *testing.T) {
+	s := newTestNetwork(t)
+	accredit(t, s, "accr")
+	accredit(t, s, "accr2")
+	mustInvoke(t, s, "admin", "set_min_signatures", "2")
+	mustInvoke(t, s, "farm", "create_grapes", testUUID(1), ts(0), "100")
+	mustInvoke(t, s, "farm", "certify_grapes", testUUID(1), "accr", ts(0))
+	mustInvoke(t, s, "farm", "certify_grapes", testUUID(1), "accr2", ts(0))
+
+	if compliance := getTestCompliance(t, s, testUUID(1)); !compliance.Compliant || compliance.ValidSignatures != 2 {
+		t.Fatalf("expected 2 of 2 signatures, got %+v", compliance)
+	}
+
+	mustInvoke(t, s, "farm", "transfer_grapes", testUUID(1), "trader", ts(time.Minute))
+}
+
+func TestRetiredGrapesCannotBeTransferred(t *testing.T) {
+	s := newTestNetwork(t)
+	mustInvoke(t, s, "farm", "create_grapes", testUUID(1), ts(0), "100")
+
+	_, err := s.invoke("trader", "retire_grapes", testUUID(1), statusConsumed, ts(time.Minute))
+	expectError(t, err, "not the current owner")
+
+	mustInvoke(t, s, "farm", "retire_grapes", testUUID(1), statusConsumed, ts(time.Minute))
+	if status := getTestGrapes(t, s, testUUID(1)).Status; status != statusConsumed {
+		t.Fatalf("expected status %s, got %s", statusConsumed, status)
+	}
+
+	_, err = s.invoke("farm", "transfer_grapes", testUUID(1), "trader", ts(2*time.Minute))
+	expectError(t, err, "are consumed")
+
+	_, err = s.invoke("farm", "retire_grapes", testUUID(1), statusDestroyed, ts(2*time.Minute))
+	expectError(t, err, "already consumed")
+}
+
+func TestRetiredGrapesCannotBeCertified(t *testing.T) {
+	s := newTestNetwork(t)
+	accredit(t, s, "accr")
+	mustInvoke(t, s, "farm", "create_grapes", testUUID(1), ts(0), "100")
+	mustInvoke(t, s, "farm", "retire_grapes", testUUID(1), statusDestroyed, ts(time.Minute))
+
+	_, err := s.invoke("farm", "certify_grapes", testUUID(1), "accr", ts(2*time.Minute))
+	expectError(t, err, "are destroyed")
+}
+
+func TestExpiringOn(t *testing.T) {
+	s := newTestNetwork(t)
+	day := time.Date(testNow.Year(), testNow.Month(), testNow.Day(), 0, 0, 0, 0, time.UTC).AddDate(0, 0, 10)
+	expiries := map[string]time.Time{
+		"before": day.Add(-time.Second),
+		"start":  day,
+		"end":    day.Add(24*time.Hour - time.Second),
+		"after":  day.Add(24 * time.Hour),
+	}
+	for id, expires := range expiries {
+		mustInvoke(t, s, "ab", "add_signing_accreditation", id, "Organic", ts(-time.Hour), expires.Format(time.RFC3339))
+	}
+	mustInvoke(t, s, "ab", "issue_signing_accreditation", "after", "cb")
+	mustInvoke(t, s, "cb", "grant_signing_authority", "after", "farm", day.Add(12*time.Hour).Format(time.RFC3339))
+	mustInvoke(t, s, "cb", "grant_signing_authority", "after", "farm2", day.Add(24*time.Hour).Format(time.RFC3339))
+
+	var expiring ExpiringEntries
+	err := json.Unmarshal(mustQuery(t, s, "expiring_on", day.Add(6*time.Hour).Format(time.RFC3339)), &expiring)
+	if err != nil {
+		t.Fatalf("Error parsing expiring entries: %s", err)
+	}
+
+	ids := map[string]bool{}
+	for _, accreditation := range expiring.Accreditations {
+		ids[accreditation.ID] = true
+	}
+	if len(ids) != 2 || !ids["start"] || !ids["end"] {
+		t.Fatalf("expected accreditations start and end, got %v", ids)
+	}
+	if len(expiring.Authorizations) != 1 || expiring.Authorizations[0].AuthorizedParty != "farm" {
+		t.Fatalf("expected authorization of farm, got %+v", expiring.Authorizations)
+	}
+}
+
+func TestSaveUnknownParty(t *testing.T) {
+	s := newTestNetwork(t)
+
+	_, err := s.transact("admin", func() ([]byte, error) {
+		return nil, s.cc.saveParty(s, Party{ID: "unknown", Role: "Farm"}, false)
+	})
+	expectError(t, err, "Party unknown does not exist")
+
+	if _, err := s.cc.getParty(s, "unknown"); err == nil {
+		t.Fatalf("expected unknown party not to be saved")
+	}
+}
+
+func TestSaveKnownParty(t *testing.T) {
+	s := newTestNetwork(t)
+
+	updateTestParty(t, s, "farm", func(party *Party) { party.Role = "Trader" })
+
+	party, err := s.cc.getParty(s, "farm")
+	if err != nil || party.Role != "Trader" {
+		t.Fatalf("expected farm to be a Trader, got %+v (%v)", party, err)
+	}
+}
+
+func TestSignatureAuthorization(t *testing.T) {
+	s := newTestNetwork(t)
+	accredit(t, s, "accr")
+	mustInvoke(t, s, "farm", "create_grapes", testUUID(1), ts(0), "100")
+	mustInvoke(t, s, "farm", "certify_grapes", testUUID(1), "accr", ts(0))
+
+	var auth SigningAuthorization
+	err := json.Unmarshal(mustQuery(t, s, "signature_authorization", testUUID(1), "accr"), &auth)
+	if err != nil {
+		t.Fatalf("Error parsing authorization: %s", err)
+	}
+	if auth.AuthorizedParty != "farm" || auth.CertifyingParty != "cb" || auth.AccreditationID != "accr" {
+		t.Fatalf("unexpected authorization %+v", auth)
+	}
+}
+
+func TestSignatureAuthorizationDeleted(t *testing.T) {
+	s := newTestNetwork(t)
+	accredit(t, s, "accr")
+	mustInvoke(t, s, "farm", "create_grapes", testUUID(1), ts(0), "100")
+	mustInvoke(t, s, "farm", "certify_grapes", testUUID(1), "accr", ts(0))
+	s.transact("admin", func() ([]byte, error) { return nil, s.PutState("SigningAuthorizations", []byte("[]")) })
+
+	_, err := s.query("", "signature_authorization", testUUID(1), "accr")
+	expectError(t, err, "Error retrieving authorization of farm on accr")
+}
+
+// accreditation and authorization of farm expiring an hour from the transaction time, with grapes to certify
+func expiringAccreditation(t *testing.T) *testStub {
+	s := newTestNetwork(t)
+	mustInvoke(t, s, "ab", "add_signing_accreditation", "accr", "Organic", ts(-time.Hour), ts(time.Hour))
+	mustInvoke(t, s, "ab", "issue_signing_accreditation", "accr", "cb")
+	mustInvoke(t, s, "cb", "grant_signing_authority", "accr", "farm", ts(time.Hour))
+	mustInvoke(t, s, "farm", "create_grapes", testUUID(1), ts(0), "100")
+	return s
+}
+
+func TestCertifyWithinClockSkew(t *testing.T) {
+	s := expiringAccreditation(t)
+	s.now = testNow.Add(time.Hour + clockSkew - time.Second)
+
+	mustInvoke(t, s, "farm", "certify_grapes", testUUID(1), "accr", s.now.Format(time.RFC3339))
+}
+
+func TestCertifyBeyondClockSkew(t *testing.T) {
+	s := expiringAccreditation(t)
+	s.now = testNow.Add(time.Hour + clockSkew + time.Second)
+
+	_, err := s.invoke("farm", "certify_grapes", testUUID(1), "accr", s.now.Format(time.RFC3339))
+	expectError(t, err, "expired")
+}
+
+func TestCertifiableGrapes(t *testing.T) {
+	s := newTestNetwork(t)
+	accredit(t, s, "accr", `["grapes"]`)
+	mustInvoke(t, s, "farm", "create_grapes", testUUID(1), ts(0), "100")
+	mustInvoke(t, s, "farm", "create_grapes", testUUID(2), ts(0), "100", "olives")
+	mustInvoke(t, s, "farm", "create_grapes", testUUID(3), ts(0), "100")
+	mustInvoke(t, s, "farm", "certify_grapes", testUUID(3), "accr", ts(0))
+	mustInvoke(t, s, "farm2", "create_grapes", testUUID(4), ts(0), "100")
+
+	if uuids := grapesUUIDs(t, mustQuery(t, s, "certifiable_grapes", "farm")); len(uuids) != 1 || uuids[0] != testUUID(1) {
+		t.Fatalf("expected only uncertified in-scope grapes of farm, got %v", uuids)
+	}
+
+	// farm2 has no authorization
+	if uuids := grapesUUIDs(t, mustQuery(t, s, "certifiable_grapes", "farm2")); len(uuids) != 0 {
+		t.Fatalf("expected no grapes certifiable by farm2, got %v", uuids)
+	}
+}
+
+func TestAddCertHeldByAnotherParty(t *testing.T) {
+	s := newTestNetwork(t)
+
+	_, err := s.invoke("farm2", "add_cert", encodeCert("farm"))
+	expectError(t, err, "already registered to another party")
+
+	// the same certificate in another encoding has the same fingerprint
+	cert := encodeCert("farm")
+	_, err = s.invoke("farm2", "add_cert", cert[:4]+"\n"+cert[4:])
+	expectError(t, err, "already registered to another party")
+
+	mustInvoke(t, s, "farm2", "add_cert", encodeCert("farm2-tcert"))
+	if owner, err := s.cc.getCertOwner(s, encodeCert("farm2-tcert")); err != nil || owner != "farm2" {
+		t.Fatalf("expected farm2 to own the new certificate, got %q (%v)", owner, err)
+	}
+}
+
+func TestGrapeTimelineInterleaves(t *testing.T) {
+	s := newTestNetwork(t)
+	accredit(t, s, "accr")
+	mustInvoke(t, s, "farm", "create_grapes", testUUID(1), ts(0), "100")
+	mustInvoke(t, s, "farm", "transfer_grapes", testUUID(1), "trader", ts(2*time.Minute))
+	mustInvoke(t, s, "farm", "certify_grapes", testUUID(1), "accr", ts(time.Minute))
+
+	var timeline []TimelineEvent
+	if err := json.Unmarshal(mustQuery(t, s, "grape_timeline", testUUID(1)), &timeline); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{"created", "certified", "transferred"}
+	if len(timeline) != len(expected) {
+		t.Fatalf("expected %d events, got %+v", len(expected), timeline)
+	}
+	for i, event := range timeline {
+		if event.Type != expected[i] {
+			t.Fatalf("expected event %d to be %s, got %+v", i, expected[i], timeline)
+		}
+	}
+	if timeline[2].PartyID != "trader" {
+		t.Fatalf("expected transfer to trader, got %s", timeline[2].PartyID)
+	}
+}
+
+func grantBatch(t *testing.T, s *testStub, partyIDs string) []BatchResult {
+	var results []BatchResult
+	if err := json.Unmarshal(mustInvoke(t, s, "cb", "grant_signing_authority_batch", "accr", partyIDs, ts(180*24*time.Hour)), &results); err != nil {
+		t.Fatal(err)
+	}
+	return results
+}
+
+func TestGrantSigningAuthorityBatch(t *testing.T) {
+	s := newTestNetwork(t)
+	mustInvoke(t, s, "ab", "add_signing_accreditation", "accr", "Organic", ts(-time.Hour), ts(365*24*time.Hour))
+	mustInvoke(t, s, "ab", "issue_signing_accreditation", "accr", "cb")
+
+	results := grantBatch(t, s, `["farm","farm2"]`)
+	if len(results) != 2 || !results[0].Success || !results[1].Success {
+		t.Fatalf("expected all grants to succeed, got %+v", results)
+	}
+
+	mustInvoke(t, s, "farm2", "create_grapes", testUUID(1), ts(0), "100")
+	mustInvoke(t, s, "farm2", "certify_grapes", testUUID(1), "accr", ts(0))
+}
+
+func TestGrantSigningAuthorityBatchInvalidRole(t *testing.T) {
+	s := newTestNetwork(t)
+	accredit(t, s, "accr")
+
+	results := grantBatch(t, s, `["farm2","trader"]`)
+	if len(results) != 2 || !results[0].Success || results[1].Success {
+		t.Fatalf("expected only farm2 to be granted, got %+v", results)
+	}
+	if !strings.Contains(results[1].Message, "no Farm") {
+		t.Fatalf("unexpected message for trader: %s", results[1].Message)
+	}
+
+	_, err := s.invoke("cb2", "grant_signing_authority_batch", "accr", `["farm2"]`, ts(time.Hour))
+	expectError(t, err, "not the certification body")
+}
+
+func TestAssertCallerRole(t *testing.T) {
+	s := newTestNetwork(t)
+	tests := []struct {
+		caller string
+		roles  []string
+		err    string
+	}{
+		{"farm", []string{"Farm", "Trader"}, ""},
+		{"trader", []string{"Farm", "Trader"}, ""},
+		{"auditor", []string{"Farm", "Trader"}, "Caller (auditor) is no Farm or Trader"},
+		{"unknown", []string{"Farm"}, "Error determining party"},
+	}
+	for _, test := range tests {
+		s.caller = []byte(test.caller)
+		party, err := s.cc.assertCallerRole(s, test.roles...)
+		if test.err == "" {
+			if err != nil {
+				t.Fatalf("%s: unexpected error: %s", test.caller, err)
+			}
+			if party.ID != test.caller {
+				t.Fatalf("%s: expected party %s, got %s", test.caller, test.caller, party.ID)
+			}
+			continue
+		}
+		expectError(t, err, test.err)
+	}
+}
+
+func TestGrapeDiffAcrossTransfer(t *testing.T) {
+	s := newTestNetwork(t)
+	accredit(t, s, "accr")
+	mustInvoke(t, s, "farm", "create_grapes", testUUID(1), ts(0), "100")
+	s.now = testNow.Add(time.Minute)
+	mustInvoke(t, s, "farm", "certify_grapes", testUUID(1), "accr", ts(time.Minute))
+	s.now = testNow.Add(2 * time.Minute)
+	mustInvoke(t, s, "farm", "transfer_grapes", testUUID(1), "trader", ts(2*time.Minute))
+
+	var diff GrapesDiff
+	if err := json.Unmarshal(mustQuery(t, s, "grape_diff", testUUID(1), ts(30*time.Second), ts(3*time.Minute)), &diff); err != nil {
+		t.Fatal(err)
+	}
+	if diff.OwnerBefore != "farm" || diff.OwnerAfter != "trader" {
+		t.Fatalf("expected owner to change from farm to trader, got %s to %s", diff.OwnerBefore, diff.OwnerAfter)
+	}
+	if len(diff.Transfers) != 1 || len(diff.SignaturesAdded) != 1 || len(diff.SignaturesRevoked) != 0 {
+		t.Fatalf("unexpected diff %+v", diff)
+	}
+
+	// nothing changed after the transfer
+	if err := json.Unmarshal(mustQuery(t, s, "grape_diff", testUUID(1), ts(3*time.Minute), ts(4*time.Minute)), &diff); err != nil {
+		t.Fatal(err)
+	}
+	if diff.OwnerBefore != "trader" || diff.OwnerAfter != "trader" || len(diff.Transfers) != 0 || len(diff.SignaturesAdded) != 0 {
+		t.Fatalf("expected no changes after transfer, got %+v", diff)
+	}
+
+	// grapes did not exist before their creating transaction
+	if err := json.Unmarshal(mustQuery(t, s, "grape_diff", testUUID(1), ts(-time.Minute), ts(90*time.Second)), &diff); err != nil {
+		t.Fatal(err)
+	}
+	if diff.OwnerBefore != "" || diff.OwnerAfter != "farm" || len(diff.Transfers) != 1 || len(diff.SignaturesAdded) != 1 {
+		t.Fatalf("expected creation and certification, got %+v", diff)
+	}
+
+	_, err := s.query("", "grape_diff", testUUID(1), ts(time.Minute), ts(0))
+	expectError(t, err, "needs to be after")
+}
+
+func TestGrapeDiffFollowsTransactionTime(t *testing.T) {
+	s := newTestNetwork(t)
+	mustInvoke(t, s, "farm", "create_grapes", testUUID(1), ts(0), "100")
+
+	// transfer committed at 10m, dated 7m by the caller
+	s.now = testNow.Add(10 * time.Minute)
+	mustInvoke(t, s, "farm", "transfer_grapes", testUUID(1), "trader", ts(7*time.Minute))
+
+	tests := []struct {
+		from, to    time.Duration
+		before      string
+		after       string
+		transferred bool
+	}{
+		{time.Minute, 8 * time.Minute, "farm", "farm", false},
+		{8 * time.Minute, 11 * time.Minute, "farm", "trader", true},
+		{time.Minute, 11 * time.Minute, "farm", "trader", true},
+	}
+	for _, test := range tests {
+		var diff GrapesDiff
+		if err := json.Unmarshal(mustQuery(t, s, "grape_diff", testUUID(1), ts(test.from), ts(test.to)), &diff); err != nil {
+			t.Fatal(err)
+		}
+		if diff.OwnerBefore != test.before || diff.OwnerAfter != test.after {
+			t.Fatalf("%s-%s: expected owner %s to %s, got %s to %s", test.from, test.to, test.before, test.after, diff.OwnerBefore, diff.OwnerAfter)
+		}
+		if transferred := len(diff.Transfers) == 1 && diff.Transfers[0].PartyID == "trader"; transferred != test.transferred {
+			t.Fatalf("%s-%s: expected transfer %v, got %+v", test.from, test.to, test.transferred, diff.Transfers)
+		}
+	}
+}
+
+func TestTransferToInvalidRecipient(t *testing.T) {
+	s := newTestNetwork(t)
+	mustInvoke(t, s, "farm", "create_grapes", testUUID(1), ts(0), "100")
+
+	_, err := s.invoke("farm", "transfer_grapes", testUUID(1), "nobody", ts(time.Minute))
+	expectError(t, err, "unknown party nobody")
+
+	_, err = s.invoke("farm", "transfer_grapes", testUUID(1), "auditor", ts(time.Minute))
+	expectError(t, err, "new party auditor has disallowed role Auditor")
+
+	mustInvoke(t, s, "farm", "transfer_grapes", testUUID(1), "farm2", ts(time.Minute))
+}
+
+func TestTransferShare(t *testing.T) {
+	s := newTestNetwork(t)
+	mustInvoke(t, s, "farm", "create_grapes", testUUID(1), ts(0), "100")
+	mustInvoke(t, s, "farm", "transfer_share", testUUID(1), "trader", "40", ts(time.Minute))
+
+	owners := getTestGrapes(t, s, testUUID(1)).Owners
+	if len(owners) != 2 || owners[0].PartyID != "farm" || owners[0].Percentage != 60 || owners[1].PartyID != "trader" || owners[1].Percentage != 40 {
+		t.Fatalf("unexpected owners %+v", owners)
+	}
+
+	// co-owned grapes can only be handed over share by share
+	_, err := s.invoke("farm", "transfer_grapes", testUUID(1), "farm2", ts(2*time.Minute))
+	expectError(t, err, "not the current owner")
+
+	_, err = s.invoke("trader", "transfer_share", testUUID(1), "farm2", "50", ts(2*time.Minute))
+	expectError(t, err, "Invalid percentage 50")
+
+	// trader becomes sole owner when receiving the remaining share
+	mustInvoke(t, s, "farm", "transfer_share", testUUID(1), "trader", "60", ts(2*time.Minute))
+	grapes := getTestGrapes(t, s, testUUID(1))
+	if len(grapes.Owners) != 0 || grapes.Ownership[len(grapes.Ownership)-1].PartyID != "trader" {
+		t.Fatalf("expected trader to be sole owner, got %+v", grapes.Owners)
+	}
+}
+
+func TestValidateOwnershipShares(t *testing.T) {
+	err := validateOwnershipShares([]OwnershipShare{{PartyID: "farm", Percentage: 60}, {PartyID: "trader", Percentage: 30}})
+	expectError(t, err, "sum up to 90%")
+
+	err = validateOwnershipShares([]OwnershipShare{{PartyID: "farm", Percentage: 110}, {PartyID: "trader", Percentage: -10}})
+	expectError(t, err, "needs to be positive")
+
+	if err = validateOwnershipShares([]OwnershipShare{{PartyID: "farm", Percentage: 60}, {PartyID: "trader", Percentage: 40}}); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+}
+
+func TestLeadTimeStats(t *testing.T) {
+	s := newTestNetwork(t)
+	mustInvoke(t, s, "farm", "create_grapes", testUUID(1), ts(0), "100")
+	mustInvoke(t, s, "farm", "create_grapes", testUUID(2), ts(0), "100")
+	mustInvoke(t, s, "farm", "create_grapes", testUUID(3), ts(0), "100")
+	mustInvoke(t, s, "farm2", "create_grapes", testUUID(4), ts(0), "100")
+	s.now = testNow.Add(time.Hour)
+	mustInvoke(t, s, "farm", "transfer_grapes", testUUID(1), "trader", ts(time.Hour))
+	s.now = testNow.Add(3 * time.Hour)
+	mustInvoke(t, s, "farm", "transfer_grapes", testUUID(2), "trader", ts(3*time.Hour))
+	s.now = testNow.Add(5 * time.Hour)
+	mustInvoke(t, s, "farm2", "transfer_share", testUUID(4), "trader", "50", ts(5*time.Hour))
+
+	tests := []struct {
+		args  []string
+		stats LeadTimeStats
+	}{
+		{nil, LeadTimeStats{Count: 3, MinSeconds: 3600, AvgSeconds: 10800, MaxSeconds: 18000}},
+		{[]string{"farm"}, LeadTimeStats{Count: 2, MinSeconds: 3600, AvgSeconds: 7200, MaxSeconds: 10800}},
+		{[]string{"trader"}, LeadTimeStats{}},
+	}
+	for _, test := range tests {
+		var stats LeadTimeStats
+		if err := json.Unmarshal(mustQuery(t, s, "lead_time_stats", test.args...), &stats); err != nil {
+			t.Fatal(err)
+		}
+		if stats != test.stats {
+			t.Fatalf("%v: expected %+v, got %+v", test.args, test.stats, stats)
+		}
+	}
+}
+
+func TestIssueAccreditationToDeactivatedCertificationBody(t *testing.T) {
+	s := newTestNetwork(t)
+	updateTestParty(t, s, "cb2", func(party *Party) { party.Deactivated = true })
+	mustInvoke(t, s, "ab", "add_signing_accreditation", "accr", "Organic", ts(-time.Hour), ts(365*24*time.Hour))
+
+	_, err := s.invoke("ab", "issue_signing_accreditation", "accr", "cb2")
+	expectError(t, err, "CertificationBody cb2 is deactivated")
+
+	mustInvoke(t, s, "ab", "issue_signing_accreditation", "accr", "cb")
+	if cb := getTestAccreditation(t, s, "accr").CertificationBody; cb != "cb" {
+		t.Fatalf("expected accreditation issued to cb, got %s", cb)
+	}
+}
+
+func TestCertifiedQuantityCap(t *testing.T) {
+	s := newTestNetwork(t)
+	accredit(t, s, "accr", "", "250")
+	mustInvoke(t, s, "farm", "create_grapes", testUUID(1), ts(0), "100")
+	mustInvoke(t, s, "farm", "create_grapes", testUUID(2), ts(0), "150")
+	mustInvoke(t, s, "farm", "create_grapes", testUUID(3), ts(0), "1")
+
+	mustInvoke(t, s, "farm", "certify_grapes", testUUID(1), "accr", ts(0))
+	mustInvoke(t, s, "farm", "certify_grapes", testUUID(2), "accr", ts(0))
+	if certified := getTestAccreditation(t, s, "accr").CertifiedQuantity; certified != 250 {
+		t.Fatalf("expected 250 certified, got %d", certified)
+	}
+
+	_, err := s.invoke("farm", "certify_grapes", testUUID(3), "accr", ts(0))
+	expectError(t, err, "exceeds maximum quantity of accreditation accr (250 of 250 certified)")
+
+	_, err = s.invoke("ab", "add_signing_accreditation", "accr2", "Organic", ts(-time.Hour), ts(time.Hour), "", "-1")
+	expectError(t, err, "Invalid max quantity")
+}
+
+func TestValidateAuthorizations(t *testing.T) {
+	s := newTestNetwork(t)
+	accredit(t, s, "accr")
+	for _, auth := range []SigningAuthorization{
+		{AuthorizedParty: "trader", CertifyingParty: "cb", AccreditationID: "accr"},
+		{AuthorizedParty: "nobody", CertifyingParty: "cb", AccreditationID: "unknown"},
+	} {
+		auth := auth
+		_, err := s.transact("admin", func() ([]byte, error) { return nil, s.cc.saveSigningAuthorization(s, auth, true) })
+		if err != nil {
+			t.Fatalf("Error saving authorization: %s", err)
+		}
+	}
+
+	_, err := s.query("farm", "validate_authorizations")
+	expectError(t, err, "not an admin")
+
+	var problems []AuthorizationProblem
+	if err = json.Unmarshal(mustAdminQuery(t, s, "validate_authorizations"), &problems); err != nil {
+		t.Fatal(err)
+	}
+	if len(problems) != 2 {
+		t.Fatalf("expected 2 inconsistent authorizations, got %+v", problems)
+	}
+	if problems[0].Authorization.AuthorizedParty != "trader" || len(problems[0].Problems) != 1 || !strings.Contains(problems[0].Problems[0], "is no Farm") {
+		t.Fatalf("unexpected problem %+v", problems[0])
+	}
+	if problems[1].Authorization.AuthorizedParty != "nobody" || len(problems[1].Problems) != 2 {
+		t.Fatalf("unexpected problem %+v", problems[1])
+	}
+}
+
+func stateSizes(t *testing.T, s *testStub) map[string]StateSize {
+	var sizes []StateSize
+	if err := json.Unmarshal(mustAdminQuery(t, s, "state_sizes"), &sizes); err != nil {
+		t.Fatal(err)
+	}
+	result := make(map[string]StateSize)
+	for _, size := range sizes {
+		result[size.Key] = size
+	}
+	return result
+}
+
+func TestStateSizes(t *testing.T) {
+	s := newTestNetwork(t)
+	_, err := s.query("farm", "state_sizes")
+	expectError(t, err, "not an admin")
+
+	before := stateSizes(t, s)
+	if before[partyKeyPrefix].Count != 9 || before[grapesKeyPrefix].Count != 0 {
+		t.Fatalf("unexpected sizes %+v", before)
+	}
+
+	mustInvoke(t, s, "farm", "create_grapes", testUUID(1), ts(0), "100")
+	mustInvoke(t, s, "farm", "create_grapes", testUUID(2), ts(0), "100")
+	after := stateSizes(t, s)
+	if after[grapesKeyPrefix].Count != 2 || after[grapesKeyPrefix].Bytes <= before[grapesKeyPrefix].Bytes {
+		t.Fatalf("expected grapes to grow, got %+v before %+v", after[grapesKeyPrefix], before[grapesKeyPrefix])
+	}
+	if after[partyKeyPrefix] != before[partyKeyPrefix] {
+		t.Fatalf("expected parties unchanged, got %+v", after[partyKeyPrefix])
+	}
+}
+
+func TestFlagTransferFraudulent(t *testing.T) {
+	s := newTestNetwork(t)
+	mustInvoke(t, s, "farm", "create_grapes", testUUID(1), ts(0), "100")
+	mustInvoke(t, s, "farm", "transfer_grapes", testUUID(1), "trader", ts(time.Minute))
+
+	_, err := s.invoke("farm", "flag_transfer_fraudulent", testUUID(1), "1", "stolen")
+	expectError(t, err, "Caller (farm) is no Auditor")
+
+	_, err = s.invoke("auditor", "flag_transfer_fraudulent", testUUID(1), "0", "stolen")
+	expectError(t, err, "Invalid ownership entry index: 0")
+
+	mustInvoke(t, s, "auditor", "flag_transfer_fraudulent", testUUID(1), "1", "stolen", ts(2*time.Minute))
+	ownership := getTestGrapes(t, s, testUUID(1)).Ownership
+	if len(ownership) != 3 {
+		t.Fatalf("expected compensating entry, got %+v", ownership)
+	}
+	if !ownership[1].Fraudulent || ownership[1].FraudReason != "stolen" || ownership[1].PartyID != "trader" {
+		t.Fatalf("expected flagged transfer to trader, got %+v", ownership[1])
+	}
+	if !ownership[2].Compensating || ownership[2].PartyID != "farm" {
+		t.Fatalf("expected ownership restored to farm, got %+v", ownership[2])
+	}
+
+	_, err = s.invoke("auditor", "flag_transfer_fraudulent", testUUID(1), "1", "stolen")
+	expectError(t, err, "already flagged as fraudulent")
+}
+
+func TestIsCurrentOwner(t *testing.T) {
+	s := newTestNetwork(t)
+	mustInvoke(t, s, "farm", "create_grapes", testUUID(1), ts(0), "100")
+	mustInvoke(t, s, "farm", "transfer_grapes", testUUID(1), "trader", ts(time.Minute))
+
+	for party, expected := range map[string]string{"trader": "true", "farm": "false", "farm2": "false"} {
+		if owner := string(mustQuery(t, s, "is_current_owner", testUUID(1), party)); owner != expected {
+			t.Fatalf("expected is_current_owner %s for %s, got %s", expected, party, owner)
+		}
+	}
+}
+
+func TestRetiredUUIDCannotBeReused(t *testing.T) {
+	s := newTestNetwork(t)
+	mustInvoke(t, s, "farm", "create_grapes", testUUID(1), ts(0), "100")
+	mustInvoke(t, s, "farm", "retire_grapes", testUUID(1), statusDestroyed, ts(time.Minute))
+
+	_, err := s.invoke("farm", "create_grapes", testUUID(1), ts(2*time.Minute), "100")
+	expectError(t, err, "Error saving")
+
+	grapes := getTestGrapes(t, s, testUUID(1))
+	_, err = s.transact("farm", func() ([]byte, error) { return nil, s.cc.saveGrapeUnit(s, grapes, true) })
+	expectError(t, err, "belongs to destroyed grapes")
+}
+
+func TestCurrentCertifications(t *testing.T) {
+	s := newTestNetwork(t)
+	accredit(t, s, "accr")
+	accredit(t, s, "revoked")
+	mustInvoke(t, s, "ab", "add_signing_accreditation", "expiring", "Organic", ts(-time.Hour), ts(time.Hour))
+	mustInvoke(t, s, "ab", "issue_signing_accreditation", "expiring", "cb")
+	mustInvoke(t, s, "cb", "grant_signing_authority", "expiring", "farm", ts(time.Hour))
+
+	mustInvoke(t, s, "farm", "create_grapes", testUUID(1), ts(0), "100")
+	for _, accr := range []string{"accr", "revoked", "expiring"} {
+		mustInvoke(t, s, "farm", "certify_grapes", testUUID(1), accr, ts(0))
+	}
+	mustInvoke(t, s, "ab", "revoke_signing_accreditation", "revoked", ts(time.Minute))
+
+	current := func() []string {
+		var signatures []AccreditationSignature
+		if err := json.Unmarshal(mustQuery(t, s, "current_certifications", testUUID(1)), &signatures); err != nil {
+			t.Fatal(err)
+		}
+		var ids []string
+		for _, signature := range signatures {
+			ids = append(ids, signature.AccreditationID)
+		}
+		return ids
+	}
+
+	if ids := current(); len(ids) != 2 || ids[0] != "accr" || ids[1] != "expiring" {
+		t.Fatalf("expected accr and expiring to be current, got %v", ids)
+	}
+
+	s.now = testNow.Add(2*time.Hour + clockSkew)
+	if ids := current(); len(ids) != 1 || ids[0] != "accr" {
+		t.Fatalf("expected only accr to be current, got %v", ids)
+	}
+}
+
+func TestUpdatePartyInfoMergesFields(t *testing.T) {
+	s := newTestNetwork(t)
+	mustInvoke(t, s, "admin", "update_party_info", "farm", `{"Name":"Old Farm","Contact":"farm@example.com"}`)
+	mustInvoke(t, s, "farm", "update_party_info", "farm", `{"Name":"New Farm"}`)
+
+	party, err := s.cc.getParty(s, "farm")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if party.Name != "New Farm" || party.Contact != "farm@example.com" {
+		t.Fatalf("expected only name to change, got %+v", party)
+	}
+	if party.Role != "Farm" || len(party.Certs) != 1 || party.Certs[0] != encodeCert("farm") {
+		t.Fatalf("expected role and certs to be preserved, got %+v", party)
+	}
+
+	_, err = s.invoke("farm2", "update_party_info", "farm", `{"Name":"Stolen Farm"}`)
+	expectError(t, err, "not an admin or the party itself")
+}
+
+func TestTransferAccreditation(t *testing.T) {
+	s := newTestNetwork(t)
+	for _, id := range []string{"accr", "accr2"} {
+		mustInvoke(t, s, "ab", "add_signing_accreditation", id, "Organic", ts(-time.Hour), ts(365*24*time.Hour))
+	}
+
+	_, err := s.invoke("ab", "transfer_accreditation", "ab", "ab2", `["accr","accr2"]`)
+	expectError(t, err, "not an admin")
+
+	_, err = s.invoke("admin", "transfer_accreditation", "ab", "cb", `["accr","accr2"]`)
+	expectError(t, err, "no AccreditationBody")
+
+	_, err = s.invoke("admin", "transfer_accreditation", "ab2", "ab", `["accr"]`)
+	expectError(t, err, "not the issuer")
+
+	mustInvoke(t, s, "admin", "transfer_accreditation", "ab", "ab2", `["accr","accr2"]`)
+	for _, id := range []string{"accr", "accr2"} {
+		if body := getTestAccreditation(t, s, id).AccreditationBody; body != "ab2" {
+			t.Fatalf("expected %s to belong to ab2, got %s", id, body)
+		}
+	}
+
+	_, err = s.invoke("ab", "issue_signing_accreditation", "accr", "cb")
+	if err == nil {
+		t.Fatalf("expected former accreditation body to be rejected")
+	}
+	mustInvoke(t, s, "ab2", "issue_signing_accreditation", "accr", "cb")
+}
+
+func TestGrantSigningAuthorityPastExpiry(t *testing.T) {
+	s := newTestNetwork(t)
+	mustInvoke(t, s, "ab", "add_signing_accreditation", "accr", "Organic", ts(-time.Hour), ts(365*24*time.Hour))
+	mustInvoke(t, s, "ab", "issue_signing_accreditation", "accr", "cb")
+
+	_, err := s.invoke("cb", "grant_signing_authority", "accr", "farm", ts(-time.Minute))
+	expectError(t, err, "is not after transaction time")
+
+	_, err = s.invoke("cb", "grant_signing_authority_batch", "accr", `["farm"]`, ts(0))
+	expectError(t, err, "is not after transaction time")
+
+	mustInvoke(t, s, "cb", "grant_signing_authority", "accr", "farm", ts(time.Minute))
+}
+
+func TestCertifiedUnderRevokedAuthority(t *testing.T) {
+	s := newTestNetwork(t)
+	accredit(t, s, "accr")
+	mustInvoke(t, s, "cb", "grant_signing_authority", "accr", "farm2", ts(180*24*time.Hour))
+	mustInvoke(t, s, "farm", "create_grapes", testUUID(1), ts(0), "100")
+	mustInvoke(t, s, "farm", "certify_grapes", testUUID(1), "accr", ts(0))
+	mustInvoke(t, s, "farm2", "create_grapes", testUUID(2), ts(0), "100")
+	mustInvoke(t, s, "farm2", "certify_grapes", testUUID(2), "accr", ts(0))
+	mustInvoke(t, s, "cb", "revoke_signing_authority", "accr", "farm", ts(time.Minute))
+
+	var offenders []RevokedAuthoritySignature
+	if err := json.Unmarshal(mustQuery(t, s, "certified_under_revoked_authority"), &offenders); err != nil {
+		t.Fatal(err)
+	}
+	if len(offenders) != 1 || offenders[0].UUID != testUUID(1) || offenders[0].Authorization.AuthorizedParty != "farm" {
+		t.Fatalf("expected only grapes of farm to be reported, got %+v", offenders)
+	}
+}
+
+func testMetrics(t *testing.T, s *testStub) map[string]int {
+	var counters map[string]int
+	if err := json.Unmarshal(mustQuery(t, s, "metrics"), &counters); err != nil {
+		t.Fatal(err)
+	}
+	return counters
+}
+
+func TestMetricsCountSuccessfulCalls(t *testing.T) {
+	s := newTestNetwork(t)
+	accredit(t, s, "accr")
+	if counters := testMetrics(t, s); counters["add_party"] != 9 || counters["create_grapes"] != 0 {
+		t.Fatalf("unexpected initial counters %v", counters)
+	}
+
+	mustInvoke(t, s, "farm", "create_grapes", testUUID(1), ts(0), "100")
+	mustInvoke(t, s, "farm", "create_grapes", testUUID(2), ts(0), "100")
+	mustInvoke(t, s, "farm", "certify_grapes", testUUID(1), "accr", ts(0))
+	_, err := s.invoke("farm2", "certify_grapes", testUUID(2), "accr", ts(0))
+	if err == nil {
+		t.Fatalf("expected certification without authority to fail")
+	}
+
+	counters := testMetrics(t, s)
+	if counters["create_grapes"] != 2 || counters["certify_grapes"] != 1 {
+		t.Fatalf("expected 2 create_grapes and 1 certify_grapes calls, got %v", counters)
+	}
+
+	mustInvoke(t, s, "farm", "certify_grapes", testUUID(2), "accr", ts(0))
+	if counters := testMetrics(t, s); counters["create_grapes"] != 2 || counters["certify_grapes"] != 2 {
+		t.Fatalf("expected 2 create_grapes and 2 certify_grapes calls, got %v", counters)
+	}
+}
+
+func TestMetricsKeyPerFunction(t *testing.T) {
+	s := newTestNetwork(t)
+	accredit(t, s, "accr")
+
+	mustInvoke(t, s, "farm", "create_grapes", testUUID(1), ts(0), "100")
+	for _, key := range s.written {
+		if key == metricsKey || key == metricKey("certify_grapes") {
+			t.Fatalf("create_grapes wrote %s", key)
+		}
+	}
+	if count_b, _ := s.GetState(metricKey("create_grapes")); string(count_b) != "1" {
+		t.Fatalf("expected create_grapes counter 1, got %q", count_b)
+	}
+
+	// counters written under the single key are still reported
+	s.transact("admin", func() ([]byte, error) {
+		return nil, s.PutState(metricsKey, []byte(`{"create_grapes":3,"add_admin":1}`))
+	})
+	mustInvoke(t, s, "farm", "certify_grapes", testUUID(1), "accr", ts(0))
+	counters := testMetrics(t, s)
+	if counters["create_grapes"] != 4 || counters["certify_grapes"] != 1 || counters["add_admin"] != 1 {
+		t.Fatalf("unexpected counters %v", counters)
+	}
+}
+
+func TestRevokeSigningAccreditationRoles(t *testing.T) {
+	s := newTestNetwork(t)
+	for _, id := range []string{"accr1", "accr2", "accr3"} {
+		mustInvoke(t, s, "ab", "add_signing_accreditation", id, "Organic", ts(-time.Hour), ts(365*24*time.Hour))
+	}
+
+	mustInvoke(t, s, "ab", "revoke_signing_accreditation", "accr1", ts(0))
+	mustInvoke(t, s, "auditor", "revoke_signing_accreditation", "accr2", ts(0))
+	_, err := s.invoke("farm", "revoke_signing_accreditation", "accr3", ts(0))
+	expectError(t, err, "is no AccreditationBody or Auditor")
+
+	for id, revoked := range map[string]bool{"accr1": true, "accr2": true, "accr3": false} {
+		if getTestAccreditation(t, s, id).Revoked != revoked {
+			t.Fatalf("expected %s revoked to be %v", id, revoked)
+		}
+	}
+}
+
+func TestCreateCertifyTransfer(t *testing.T) {
+	s := newTestNetwork(t)
+	accredit(t, s, "accr")
+	mustInvoke(t, s, "farm", "create_grapes", testUUID(1), ts(0), "100")
+	mustInvoke(t, s, "farm", "certify_grapes", testUUID(1), "accr", ts(time.Minute))
+
+	mustInvoke(t, s, "farm", "transfer_grapes", testUUID(1), "trader", ts(2*time.Minute))
+
+	unit := getTestGrapes(t, s, testUUID(1))
+	if len(unit.AccreditationSignatures) != 1 || len(unit.Ownership) != 2 || unit.Ownership[1].PartyID != "trader" {
+		t.Fatalf("expected certified grapes owned by trader, got %+v", unit)
+	}
+}
+
+func TestRevokeSignatureDispatched(t *testing.T) {
+	s := newTestNetwork(t)
+	mustInvoke(t, s, "farm", "create_grapes", testUUID(1), ts(0), "100")
+
+	_, err := s.invoke("auditor", "revoke_signature", testUUID(1), "accr", ts(time.Minute))
+	if err != nil && strings.Contains(err.Error(), "unknown function") {
+		t.Fatalf("revoke_signature is not dispatched: %s", err)
+	}
+}
+
+func TestCreateCertifyRevokeSignature(t *testing.T) {
+	s := newTestNetwork(t)
+	accredit(t, s, "accr")
+	mustInvoke(t, s, "farm", "create_grapes", testUUID(1), ts(0), "100")
+	mustInvoke(t, s, "farm", "certify_grapes", testUUID(1), "accr", ts(time.Minute))
+
+	accredit(t, s, "accr2")
+	mustInvoke(t, s, "farm", "certify_grapes", testUUID(1), "accr2", ts(time.Minute))
+
+	mustInvoke(t, s, "auditor", "revoke_signature", testUUID(1), "accr", ts(2*time.Minute))
+
+	signatures := getTestGrapes(t, s, testUUID(1)).AccreditationSignatures
+	if len(signatures) != 2 || !signatures[0].Revoked || signatures[1].Revoked {
+		t.Fatalf("expected only signature of accr to be revoked, got %+v", signatures)
+	}
+	if revoked := signatures[0].RevocationTimestamp.Format(time.RFC3339); revoked != ts(2*time.Minute) {
+		t.Fatalf("expected revocation at %s, got %s", ts(2*time.Minute), revoked)
+	}
+}
+
+func TestGetPartiesWithoutPartiesState(t *testing.T) {
+	s := newTestStub(t)
+	parties, err := s.cc.getParties(s)
+	if err != nil || len(parties) != 0 {
+		t.Fatalf("expected no parties after Init, got %v (%v)", parties, err)
+	}
+
+	_, err = s.transact("admin", func() ([]byte, error) { return nil, s.DelState("Parties") })
+	if err != nil {
+		t.Fatal(err)
+	}
+	parties, err = s.cc.getParties(s)
+	if err != nil || parties == nil || len(parties) != 0 {
+		t.Fatalf("expected empty list without Parties state, got %v (%v)", parties, err)
+	}
+}
+
+func TestInitReportsFailingKey(t *testing.T) {
+	cc := new(AgrifoodChaincode)
+	s := &testStub{MockStub: shim.NewMockStub("agrifood", cc), cc: cc, now: testNow, failKey: signingAccreditationsKey}
+
+	_, err := s.init(encodeCert("admin"))
+	expectError(t, err, "Failed initializing SigningAccreditations")
+}
+
+func TestInitWithoutArguments(t *testing.T) {
+	cc := new(AgrifoodChaincode)
+	s := &testStub{MockStub: shim.NewMockStub("agrifood", cc), cc: cc, now: testNow}
+
+	_, err := s.init()
+	expectError(t, err, "admin certificate")
+}
+
+func TestListParties(t *testing.T) {
+	s := newTestStub(t)
+	parties := [][]string{{"ab", "AccreditationBody"}, {"cb", "CertificationBody"}, {"farm", "Farm"}}
+	for _, party := range parties {
+		mustInvoke(t, s, "admin", "add_party", party[0], party[1], encodeCert(party[0]))
+	}
+
+	list_b := mustQuery(t, s, "list_parties")
+	if bytes.Contains(list_b, []byte("Certs")) {
+		t.Fatalf("expected parties without certificates, got %s", list_b)
+	}
+
+	var list []PublicParty
+	if err := json.Unmarshal(list_b, &list); err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != len(parties) {
+		t.Fatalf("expected %d parties, got %+v", len(parties), list)
+	}
+	for i, party := range parties {
+		if list[i].ID != party[0] || list[i].Role != party[1] {
+			t.Fatalf("expected %s as %s, got %+v", party[0], party[1], list[i])
+		}
+	}
+}
+
+func TestGetParty(t *testing.T) {
+	s := newTestNetwork(t)
+
+	var party PublicParty
+	if err := json.Unmarshal(mustQuery(t, s, "get_party", "cb"), &party); err != nil {
+		t.Fatal(err)
+	}
+	if party.ID != "cb" || party.Role != "CertificationBody" {
+		t.Fatalf("unexpected party %+v", party)
+	}
+
+	_, err := s.query("", "get_party", "nobody")
+	expectError(t, err, "party not found: nobody")
+}
+
+func TestAccreditationSigners(t *testing.T) {
+	s := newTestNetwork(t)
+	accredit(t, s, "accr")
+	accredit(t, s, "accr2")
+	mustInvoke(t, s, "cb", "grant_signing_authority", "accr", "farm2", ts(180*24*time.Hour))
+	mustInvoke(t, s, "cb", "revoke_signing_authority", "accr", "farm", ts(time.Minute))
+
+	var signers []SigningAuthorization
+	if err := json.Unmarshal(mustQuery(t, s, "accreditation_signers", "accr"), &signers); err != nil {
+		t.Fatal(err)
+	}
+	if len(signers) != 2 || signers[0].AuthorizedParty != "farm" || signers[1].AuthorizedParty != "farm2" {
+		t.Fatalf("expected farm and farm2, got %+v", signers)
+	}
+	if !signers[0].Revoked || signers[0].RevocationTimestamp.Format(time.RFC3339) != ts(time.Minute) || signers[1].Revoked {
+		t.Fatalf("expected only authorization of farm to be revoked, got %+v", signers)
+	}
+
+	_, err := s.query("", "accreditation_signers", "unknown")
+	expectError(t, err, "Error retrieving accreditation")
+}
+
+func expectGrapesEvent(t *testing.T, s *testStub, name string, uuid string, timestamp string) {
+	if s.event != name {
+		t.Fatalf("expected event %s, got %q", name, s.event)
+	}
+	var event GrapesEvent
+	if err := json.Unmarshal(s.payload, &event); err != nil {
+		t.Fatal(err)
+	}
+	if event.UUID != uuid || event.Producer != "farm" || event.Timestamp.Format(time.RFC3339) != timestamp {
+		t.Fatalf("unexpected %s payload %+v", name, event)
+	}
+}
+
+func TestGrapesEvents(t *testing.T) {
+	s := newTestNetwork(t)
+	accredit(t, s, "accr")
+
+	mustInvoke(t, s, "farm", "create_grapes", testUUID(1), ts(0), "100")
+	expectGrapesEvent(t, s, "grapes_created", testUUID(1), ts(0))
+
+	mustInvoke(t, s, "farm", "certify_grapes", testUUID(1), "accr", ts(time.Minute))
+	expectGrapesEvent(t, s, "grapes_certified", testUUID(1), ts(time.Minute))
+
+	// no event when saving fails
+	_, err := s.invoke("farm", "create_grapes", testUUID(1), ts(0), "100")
+	if err == nil || s.event != "" {
+		t.Fatalf("expected failed creation without event, got %q (%v)", s.event, err)
+	}
+}
+
+func TestAccreditationRevokedEvent(t *testing.T) {
+	s := newTestNetwork(t)
+	accredit(t, s, "accr")
+	mustInvoke(t, s, "ab", "revoke_signing_accreditation", "accr", ts(time.Minute))
+
+	if s.event != "accreditation_revoked" {
+		t.Fatalf("expected accreditation_revoked event, got %q", s.event)
+	}
+	var event AccreditationRevokedEvent
+	if err := json.Unmarshal(s.payload, &event); err != nil {
+		t.Fatal(err)
+	}
+	if event.AccreditationID != "accr" || event.RevocationTimestamp.Format(time.RFC3339) != ts(time.Minute) {
+		t.Fatalf("unexpected payload %+v", event)
+	}
+}
+
+func TestGetGrapes(t *testing.T) {
+	s := newTestNetwork(t)
+	accredit(t, s, "accr")
+	mustInvoke(t, s, "farm", "create_grapes", testUUID(1), ts(0), "100")
+	mustInvoke(t, s, "farm", "certify_grapes", testUUID(1), "accr", ts(time.Minute))
+	mustInvoke(t, s, "farm", "transfer_grapes", testUUID(1), "trader", ts(2*time.Minute))
+
+	var grapes struct {
+		UUID                    string
+		Producer                string
+		Created                 time.Time
+		Amount                  int
+		AccreditationSignatures []AccreditationSignature
+		Ownership               []OwnershipEntry
+	}
+	if err := json.Unmarshal(mustQuery(t, s, "get_grapes", testUUID(1)), &grapes); err != nil {
+		t.Fatal(err)
+	}
+	if grapes.UUID != testUUID(1) || grapes.Producer != "farm" || grapes.Created.Format(time.RFC3339) != ts(0) || grapes.Amount != 100 {
+		t.Fatalf("unexpected grapes %+v", grapes)
+	}
+	if len(grapes.AccreditationSignatures) != 1 || grapes.AccreditationSignatures[0].AccreditationID != "accr" {
+		t.Fatalf("unexpected signatures %+v", grapes.AccreditationSignatures)
+	}
+	if len(grapes.Ownership) != 2 || grapes.Ownership[0].PartyID != "farm" || grapes.Ownership[1].PartyID != "trader" {
+		t.Fatalf("unexpected ownership %+v", grapes.Ownership)
+	}
+
+	_, err := s.query("", "get_grapes", testUUID(2))
+	expectError(t, err, "grapes not found")
+}
+
+func TestAddSigningAccreditationExpiry(t *testing.T) {
+	s := newTestNetwork(t)
+
+	_, err := s.invoke("ab", "add_signing_accreditation", "accr", "Organic", ts(time.Hour), ts(time.Hour))
+	expectError(t, err, "is not after created date")
+
+	_, err = s.invoke("ab", "add_signing_accreditation", "accr", "Organic", ts(-2*time.Hour), ts(-time.Hour))
+	expectError(t, err, "is in the past")
+
+	mustInvoke(t, s, "ab", "add_signing_accreditation", "accr", "Organic", ts(-time.Hour), ts(time.Hour))
+}
+
+func TestAddedAccreditationCanBeIssued(t *testing.T) {
+	s := newTestNetwork(t)
+	mustInvoke(t, s, "ab", "add_signing_accreditation", "accr", "Organic", ts(-time.Hour), ts(365*24*time.Hour))
+
+	if body := getTestAccreditation(t, s, "accr").AccreditationBody; body != "ab" {
+		t.Fatalf("expected accreditation body ab, got %q", body)
+	}
+
+	mustInvoke(t, s, "ab", "issue_signing_accreditation", "accr", "cb")
+}
+
+func TestIssueRevokedAccreditation(t *testing.T) {
+	s := newTestNetwork(t)
+	mustInvoke(t, s, "ab", "add_signing_accreditation", "accr", "Organic", ts(-time.Hour), ts(365*24*time.Hour))
+	mustInvoke(t, s, "ab", "revoke_signing_accreditation", "accr", ts(0))
+
+	_, err := s.invoke("ab", "issue_signing_accreditation", "accr", "cb")
+	expectError(t, err, "cannot issue accreditation accr, it was revoked")
+	if cb := getTestAccreditation(t, s, "accr").CertificationBody; cb != "" {
+		t.Fatalf("expected revoked accreditation not to be issued, got %s", cb)
+	}
+}
+
+func TestReissueAccreditation(t *testing.T) {
+	s := newTestNetwork(t)
+	mustInvoke(t, s, "ab", "add_signing_accreditation", "accr", "Organic", ts(-time.Hour), ts(365*24*time.Hour))
+	mustInvoke(t, s, "ab", "issue_signing_accreditation", "accr", "cb")
+
+	_, err := s.invoke("ab", "issue_signing_accreditation", "accr", "cb2")
+	expectError(t, err, "accreditation accr is already issued to cb")
+
+	_, err = s.invoke("ab", "issue_signing_accreditation", "accr", "cb2", "false")
+	expectError(t, err, "already issued")
+
+	mustInvoke(t, s, "ab", "issue_signing_accreditation", "accr", "cb2", "true")
+	if cb := getTestAccreditation(t, s, "accr").CertificationBody; cb != "cb2" {
+		t.Fatalf("expected accreditation reassigned to cb2, got %s", cb)
+	}
+}
+
+func TestListGrapesPages(t *testing.T) {
+	s := newTestNetwork(t)
+	for i := 1; i <= 5; i++ {
+		mustInvoke(t, s, "farm", "create_grapes", testUUID(i), ts(0), "100")
+	}
+
+	var first, second GrapesPage
+	json.Unmarshal(mustQuery(t, s, "list_grapes", "3"), &first)
+	if len(first.Grapes) != 3 || first.Bookmark != testUUID(4) {
+		t.Fatalf("expected 3 grapes and bookmark %s, got %d and %q", testUUID(4), len(first.Grapes), first.Bookmark)
+	}
+
+	json.Unmarshal(mustQuery(t, s, "list_grapes", "3", first.Bookmark), &second)
+	if len(second.Grapes) != 2 || second.Bookmark != "" {
+		t.Fatalf("expected last page of 2 grapes, got %d and bookmark %q", len(second.Grapes), second.Bookmark)
+	}
+
+	// pages continue without gaps or overlap
+	for i, unit := range append(first.Grapes, second.Grapes...) {
+		if unit.UUID != testUUID(i+1) {
+			t.Fatalf("expected grapes %s at position %d, got %s", testUUID(i+1), i, unit.UUID)
+		}
+	}
+}
+
+func TestAddPartyWritesOwnKey(t *testing.T) {
+	s := newTestStub(t)
+	mustInvoke(t, s, "admin", "add_party", "farm", "Farm", encodeCert("farm"))
+	farm_b, err := s.GetState(partyKey("farm"))
+	if err != nil || farm_b == nil {
+		t.Fatalf("expected farm under its own key, got %s (%v)", farm_b, err)
+	}
+
+	mustInvoke(t, s, "admin", "add_party", "farm2", "Farm", encodeCert("farm2"))
+	for _, key := range s.written {
+		if key == partyKey("farm") || key == "Parties" {
+			t.Fatalf("adding farm2 wrote %s", key)
+		}
+	}
+
+	if after_b, _ := s.GetState(partyKey("farm")); !bytes.Equal(after_b, farm_b) {
+		t.Fatalf("expected farm to be untouched, got %s", after_b)
+	}
+	if party, err := s.cc.getParty(s, "farm2"); err != nil || party.Role != "Farm" {
+		t.Fatalf("expected farm2 to be stored, got %+v (%v)", party, err)
+	}
+}
+
+func TestGrapeHistory(t *testing.T) {
+	s := newTestNetwork(t)
+	accredit(t, s, "accr")
+	mustInvoke(t, s, "farm", "create_grapes", testUUID(1), ts(0), "100")
+	created := fmt.Sprintf("tx%d", s.txs)
+	s.now = testNow.Add(time.Minute)
+	mustInvoke(t, s, "farm", "certify_grapes", testUUID(1), "accr", ts(time.Minute))
+	certified := fmt.Sprintf("tx%d", s.txs)
+
+	var history []GrapesHistoryEntry
+	if err := json.Unmarshal(mustQuery(t, s, "grape_history", testUUID(1)), &history); err != nil {
+		t.Fatal(err)
+	}
+	if len(history) != 2 || history[0].TxID != created || history[1].TxID != certified {
+		t.Fatalf("expected entries of %s and %s, got %+v", created, certified, history)
+	}
+	if !history[0].Timestamp.Equal(testNow) || !history[1].Timestamp.Equal(testNow.Add(time.Minute)) {
+		t.Fatalf("expected entries at transaction times, got %s and %s", history[0].Timestamp, history[1].Timestamp)
+	}
+	if len(history[0].Value.AccreditationSignatures) != 0 || len(history[1].Value.AccreditationSignatures) != 1 {
+		t.Fatalf("expected history entries in order, got %+v", history)
+	}
+
+	_, err := s.query("", "grape_history", testUUID(2))
+	expectError(t, err, "grapes not found")
+}
+
+func TestRevokeSignatureByIssueTime(t *testing.T) {
+	s := newTestNetwork(t)
+	accredit(t, s, "accr")
+	mustInvoke(t, s, "farm", "create_grapes", testUUID(1), ts(0), "100")
+	mustInvoke(t, s, "farm", "certify_grapes", testUUID(1), "accr", ts(time.Minute))
+	mustInvoke(t, s, "auditor", "revoke_signature", testUUID(1), "accr", ts(2*time.Minute))
+	mustInvoke(t, s, "farm", "certify_grapes", testUUID(1), "accr", ts(3*time.Minute))
+
+	mustInvoke(t, s, "auditor", "revoke_signature", testUUID(1), "accr", ts(4*time.Minute), ts(time.Minute))
+	signatures := getTestGrapes(t, s, testUUID(1)).AccreditationSignatures
+	if len(signatures) != 2 || !signatures[0].Revoked || signatures[1].Revoked {
+		t.Fatalf("expected only the first signature to be revoked, got %+v", signatures)
+	}
+
+	mustInvoke(t, s, "auditor", "revoke_signature", testUUID(1), "accr", ts(5*time.Minute), ts(3*time.Minute))
+	signatures = getTestGrapes(t, s, testUUID(1)).AccreditationSignatures
+	if !signatures[0].Revoked || !signatures[1].Revoked {
+		t.Fatalf("expected all signatures of accr to be revoked, got %+v", signatures)
+	}
+}
+
+func TestRevokeUnknownSignature(t *testing.T) {
+	s := newTestNetwork(t)
+	accredit(t, s, "accr")
+	mustInvoke(t, s, "farm", "create_grapes", testUUID(1), ts(0), "100")
+	mustInvoke(t, s, "farm", "certify_grapes", testUUID(1), "accr", ts(time.Minute))
+
+	_, err := s.invoke("auditor", "revoke_signature", testUUID(1), "unknown", ts(2*time.Minute))
+	expectError(t, err, "no matching signature found on grape unit "+testUUID(1))
+
+	_, err = s.invoke("auditor", "revoke_signature", testUUID(1), "accr", ts(2*time.Minute), ts(3*time.Minute))
+	expectError(t, err, "no matching signature found")
+
+	if getTestGrapes(t, s, testUUID(1)).AccreditationSignatures[0].Revoked {
+		t.Fatalf("expected signature to remain valid")
+	}
+}
+
+func TestCreateWine(t *testing.T) {
+	s := newTestNetwork(t)
+	for i := 1; i <= 3; i++ {
+		mustInvoke(t, s, "farm", "create_grapes", testUUID(i), ts(0), "100")
+	}
+	mustInvoke(t, s, "farm", "transfer_grapes", testUUID(1), "trader", ts(time.Minute))
+	mustInvoke(t, s, "farm", "transfer_grapes", testUUID(2), "trader", ts(time.Minute))
+
+	_, err := s.invoke("farm", "create_wine", "wine", ts(2*time.Minute), fmt.Sprintf("[%q]", testUUID(3)))
+	expectError(t, err, "Caller (farm) is no Trader or Winery")
+
+	_, err = s.invoke("trader", "create_wine", "wine", ts(2*time.Minute), fmt.Sprintf("[%q]", testUUID(4)))
+	expectError(t, err, "grapes not found: "+testUUID(4))
+
+	_, err = s.invoke("trader", "create_wine", "wine", ts(2*time.Minute), fmt.Sprintf("[%q,%q]", testUUID(1), testUUID(3)))
+	expectError(t, err, "not the current owner of the grapes: "+testUUID(3))
+
+	_, err = s.invoke("trader", "create_wine", "wine", ts(2*time.Minute), fmt.Sprintf("[%q,%q]", testUUID(1), testUUID(1)))
+	expectError(t, err, "listed twice")
+
+	mustInvoke(t, s, "trader", "create_wine", "wine", ts(2*time.Minute), fmt.Sprintf("[%q,%q]", testUUID(1), testUUID(2)))
+
+	var wine WineBatch
+	if err = json.Unmarshal(mustQuery(t, s, "get_wine", "wine"), &wine); err != nil {
+		t.Fatal(err)
+	}
+	if wine.Producer != "trader" || len(wine.SourceGrapeUUIDs) != 2 || len(wine.Ownership) != 1 || wine.Ownership[0].PartyID != "trader" {
+		t.Fatalf("unexpected wine %+v", wine)
+	}
+	if status := getTestGrapes(t, s, testUUID(1)).Status; status != statusConsumed {
+		t.Fatalf("expected source grapes to be consumed, got %s", status)
+	}
+
+	_, err = s.invoke("trader", "create_wine", "wine", ts(2*time.Minute), fmt.Sprintf("[%q]", testUUID(2)))
+	expectError(t, err, "WineBatch ID needs to be unique")
+
+	_, err = s.query("", "get_wine", "unknown")
+	expectError(t, err, "wine not found")
+}
+
+func TestInitWithCustomRoles(t *testing.T) {
+	cc := new(AgrifoodChaincode)
+	s := &testStub{MockStub: shim.NewMockStub("agrifood", cc), cc: cc, now: testNow}
+
+	invalid := map[string]string{
+		`[]`:              "Roles must not be empty",
+		`["Farm",""]`:     `Invalid role ""`,
+		`["Farm","Farm"]`: `Invalid role "Farm"`,
+		`"Farm"`:          "Error parsing roles",
+	}
+	for roles, msg := range invalid {
+		_, err := s.init(encodeCert("admin"), roles)
+		expectError(t, err, msg)
+	}
+
+	_, err := s.init(encodeCert("admin"), `["AccreditationBody","CertificationBody","Farm","Auditor","Trader","Winery","Retailer"]`)
+	if err != nil {
+		t.Fatalf("Init failed: %s", err)
+	}
+
+	// roles are loaded from world-state, not kept by the chaincode object
+	s.cc = new(AgrifoodChaincode)
+	mustInvoke(t, s, "admin", "add_party", "retailer", "Retailer", encodeCert("retailer"))
+	if party, err := s.cc.getParty(s, "retailer"); err != nil || party.Role != "Retailer" {
+		t.Fatalf("expected retailer to be added, got %+v (%v)", party, err)
+	}
+
+	_, err = s.invoke("admin", "add_party", "shipper", "Logistics", encodeCert("shipper"))
+	if err == nil {
+		t.Fatalf("expected unknown role to be rejected")
+	}
+}
+
+func TestCustomRolesReplaceDefaults(t *testing.T) {
+	cc := new(AgrifoodChaincode)
+	s := &testStub{MockStub: shim.NewMockStub("agrifood", cc), cc: cc, now: testNow}
+	if _, err := s.init(encodeCert("admin"), `["Retailer","Farm","Trader"]`); err != nil {
+		t.Fatalf("Init failed: %s", err)
+	}
+
+	var roles []string
+	if err := json.Unmarshal(mustAdminQuery(t, s, "get_roles"), &roles); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(roles, ",") != "Retailer,Farm,Trader" {
+		t.Fatalf("expected custom roles, got %v", roles)
+	}
+
+	for _, party := range [][]string{{"retailer", "Retailer"}, {"farm", "Farm"}, {"trader", "Trader"}} {
+		mustInvoke(t, s, "admin", "add_party", party[0], party[1], encodeCert(party[0]))
+	}
+	_, err := s.invoke("admin", "add_party", "ab", "AccreditationBody", encodeCert("ab"))
+	expectError(t, err, "Incorrect role")
+
+	// functions find their roles by name, not by position
+	mustInvoke(t, s, "farm", "create_grapes", testUUID(1), ts(0), "100")
+	mustInvoke(t, s, "farm", "transfer_grapes", testUUID(1), "trader", ts(time.Minute))
+	if uuids := ownedGrapes(t, s, "trader"); len(uuids) != 1 {
+		t.Fatalf("expected trader to own the grapes, got %v", uuids)
+	}
+
+	_, err = s.invoke("retailer", "create_grapes", testUUID(2), ts(0), "100")
+	expectError(t, err, "Caller (retailer) is no Farm")
+	_, err = s.invoke("trader", "transfer_grapes", testUUID(1), "retailer", ts(2*time.Minute))
+	expectError(t, err, "disallowed role Retailer")
+}
+
+func TestRolesInSeparateInstance(t *testing.T) {
+	s := newTestStub(t)
+	s.cc = new(AgrifoodChaincode)
+
+	mustInvoke(t, s, "admin", "add_party", "farm", "Farm", encodeCert("farm"))
+	_, err := s.invoke("admin", "add_party", "retailer", "Retailer", encodeCert("retailer"))
+	if err == nil {
+		t.Fatalf("expected unknown role to be rejected")
+	}
+
+	// chaincode deployed before roles were stored uses the built-in roles
+	_, err = s.transact("admin", func() ([]byte, error) { return nil, s.DelState(rolesKey) })
+	if err != nil {
+		t.Fatal(err)
+	}
+	roles, err := getRoles(s)
+	if err != nil || len(roles) != len(defaultRoles) || roles[2] != "Farm" {
+		t.Fatalf("expected built-in roles, got %v (%v)", roles, err)
+	}
+}
+
+func TestRemoveParty(t *testing.T) {
+	s := newTestNetwork(t)
+	mustInvoke(t, s, "farm", "create_grapes", testUUID(1), ts(0), "100")
+
+	_, err := s.invoke("farm", "remove_party", "farm2")
+	expectError(t, err, "not an admin")
+
+	mustInvoke(t, s, "admin", "remove_party", "farm2")
+	_, err = s.invoke("farm2", "create_grapes", testUUID(2), ts(0), "100")
+	expectError(t, err, "Party farm2 is deactivated")
+
+	_, err = s.invoke("farm", "transfer_grapes", testUUID(1), "farm2", ts(time.Minute))
+	expectError(t, err, "new party farm2 is deactivated")
+
+	_, err = s.invoke("admin", "remove_party", "farm2")
+	expectError(t, err, "already deactivated")
+}
+
+func TestSignerCertsValidity(t *testing.T) {
+	s := newTestNetwork(t)
+	accredit(t, s, "valid")
+	accredit(t, s, "revoked")
+	mustInvoke(t, s, "cb", "revoke_signing_authority", "revoked", "farm", ts(0))
+	mustInvoke(t, s, "ab", "add_signing_accreditation", "expired", "Organic", ts(-time.Hour), ts(365*24*time.Hour))
+	mustInvoke(t, s, "ab", "issue_signing_accreditation", "expired", "cb")
+	mustInvoke(t, s, "cb", "grant_signing_authority", "expired", "farm", ts(time.Hour))
+	s.now = testNow.Add(2*time.Hour + clockSkew)
+
+	var auths []SignerAuthorization
+	if err := json.Unmarshal(mustQuery(t, s, "signer_certs", "farm"), &auths); err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]bool{"valid": true, "revoked": false, "expired": false}
+	if len(auths) != len(expected) {
+		t.Fatalf("expected %d authorizations, got %+v", len(expected), auths)
+	}
+	for _, auth := range auths {
+		if auth.Valid != expected[auth.AccreditationID] {
+			t.Fatalf("expected %s valid to be %v", auth.AccreditationID, expected[auth.AccreditationID])
+		}
+	}
+}
+
+func TestCreateGrapesBatch(t *testing.T) {
+	s := newTestNetwork(t)
+	batch := fmt.Sprintf(`[{"UUID":%q,"Created":%q,"Amount":100},{"UUID":%q,"Created":%q,"Amount":50,"CropType":"olives","Latitude":44.8,"Longitude":-0.6}]`, testUUID(1), ts(0), testUUID(2), ts(0))
+
+	_, err := s.invoke("trader", "create_grapes_batch", batch)
+	expectError(t, err, "Caller (trader) is no Farm")
+
+	mustInvoke(t, s, "farm", "create_grapes_batch", batch)
+
+	grapes := getTestGrapes(t, s, testUUID(1))
+	if grapes.Producer != "farm" || grapes.Amount != 100 || grapes.Ownership[0].PartyID != "farm" {
+		t.Fatalf("unexpected grapes %+v", grapes)
+	}
+	olives := getTestGrapes(t, s, testUUID(2))
+	if olives.CropType != "olives" || olives.Amount != 50 || olives.Ownership[0].Latitude == nil || *olives.Ownership[0].Latitude != 44.8 {
+		t.Fatalf("expected 50 olives harvested at latitude 44.8, got %+v", olives)
+	}
+
+	// a transaction carries a single event, listing every unit of the batch
+	if s.event != "grapes_created" {
+		t.Fatalf("expected grapes_created event, got %q", s.event)
+	}
+	var events []GrapesEvent
+	if err = json.Unmarshal(s.payload, &events); err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 2 || events[0].UUID != testUUID(1) || events[1].UUID != testUUID(2) {
+		t.Fatalf("expected events for both units, got %s", s.payload)
+	}
+}
+
+func TestCreateGrapesBatchBlankCropType(t *testing.T) {
+	s := newTestNetwork(t)
+	batch := fmt.Sprintf(`[{"UUID":%q,"Created":%q,"Amount":100,"CropType":" "}]`, testUUID(1), ts(0))
+
+	_, err := s.invoke("farm", "create_grapes_batch", batch)
+	expectError(t, err, "crop type must not be empty")
+}
+
+func TestCreateGrapesBatchDuplicateUUID(t *testing.T) {
+	s := newTestNetwork(t)
+	mustInvoke(t, s, "farm", "create_grapes", testUUID(3), ts(0), "100")
+
+	for _, batch := range []string{
+		fmt.Sprintf(`[{"UUID":%q,"Created":%q,"Amount":100},{"UUID":%q,"Created":%q,"Amount":50},{"UUID":%q,"Created":%q,"Amount":50}]`, testUUID(1), ts(0), testUUID(2), ts(0), testUUID(1), ts(0)),
+		fmt.Sprintf(`[{"UUID":%q,"Created":%q,"Amount":100},{"UUID":%q,"Created":%q,"Amount":50}]`, testUUID(1), ts(0), testUUID(3), ts(0)),
+	} {
+		_, err := s.invoke("farm", "create_grapes_batch", batch)
+		if err == nil {
+			t.Fatalf("expected batch %s to be rejected", batch)
+		}
+
+		// none of the batch is created
+		for _, uuid := range []string{testUUID(1), testUUID(2)} {
+			if _, err := s.query("", "get_grapes", uuid); err == nil {
+				t.Fatalf("expected grapes %s not to be created", uuid)
+			}
+		}
+	}
+}
+
+func TestGrapeOwner(t *testing.T) {
+	s := newTestNetwork(t)
+	mustInvoke(t, s, "farm", "create_grapes", testUUID(1), ts(0), "100")
+	mustInvoke(t, s, "farm", "transfer_grapes", testUUID(1), "trader", ts(time.Minute))
+
+	var owners []OwnershipShare
+	if err := json.Unmarshal(mustQuery(t, s, "grape_owner", testUUID(1)), &owners); err != nil {
+		t.Fatal(err)
+	}
+	if len(owners) != 1 || owners[0].PartyID != "trader" || owners[0].Percentage != 100 || owners[0].Acquired.Format(time.RFC3339) != ts(time.Minute) {
+		t.Fatalf("expected trader to own the grapes since %s, got %+v", ts(time.Minute), owners)
+	}
+
+	// grapes without ownership entries
+	grapes := getTestGrapes(t, s, testUUID(1))
+	grapes.UUID, grapes.Ownership = testUUID(2), nil
+	_, err := s.transact("farm", func() ([]byte, error) { return nil, s.cc.saveGrapeUnit(s, grapes, true) })
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = s.query("", "grape_owner", testUUID(2))
+	expectError(t, err, "have no ownership entries")
+}
+
+func TestTransferToWinery(t *testing.T) {
+	s := newTestNetwork(t)
+	mustInvoke(t, s, "farm", "create_grapes", testUUID(1), ts(0), "100")
+
+	_, err := s.invoke("farm", "transfer_grapes", testUUID(1), "auditor", ts(time.Minute))
+	expectError(t, err, "expecting Farm, Trader or Winery")
+
+	mustInvoke(t, s, "farm", "transfer_grapes", testUUID(1), "winery", ts(time.Minute))
+	mustInvoke(t, s, "winery", "create_wine", "wine", ts(2*time.Minute), fmt.Sprintf("[%q]", testUUID(1)))
+}
+
+func TestOwnershipTimestampsStrictlyIncrease(t *testing.T) {
+	s := newTestNetwork(t)
+	mustInvoke(t, s, "farm", "create_grapes", testUUID(1), ts(0), "100")
+
+	for _, timestamp := range []string{ts(-time.Minute), ts(0)} {
+		_, err := s.invoke("farm", "transfer_grapes", testUUID(1), "trader", timestamp)
+		expectError(t, err, "needs to be after latest ownership entry timestamp")
+		_, err = s.invoke("farm", "transfer_share", testUUID(1), "trader", "50", timestamp)
+		expectError(t, err, "needs to be after latest ownership entry timestamp")
+	}
+
+	mustInvoke(t, s, "farm", "transfer_grapes", testUUID(1), "trader", ts(time.Second))
+}
+
+func TestTransferToCurrentOwner(t *testing.T) {
+	s := newTestNetwork(t)
+	mustInvoke(t, s, "farm", "create_grapes", testUUID(1), ts(0), "100")
+
+	_, err := s.invoke("farm", "transfer_grapes", testUUID(1), "farm", ts(time.Minute))
+	expectError(t, err, "cannot transfer grapes to the current owner")
+
+	mustInvoke(t, s, "farm", "transfer_grapes", testUUID(1), "farm2", ts(time.Minute))
+	if ownership := getTestGrapes(t, s, testUUID(1)).Ownership; len(ownership) != 2 || ownership[1].PartyID != "farm2" {
+		t.Fatalf("expected single transfer to farm2, got %+v", ownership)
+	}
+}
+
+func TestIsAccreditationValid(t *testing.T) {
+	s := newTestNetwork(t)
+	mustInvoke(t, s, "ab", "add_signing_accreditation", "valid", "Organic", ts(-time.Hour), ts(365*24*time.Hour))
+	mustInvoke(t, s, "ab", "add_signing_accreditation", "revoked", "Organic", ts(-time.Hour), ts(365*24*time.Hour))
+	mustInvoke(t, s, "ab", "revoke_signing_accreditation", "revoked", ts(0))
+	mustInvoke(t, s, "ab", "add_signing_accreditation", "expired", "Organic", ts(-time.Hour), ts(time.Hour))
+	s.now = testNow.Add(2*time.Hour + clockSkew)
+
+	tests := []struct {
+		id     string
+		valid  bool
+		reason string
+	}{
+		{"valid", true, ""},
+		{"revoked", false, "Revoked at"},
+		{"expired", false, "Expired at"},
+		{"unknown", false, "Unknown accreditation"},
+	}
+	for _, test := range tests {
+		var validity Validity
+		if err := json.Unmarshal(mustQuery(t, s, "is_accreditation_valid", test.id), &validity); err != nil {
+			t.Fatal(err)
+		}
+		if validity.Valid != test.valid || !strings.HasPrefix(validity.Reason, test.reason) || (test.reason == "") != (validity.Reason == "") {
+			t.Fatalf("%s: expected valid %v (%q), got %+v", test.id, test.valid, test.reason, validity)
+		}
+	}
+}
+
+func TestGrantedAuthorizationIsStored(t *testing.T) {
+	s := newTestNetwork(t)
+	accredit(t, s, "accr")
+	auth, err := s.cc.getSigningAuthorization(s, "accr", "farm")
+	if err != nil || auth.CertifyingParty != "cb" || auth.Expires.Format(time.RFC3339) != ts(180*24*time.Hour) {
+		t.Fatalf("expected stored authorization of farm, got %+v (%v)", auth, err)
+	}
+
+	auth.AuthorizedParty = "farm2"
+	_, err = s.transact("cb", func() ([]byte, error) { return nil, s.cc.saveSigningAuthorization(s, auth, false) })
+	expectError(t, err, "signing authorization of farm2 for accr does not exist")
+}
+
+func TestGrantRevokeRegrant(t *testing.T) {
+	s := newTestNetwork(t)
+	accredit(t, s, "accr")
+	mustInvoke(t, s, "farm", "create_grapes", testUUID(1), ts(0), "100")
+
+	mustInvoke(t, s, "cb", "revoke_signing_authority", "accr", "farm", ts(0))
+	_, err := s.invoke("farm", "certify_grapes", testUUID(1), "accr", ts(0))
+	expectError(t, err, "No signing authority")
+
+	mustInvoke(t, s, "cb", "grant_signing_authority", "accr", "farm", ts(time.Hour))
+	mustInvoke(t, s, "farm", "certify_grapes", testUUID(1), "accr", ts(0))
+
+	// the grant replaces the revoked authorization
+	var signers []SigningAuthorization
+	if err = json.Unmarshal(mustQuery(t, s, "accreditation_signers", "accr"), &signers); err != nil {
+		t.Fatal(err)
+	}
+	if len(signers) != 1 || signers[0].Revoked || signers[0].Expires.Format(time.RFC3339) != ts(time.Hour) {
+		t.Fatalf("expected a single renewed authorization, got %+v", signers)
+	}
+}
+
+func TestRevocationReason(t *testing.T) {
+	s := newTestNetwork(t)
+	accredit(t, s, "accr")
+	mustInvoke(t, s, "ab", "revoke_signing_accreditation", "accr", ts(0), "Audit failed")
+
+	if reason := getTestAccreditation(t, s, "accr").RevocationReason; reason != "Audit failed" {
+		t.Fatalf("expected stored reason, got %q", reason)
+	}
+
+	var event AccreditationRevokedEvent
+	if err := json.Unmarshal(s.payload, &event); err != nil || event.RevocationReason != "Audit failed" {
+		t.Fatalf("expected reason in event, got %+v (%v)", event, err)
+	}
+
+	var accreditation SigningAccreditation
+	if err := json.Unmarshal(mustQuery(t, s, "get_accreditation", "accr"), &accreditation); err != nil || accreditation.RevocationReason != "Audit failed" {
+		t.Fatalf("expected reason in get_accreditation, got %+v (%v)", accreditation, err)
+	}
+
+	var validity Validity
+	if err := json.Unmarshal(mustQuery(t, s, "is_accreditation_valid", "accr"), &validity); err != nil || !strings.HasSuffix(validity.Reason, ": Audit failed") {
+		t.Fatalf("expected reason in validity, got %+v (%v)", validity, err)
+	}
+}
+
+func TestCertifyExpiryUsesTransactionTime(t *testing.T) {
+	// the transaction time lies years before the wall clock, so comparing with the wall clock would expire everything
+	s := expiringAccreditation(t)
+	s.now = testNow.Add(30 * time.Minute)
+	mustInvoke(t, s, "farm", "certify_grapes", testUUID(1), "accr", s.now.Format(time.RFC3339))
+
+	s = expiringAccreditation(t)
+	s.now = testNow.Add(2 * time.Hour)
+	_, err := s.invoke("farm", "certify_grapes", testUUID(1), "accr", s.now.Format(time.RFC3339))
+	expectError(t, err, "expired")
+}
+
+func TestEndorsementsAgreeOnExpiry(t *testing.T) {
+	// two peers endorse the same transaction at the expiry boundary
+	peers := []*testStub{expiringAccreditation(t), expiringAccreditation(t)}
+	var results []string
+	for _, peer := range peers {
+		peer.now = testNow.Add(time.Hour + clockSkew)
+		result, err := peer.invoke("farm", "certify_grapes", testUUID(1), "accr", peer.now.Format(time.RFC3339))
+		results = append(results, fmt.Sprintf("%s %v", result, err))
+	}
+
+	if results[0] != results[1] {
+		t.Fatalf("endorsements disagree: %q and %q", results[0], results[1])
+	}
+	if !bytes.Equal(peers[0].State[grapesKey(testUUID(1))], peers[1].State[grapesKey(testUUID(1))]) {
+		t.Fatalf("endorsements wrote different grapes state")
+	}
+}
+
+func TestTimestampWindow(t *testing.T) {
+	s := newTestNetwork(t)
+
+	_, err := s.invoke("farm", "create_grapes", testUUID(1), ts(-timestampWindow-time.Second), "100")
+	expectError(t, err, "is not within 5m0s of transaction time")
+
+	_, err = s.invoke("farm", "create_grapes", testUUID(1), ts(24*time.Hour), "100")
+	expectError(t, err, "is not within 5m0s of transaction time")
+
+	mustInvoke(t, s, "farm", "create_grapes", testUUID(1), ts(-timestampWindow), "100")
+	mustInvoke(t, s, "farm", "transfer_grapes", testUUID(1), "trader", ts(timestampWindow))
+}
+
+func getTestVerification(t *testing.T, s *testStub, uuid string) GrapesVerification {
+	var verification GrapesVerification
+	if err := json.Unmarshal(mustQuery(t, s, "verify_grapes", uuid), &verification); err != nil {
+		t.Fatal(err)
+	}
+	return verification
+}
+
+func TestVerifyGrapes(t *testing.T) {
+	s := newTestNetwork(t)
+	accredit(t, s, "accr")
+	mustInvoke(t, s, "farm", "create_grapes", testUUID(1), ts(0), "100")
+	mustInvoke(t, s, "farm", "certify_grapes", testUUID(1), "accr", ts(time.Minute))
+
+	verification := getTestVerification(t, s, testUUID(1))
+	if !verification.Certified || len(verification.Details) != 1 || !verification.Details[0].Valid {
+		t.Fatalf("expected valid certification, got %+v", verification)
+	}
+
+	_, err := s.query("", "verify_grapes", testUUID(2))
+	expectError(t, err, "grapes not found")
+}
+
+func TestVerifyGrapesRevokedSignature(t *testing.T) {
+	s := newTestNetwork(t)
+	accredit(t, s, "accr")
+	mustInvoke(t, s, "farm", "create_grapes", testUUID(1), ts(0), "100")
+	mustInvoke(t, s, "farm", "certify_grapes", testUUID(1), "accr", ts(time.Minute))
+	mustInvoke(t, s, "auditor", "revoke_signature", testUUID(1), "accr", ts(2*time.Minute))
+
+	verification := getTestVerification(t, s, testUUID(1))
+	if verification.Certified || len(verification.Details) != 1 || verification.Details[0].Valid {
+		t.Fatalf("expected revoked signature to be invalid, got %+v", verification)
+	}
+	if !strings.Contains(verification.Details[0].Reason, "Signature revoked") {
+		t.Fatalf("unexpected reason %q", verification.Details[0].Reason)
+	}
+}
+
+func TestVerifyGrapesExpiredAfterSigning(t *testing.T) {
+	s := expiringAccreditation(t)
+	mustInvoke(t, s, "farm", "certify_grapes", testUUID(1), "accr", ts(time.Minute))
+	if !getTestVerification(t, s, testUUID(1)).Certified {
+		t.Fatal("expected grapes to be certified before expiry")
+	}
+
+	s.now = testNow.Add(2 * time.Hour)
+	verification := getTestVerification(t, s, testUUID(1))
+	if verification.Certified || verification.Details[0].Valid {
+		t.Fatalf("expected expired accreditation to be invalid, got %+v", verification)
+	}
+	if !strings.Contains(verification.Details[0].Reason, "Expired at") {
+		t.Fatalf("unexpected reason %q", verification.Details[0].Reason)
+	}
+}
+
+func TestRoleAdminAddsPartyOfRole(t *testing.T) {
+	s := newTestStub(t)
+	mustInvoke(t, s, "admin", "add_role_admin", "Farm", encodeCert("farmadmin"))
+
+	mustInvoke(t, s, "farmadmin", "add_party", "farm", "Farm", encodeCert("farm"))
+	var party Party
+	if err := json.Unmarshal(mustQuery(t, s, "get_party", "farm"), &party); err != nil {
+		t.Fatal(err)
+	}
+	if party.Role != "Farm" {
+		t.Fatalf("expected farm to be added as Farm, got %+v", party)
+	}
+
+	_, err := s.invoke("farmadmin", "add_party", "trader", "Trader", encodeCert("trader"))
+	expectError(t, err, "The caller is not an admin of role Trader")
+	_, err = s.invoke("trader", "add_party", "farm2", "Farm", encodeCert("farm2"))
+	expectError(t, err, "The caller is not an admin of role Farm")
+}
+
+func TestAddRoleAdmin(t *testing.T) {
+	s := newTestStub(t)
+	_, err := s.invoke("admin", "add_role_admin", "Vineyard", encodeCert("farmadmin"))
+	expectError(t, err, "Incorrect role")
+	_, err = s.invoke("farmadmin", "add_role_admin", "Farm", encodeCert("farmadmin"))
+	expectError(t, err, "The caller is not an admin")
+}
+
+func TestRemoveAdmin(t *testing.T) {
+	s := newTestStub(t)
+	mustInvoke(t, s, "admin", "add_admin", encodeCert("admin2"))
+	mustInvoke(t, s, "admin2", "remove_admin", encodeCert("admin"))
+
+	_, err := s.invoke("admin", "add_party", "farm", "Farm", encodeCert("farm"))
+	expectError(t, err, "The caller is not an admin")
+	mustInvoke(t, s, "admin2", "add_party", "farm", "Farm", encodeCert("farm"))
+
+	_, err = s.invoke("admin2", "remove_admin", encodeCert("admin"))
+	expectError(t, err, "Certificate is not an admin certificate")
+}
+
+func TestRemoveAdminInOtherEncoding(t *testing.T) {
+	s := newTestStub(t)
+	mustInvoke(t, s, "admin", "add_admin", encodeCert("admin2"))
+
+	cert := encodeCert("admin")
+	mustInvoke(t, s, "admin2", "remove_admin", cert[:4]+"\n"+cert[4:])
+	if certs, err := s.cc.getAdminCerts(s); err != nil || len(certs) != 1 || certs[0] != encodeCert("admin2") {
+		t.Fatalf("expected only admin2 to remain, got %v (%v)", certs, err)
+	}
+
+	_, err := s.invoke("admin2", "remove_admin", "not base64!")
+	expectError(t, err, "Invalid certificate")
+}
+
+func TestRemoveLastAdmin(t *testing.T) {
+	s := newTestStub(t)
+	_, err := s.invoke("admin", "remove_admin", encodeCert("admin"))
+	expectError(t, err, "Cannot remove the last admin certificate")
+	mustInvoke(t, s, "admin", "add_party", "farm", "Farm", encodeCert("farm"))
+}
+
+func TestAddAdminTwice(t *testing.T) {
+	s := newTestStub(t)
+	mustInvoke(t, s, "admin", "add_admin", encodeCert("admin"))
+
+	certs, err := s.cc.getAdminCerts(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(certs) != 1 {
+		t.Fatalf("expected one admin certificate, got %v", certs)
+	}
+}
+
+func TestAddAdminTwiceInOtherEncoding(t *testing.T) {
+	s := newTestStub(t)
+	cert := encodeCert("admin")
+	mustInvoke(t, s, "admin", "add_admin", cert[:4]+"\n"+cert[4:])
+
+	certs, err := s.cc.getAdminCerts(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(certs) != 1 || certs[0] != cert {
+		t.Fatalf("expected one admin certificate, got %v", certs)
+	}
+
+	_, err = s.invoke("admin", "add_admin", "not base64!")
+	expectError(t, err, "Failed adding to AdminCerts array: Invalid certificate")
+}
+
+func TestAddCertTwice(t *testing.T) {
+	s := newTestNetwork(t)
+	mustInvoke(t, s, "farm", "add_cert", encodeCert("farm-tcert"))
+	mustInvoke(t, s, "farm", "add_cert", encodeCert("farm-tcert"))
+
+	party, err := s.cc.getParty(s, "farm")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(party.Certs) != 2 {
+		t.Fatalf("expected two certificates, got %v", party.Certs)
+	}
+}
+
+func TestPartyCerts(t *testing.T) {
+	s := newTestNetwork(t)
+	mustInvoke(t, s, "farm", "add_cert", encodeCert("farm-tcert"))
+
+	var certs []string
+	if err := json.Unmarshal(mustAdminQuery(t, s, "party_certs", "farm"), &certs); err != nil {
+		t.Fatal(err)
+	}
+	if len(certs) != 2 || certs[0] != encodeCert("farm") || certs[1] != encodeCert("farm-tcert") {
+		t.Fatalf("unexpected certificates %v", certs)
+	}
+
+	_, err := s.query("farm", "party_certs", "farm")
+	expectError(t, err, "Caller is not an admin")
+	_, err = s.query("admin", "party_certs", "unknown")
+	expectError(t, err, "Error determining party")
+}
+
+func TestOwnershipRecordsTransferringParty(t *testing.T) {
+	s := newTestNetwork(t)
+	mustInvoke(t, s, "farm", "create_grapes", testUUID(1), ts(0), "100")
+	mustInvoke(t, s, "farm", "transfer_grapes", testUUID(1), "trader", ts(time.Minute))
+	mustInvoke(t, s, "trader", "transfer_grapes", testUUID(1), "winery", ts(2*time.Minute))
+
+	var trail OwnershipTrail
+	if err := json.Unmarshal(mustQuery(t, s, "grape_ownership_trail", testUUID(1)), &trail); err != nil {
+		t.Fatal(err)
+	}
+	if len(trail.Ownership) != 3 {
+		t.Fatalf("expected 3 ownership entries, got %+v", trail.Ownership)
+	}
+	for i, transferredBy := range []string{"", "farm", "trader"} {
+		if trail.Ownership[i].TransferredBy != transferredBy {
+			t.Fatalf("expected entry %d transferred by %q, got %+v", i, transferredBy, trail.Ownership[i])
+		}
+	}
+}
+
+func TestSplitGrapes(t *testing.T) {
+	s := newTestNetwork(t)
+	accredit(t, s, "accr")
+	mustInvoke(t, s, "farm", "create_grapes", testUUID(1), ts(0), "100")
+	mustInvoke(t, s, "farm", "certify_grapes", testUUID(1), "accr", ts(time.Minute))
+	mustInvoke(t, s, "farm", "transfer_grapes", testUUID(1), "trader", ts(2*time.Minute))
+
+	children := fmt.Sprintf(`[{"UUID":%q,"Amount":50},{"UUID":%q,"Amount":30},{"UUID":%q,"Amount":20}]`, testUUID(2), testUUID(3), testUUID(4))
+	_, err := s.invoke("farm", "split_grapes", testUUID(1), children, ts(3*time.Minute))
+	expectError(t, err, "Caller is not the current owner")
+	mustInvoke(t, s, "trader", "split_grapes", testUUID(1), children, ts(3*time.Minute))
+
+	if parent := getTestGrapes(t, s, testUUID(1)); parent.Status != statusConsumed {
+		t.Fatalf("expected parent to be consumed, got %+v", parent)
+	}
+	for _, uuid := range []string{testUUID(2), testUUID(3), testUUID(4)} {
+		child := getTestGrapes(t, s, uuid)
+		if len(child.ParentUUIDs) != 1 || child.ParentUUIDs[0] != testUUID(1) {
+			t.Fatalf("expected %s to be split from %s, got %+v", uuid, testUUID(1), child.ParentUUIDs)
+		}
+		if len(child.Ownership) != 2 || child.Ownership[1].PartyID != "trader" {
+			t.Fatalf("expected %s to inherit the ownership trail, got %+v", uuid, child.Ownership)
+		}
+		if len(child.AccreditationSignatures) != 1 || child.AccreditationSignatures[0].AccreditationID != "accr" {
+			t.Fatalf("expected %s to inherit the signatures, got %+v", uuid, child.AccreditationSignatures)
+		}
+	}
+
+	_, err = s.invoke("trader", "transfer_grapes", testUUID(1), "winery", ts(4*time.Minute))
+	expectError(t, err, "are consumed")
+}
+
+func TestSplitGrapesAmounts(t *testing.T) {
+	s := newTestNetwork(t)
+	mustInvoke(t, s, "farm", "create_grapes", testUUID(1), ts(0), "100")
+
+	children := fmt.Sprintf(`[{"UUID":%q,"Amount":50},{"UUID":%q,"Amount":40}]`, testUUID(2), testUUID(3))
+	_, err := s.invoke("farm", "split_grapes", testUUID(1), children, ts(time.Minute))
+	expectError(t, err, "add up to 90, expecting 100")
+	children = fmt.Sprintf(`[{"UUID":%q,"Amount":100}]`, testUUID(2))
+	_, err = s.invoke("farm", "split_grapes", testUUID(1), children, ts(time.Minute))
+	expectError(t, err, "expecting at least 2")
+}
+
+func TestMergeGrapes(t *testing.T) {
+	s := newTestNetwork(t)
+	accredit(t, s, "accr")
+	accredit(t, s, "accr2")
+	mustInvoke(t, s, "farm", "create_grapes", testUUID(1), ts(0), "100")
+	mustInvoke(t, s, "farm", "create_grapes", testUUID(2), ts(0), "50")
+	mustInvoke(t, s, "farm", "certify_grapes", testUUID(1), "accr", ts(time.Minute))
+	mustInvoke(t, s, "farm", "certify_grapes", testUUID(1), "accr2", ts(time.Minute))
+	mustInvoke(t, s, "farm", "certify_grapes", testUUID(2), "accr", ts(time.Minute))
+
+	mustInvoke(t, s, "farm", "merge_grapes", testUUID(3), fmt.Sprintf("[%q,%q]", testUUID(1), testUUID(2)), ts(2*time.Minute))
+
+	merged := getTestGrapes(t, s, testUUID(3))
+	if merged.Amount != 150 || len(merged.ParentUUIDs) != 2 {
+		t.Fatalf("expected 150 merged from two units, got %+v", merged)
+	}
+	if len(merged.AccreditationSignatures) != 1 || merged.AccreditationSignatures[0].AccreditationID != "accr" {
+		t.Fatalf("expected only the common accreditation, got %+v", merged.AccreditationSignatures)
+	}
+	if owner := merged.Ownership[len(merged.Ownership)-1]; owner.PartyID != "farm" {
+		t.Fatalf("expected farm to own the merged grapes, got %+v", merged.Ownership)
+	}
+	for _, uuid := range []string{testUUID(1), testUUID(2)} {
+		if source := getTestGrapes(t, s, uuid); source.Status != statusConsumed {
+			t.Fatalf("expected %s to be consumed, got %+v", uuid, source)
+		}
+	}
+}
+
+func TestMergeGrapesOfDifferentOwners(t *testing.T) {
+	s := newTestNetwork(t)
+	mustInvoke(t, s, "farm", "create_grapes", testUUID(1), ts(0), "100")
+	mustInvoke(t, s, "farm", "create_grapes", testUUID(2), ts(0), "50")
+	mustInvoke(t, s, "farm", "transfer_grapes", testUUID(2), "trader", ts(time.Minute))
+
+	_, err := s.invoke("farm", "merge_grapes", testUUID(3), fmt.Sprintf("[%q,%q]", testUUID(1), testUUID(2)), ts(2*time.Minute))
+	expectError(t, err, "Caller is not the current owner of the grapes: "+testUUID(2))
+	if grapes := getTestGrapes(t, s, testUUID(1)); grapes.Status == statusConsumed {
+		t.Fatalf("expected %s to stay active, got %+v", testUUID(1), grapes)
+	}
+}
+
+func TestCreateGrapesPositiveAmount(t *testing.T) {
+	s := newTestNetwork(t)
+	for _, amount := range []string{"0", "-5", "many"} {
+		_, err := s.invoke("farm", "create_grapes", testUUID(1), ts(0), amount)
+		expectError(t, err, "expecting a positive number")
+	}
+	batch := fmt.Sprintf(`[{"UUID":%q,"Created":%q,"Amount":0}]`, testUUID(1), ts(0))
+	_, err := s.invoke("farm", "create_grapes_batch", batch)
+	expectError(t, err, "expecting a positive number")
+
+	mustInvoke(t, s, "farm", "create_grapes", testUUID(1), ts(0), "100")
+	mustInvoke(t, s, "farm", "create_grapes", testUUID(2), ts(0), "3", "", "t")
+	if unit := getTestGrapes(t, s, testUUID(1)).Unit; unit != defaultUnit {
+		t.Fatalf("expected default unit %s, got %s", defaultUnit, unit)
+	}
+	if unit := getTestGrapes(t, s, testUUID(2)).Unit; unit != "t" {
+		t.Fatalf("expected unit t, got %s", unit)
+	}
+}
+
+func TestSplitMergeConserveAmount(t *testing.T) {
+	s := newTestNetwork(t)
+	mustInvoke(t, s, "farm", "create_grapes", testUUID(1), ts(0), "100", "", "t")
+	mustInvoke(t, s, "farm", "create_grapes", testUUID(2), ts(0), "100")
+
+	children := fmt.Sprintf(`[{"UUID":%q,"Amount":60},{"UUID":%q,"Amount":40}]`, testUUID(3), testUUID(4))
+	mustInvoke(t, s, "farm", "split_grapes", testUUID(1), children, ts(time.Minute))
+	for uuid, amount := range map[string]int{testUUID(3): 60, testUUID(4): 40} {
+		if child := getTestGrapes(t, s, uuid); child.Amount != amount || child.Unit != "t" {
+			t.Fatalf("expected %d t in %s, got %+v", amount, uuid, child)
+		}
+	}
+
+	_, err := s.invoke("farm", "merge_grapes", testUUID(5), fmt.Sprintf("[%q,%q]", testUUID(2), testUUID(3)), ts(2*time.Minute))
+	expectError(t, err, "measured in t, cannot merge with kg")
+
+	mustInvoke(t, s, "farm", "merge_grapes", testUUID(5), fmt.Sprintf("[%q,%q]", testUUID(3), testUUID(4)), ts(2*time.Minute))
+	if merged := getTestGrapes(t, s, testUUID(5)); merged.Amount != 100 || merged.Unit != "t" {
+		t.Fatalf("expected 100 t after merging, got %+v", merged)
+	}
+}
+
+func TestOwnershipCoordinates(t *testing.T) {
+	s := newTestNetwork(t)
+	mustInvoke(t, s, "farm", "create_grapes", testUUID(1), ts(0), "100", "", "", "44.84", "-0.58")
+	mustInvoke(t, s, "farm", "transfer_grapes", testUUID(1), "trader", ts(time.Minute))
+
+	ownership := getTestGrapes(t, s, testUUID(1)).Ownership
+	if ownership[0].Latitude == nil || *ownership[0].Latitude != 44.84 || *ownership[0].Longitude != -0.58 {
+		t.Fatalf("expected coordinates on the producer's entry, got %+v", ownership[0])
+	}
+	if ownership[1].Latitude != nil || ownership[1].Longitude != nil {
+		t.Fatalf("expected no coordinates without arguments, got %+v", ownership[1])
+	}
+}
+
+func TestOwnershipCoordinatesOutOfRange(t *testing.T) {
+	s := newTestNetwork(t)
+	_, err := s.invoke("farm", "create_grapes", testUUID(1), ts(0), "100", "", "", "90.5", "0")
+	expectError(t, err, "Invalid latitude 90.5")
+
+	mustInvoke(t, s, "farm", "create_grapes", testUUID(1), ts(0), "100")
+	_, err = s.invoke("farm", "transfer_grapes", testUUID(1), "trader", ts(time.Minute), "0", "-180.1")
+	expectError(t, err, "Invalid longitude -180.1")
+	_, err = s.invoke("farm", "transfer_grapes", testUUID(1), "trader", ts(time.Minute), "north", "0")
+	expectError(t, err, "Invalid latitude north")
+}
+
+func TestGrapesByProducer(t *testing.T) {
+	s := newTestNetwork(t)
+	mustInvoke(t, s, "admin", "add_party", "farm_b", "Farm", encodeCert("farm_b"))
+	mustInvoke(t, s, "farm", "create_grapes", testUUID(1), ts(0), "100")
+	mustInvoke(t, s, "farm2", "create_grapes", testUUID(2), ts(0), "100")
+	mustInvoke(t, s, "farm_b", "create_grapes", testUUID(3), ts(0), "100")
+	mustInvoke(t, s, "farm", "create_grapes", testUUID(4), ts(0), "100")
+
+	uuids := grapesUUIDs(t, mustQuery(t, s, "grapes_by_producer", "farm"))
+	if len(uuids) != 2 || uuids[0] != testUUID(1) || uuids[1] != testUUID(4) {
+		t.Fatalf("expected only grapes of farm, got %v", uuids)
+	}
+	if uuids := grapesUUIDs(t, mustQuery(t, s, "grapes_by_producer", "trader")); len(uuids) != 0 {
+		t.Fatalf("expected no grapes of trader, got %v", uuids)
+	}
+}
+
+func TestProducerIndexSeparatesIDs(t *testing.T) {
+	s := newTestNetwork(t)
+	mustInvoke(t, s, "admin", "add_party", "farm_b", "Farm", encodeCert("farm_b"))
+	mustInvoke(t, s, "farm", "create_grapes", testUUID(1), ts(0), "100")
+	mustInvoke(t, s, "farm_b", "create_grapes", testUUID(2), ts(0), "100")
+	mustInvoke(t, s, "farm_b", "create_grapes", testUUID(3), ts(0), "100")
+	mustInvoke(t, s, "farm", "create_grapes", testUUID(4), ts(0), "100")
+
+	entries, err := getStateByPrefix(s, producerIndexPrefix("farm"))
+	if err != nil || len(entries) != 2 {
+		t.Fatalf("expected only the index entries of farm, got %d (%v)", len(entries), err)
+	}
+
+	// index entries written with the old separator are still found
+	_, err = s.transact("admin", func() ([]byte, error) {
+		for _, entry := range [][]string{{"farm", testUUID(4)}, {"farm_b", testUUID(3)}} {
+			if err := s.DelState(producerIndexKey(entry[0], entry[1])); err != nil {
+				return nil, err
+			}
+			if err := s.PutState(legacyProducerIndexPrefix(entry[0])+entry[1], []byte(entry[1])); err != nil {
+				return nil, err
+			}
+		}
+		return nil, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := map[string][]string{
+		"farm":   {testUUID(1), testUUID(4)},
+		"farm_b": {testUUID(2), testUUID(3)},
+	}
+	for producer, expected := range tests {
+		uuids := grapesUUIDs(t, mustQuery(t, s, "grapes_by_producer", producer))
+		if strings.Join(uuids, ",") != strings.Join(expected, ",") {
+			t.Fatalf("expected grapes %v of %s, got %v", expected, producer, uuids)
+		}
+	}
+
+	_, err = s.invoke("admin", "add_party", "farm\x00b", "Farm", encodeCert("farm-nul"))
+	expectError(t, err, "party ID must not contain NUL characters")
+}
+
+func TestUncertifiedGrapes(t *testing.T) {
+	s := newTestNetwork(t)
+	accredit(t, s, "accr")
+	mustInvoke(t, s, "ab", "add_signing_accreditation", "expiring", "Organic", ts(-time.Hour), ts(time.Hour))
+	mustInvoke(t, s, "ab", "issue_signing_accreditation", "expiring", "cb")
+	mustInvoke(t, s, "cb", "grant_signing_authority", "expiring", "farm", ts(time.Hour))
+	for i := 1; i <= 4; i++ {
+		mustInvoke(t, s, "farm", "create_grapes", testUUID(i), ts(0), "100")
+	}
+	mustInvoke(t, s, "farm", "certify_grapes", testUUID(1), "accr", ts(time.Minute))
+	mustInvoke(t, s, "farm", "certify_grapes", testUUID(3), "accr", ts(time.Minute))
+	mustInvoke(t, s, "auditor", "revoke_signature", testUUID(3), "accr", ts(2*time.Minute))
+	mustInvoke(t, s, "farm", "certify_grapes", testUUID(4), "expiring", ts(time.Minute))
+
+	uuids := grapesUUIDs(t, mustQuery(t, s, "uncertified_grapes"))
+	if len(uuids) != 2 || uuids[0] != testUUID(2) || uuids[1] != testUUID(3) {
+		t.Fatalf("expected grapes without signature or with revoked signature, got %v", uuids)
+	}
+
+	s.now = testNow.Add(2 * time.Hour)
+	uuids = grapesUUIDs(t, mustQuery(t, s, "uncertified_grapes"))
+	if len(uuids) != 3 || uuids[2] != testUUID(4) {
+		t.Fatalf("expected grapes signed under an expired accreditation, got %v", uuids)
+	}
+}
+
+func TestEmptyIdentifiers(t *testing.T) {
+	s := newTestNetwork(t)
+	mustInvoke(t, s, "farm", "create_grapes", testUUID(1), ts(0), "100")
+	mustInvoke(t, s, "farm", "create_grapes", testUUID(2), ts(0), "100")
+	calls := []struct {
+		caller   string
+		function string
+		args     []string
+		err      string
+	}{
+		{"admin", "add_admin", []string{""}, "certificate must not be empty"},
+		{"admin", "add_role_admin", []string{"Farm", " "}, "certificate must not be empty"},
+		{"admin", "add_party", []string{"", "Farm", encodeCert("farm3")}, "party ID must not be empty"},
+		{"admin", "add_party", []string{"farm3", "Farm", ""}, "certificate must not be empty"},
+		{"farm", "add_cert", []string{""}, "certificate must not be empty"},
+		{"ab", "add_signing_accreditation", []string{"", "Organic", ts(-time.Hour), ts(time.Hour)}, "accreditation ID must not be empty"},
+		{"farm", "create_grapes", []string{"", ts(0), "100"}, `UUID "" is not a canonical RFC4122 UUID`},
+		{"farm", "create_grapes_batch", []string{fmt.Sprintf(`[{"UUID":"","Created":%q,"Amount":100}]`, ts(0))}, `UUID "" is not a canonical RFC4122 UUID`},
+		{"farm", "split_grapes", []string{testUUID(1), `[{"UUID":"","Amount":50},{"UUID":"b","Amount":50}]`, ts(time.Minute)}, `UUID "" is not a canonical RFC4122 UUID`},
+		{"farm", "merge_grapes", []string{"", fmt.Sprintf("[%q,%q]", testUUID(1), testUUID(2)), ts(time.Minute)}, `UUID "" is not a canonical RFC4122 UUID`},
+		{"winery", "create_wine", []string{"", ts(0), fmt.Sprintf("[%q]", testUUID(1))}, "batch ID must not be empty"},
+	}
+	for _, call := range calls {
+		_, err := s.invoke(call.caller, call.function, call.args...)
+		expectError(t, err, call.err)
+	}
+}
+
+func TestCreateGrapesUUIDFormat(t *testing.T) {
+	s := newTestNetwork(t)
+	for _, uuid := range []string{"", "grapes-1", "123e4567-e89b-02d3-a456-426655440000", "123e4567e89b42d3a456426655440000"} {
+		_, err := s.invoke("farm", "create_grapes", uuid, ts(0), "100")
+		expectError(t, err, fmt.Sprintf("UUID %q is not a canonical RFC4122 UUID", uuid))
+	}
+	mustInvoke(t, s, "farm", "create_grapes", "123e4567-e89b-42d3-a456-426655440000", ts(0), "100")
+	mustInvoke(t, s, "farm", "create_grapes", "123E4567-E89B-12D3-B456-426655440000", ts(0), "100")
+}
+
+func TestGettersWithoutState(t *testing.T) {
+	// chaincode that was never initialized
+	cc := new(AgrifoodChaincode)
+	s := &testStub{MockStub: shim.NewMockStub("agrifood", cc), cc: cc, now: testNow}
+
+	grapes, err := cc.getGrapes(s)
+	if err != nil || grapes == nil || len(grapes) != 0 {
+		t.Fatalf("expected no grapes, got %v (%v)", grapes, err)
+	}
+	parties, err := cc.getParties(s)
+	if err != nil || parties == nil || len(parties) != 0 {
+		t.Fatalf("expected no parties, got %v (%v)", parties, err)
+	}
+	accreditations, err := cc.getSigningAccreditations(s)
+	if err != nil || accreditations == nil || len(accreditations) != 0 {
+		t.Fatalf("expected no accreditations, got %v (%v)", accreditations, err)
+	}
+	authorizations, err := cc.getSigningAuthorizations(s)
+	if err != nil || authorizations == nil || len(authorizations) != 0 {
+		t.Fatalf("expected no authorizations, got %v (%v)", authorizations, err)
+	}
+	certs, err := cc.getAdminCerts(s)
+	if err != nil || len(certs) != 0 {
+		t.Fatalf("expected no admin certificates, got %v (%v)", certs, err)
+	}
+}
+
+func TestKeysNamespaced(t *testing.T) {
+	id := testUUID(1)
+	keys := map[string]string{
+		grapesKey(id):        grapesKeyPrefix,
+		grapesHistoryKey(id): historyKeyPrefix,
+		partyKey(id):         partyKeyPrefix,
+		wineKey(id):          wineKeyPrefix,
+	}
+	if len(keys) != 4 {
+		t.Fatalf("expected distinct keys per asset type, got %v", keys)
+	}
+	for key, prefix := range keys {
+		if !strings.HasPrefix(key, prefix) {
+			t.Fatalf("expected key %s to start with %s", key, prefix)
+		}
+	}
+}
+
+func TestAssetsWithSameIDDoNotCollide(t *testing.T) {
+	s := newTestNetwork(t)
+	id := testUUID(1)
+	mustInvoke(t, s, "farm", "create_grapes", id, ts(0), "100")
+	mustInvoke(t, s, "farm", "transfer_grapes", id, "winery", ts(time.Minute))
+	mustInvoke(t, s, "admin", "add_party", id, "Trader", encodeCert("trader2"))
+	mustInvoke(t, s, "winery", "create_wine", id, ts(2*time.Minute), fmt.Sprintf("[%q]", id))
+
+	if grapes := getTestGrapes(t, s, id); grapes.Amount != 100 {
+		t.Fatalf("expected grapes to be unchanged, got %+v", grapes)
+	}
+	party, err := s.cc.getParty(s, id)
+	if err != nil || party.Role != "Trader" {
+		t.Fatalf("expected party %s to be a Trader, got %+v (%v)", id, party, err)
+	}
+	var wine WineBatch
+	if err = json.Unmarshal(mustQuery(t, s, "get_wine", id), &wine); err != nil {
+		t.Fatal(err)
+	}
+	if wine.BatchID != id {
+		t.Fatalf("unexpected wine batch %+v", wine)
+	}
+}
+
+func TestProduceByCropType(t *testing.T) {
+	s := newTestNetwork(t)
+	mustInvoke(t, s, "farm", "create_grapes", testUUID(1), ts(0), "100")
+	mustInvoke(t, s, "farm", "create_produce", "olives", testUUID(2), ts(0), "50")
+	mustInvoke(t, s, "farm", "create_grapes", testUUID(3), ts(0), "100", "olives")
+
+	_, err := s.invoke("farm", "create_produce", "", testUUID(4), ts(0), "50")
+	expectError(t, err, "crop type must not be empty")
+
+	if grapes := getTestGrapes(t, s, testUUID(1)); grapes.CropType != defaultCropType {
+		t.Fatalf("expected grapes by default, got %+v", grapes)
+	}
+	uuids := grapesUUIDs(t, mustQuery(t, s, "produce_by_crop_type", "grapes"))
+	if len(uuids) != 1 || uuids[0] != testUUID(1) {
+		t.Fatalf("expected only the grapes, got %v", uuids)
+	}
+	uuids = grapesUUIDs(t, mustQuery(t, s, "produce_by_crop_type", "olives"))
+	if len(uuids) != 2 || uuids[0] != testUUID(2) || uuids[1] != testUUID(3) {
+		t.Fatalf("expected only the olives, got %v", uuids)
+	}
+}
+
+func TestTransferGrapesBatch(t *testing.T) {
+	s := newTestNetwork(t)
+	mustInvoke(t, s, "farm", "create_grapes", testUUID(1), ts(0), "100")
+	mustInvoke(t, s, "farm", "create_grapes", testUUID(2), ts(0), "100")
+
+	mustInvoke(t, s, "farm", "transfer_grapes_batch", fmt.Sprintf(`[%q,%q]`, testUUID(1), testUUID(2)), "trader", ts(time.Minute))
+
+	for _, uuid := range []string{testUUID(1), testUUID(2)} {
+		unit := getTestGrapes(t, s, uuid)
+		if owner := unit.Ownership[len(unit.Ownership)-1].PartyID; owner != "trader" {
+			t.Fatalf("expected grapes %s to be owned by trader, got %s", uuid, owner)
+		}
+	}
+}
+
+func TestTransferGrapesBatchNotOwned(t *testing.T) {
+	s := newTestNetwork(t)
+	mustInvoke(t, s, "farm", "create_grapes", testUUID(1), ts(0), "100")
+	mustInvoke(t, s, "farm2", "create_grapes", testUUID(2), ts(0), "100")
+
+	_, err := s.invoke("farm", "transfer_grapes_batch", fmt.Sprintf(`[%q,%q]`, testUUID(1), testUUID(2)), "trader", ts(time.Minute))
+	expectError(t, err, "not the current owner")
+
+	// the owned unit isn't transferred either
+	if n := len(getTestGrapes(t, s, testUUID(1)).Ownership); n != 1 {
+		t.Fatalf("expected grapes %s to stay with farm, got %d ownership entries", testUUID(1), n)
+	}
+}
+
+func TestTransferGrapesBatchDuplicateUUID(t *testing.T) {
+	s := newTestNetwork(t)
+	mustInvoke(t, s, "farm", "create_grapes", testUUID(1), ts(0), "100")
+
+	_, err := s.invoke("farm", "transfer_grapes_batch", fmt.Sprintf(`[%q,%q]`, testUUID(1), testUUID(1)), "trader", ts(time.Minute))
+	expectError(t, err, "listed twice in batch")
+	_, err = s.invoke("farm", "transfer_grapes_batch", "[]", "trader", ts(time.Minute))
+	expectError(t, err, "no grape units to transfer")
+}
+
+func TestRenewExpiredAccreditation(t *testing.T) {
+	s := expiringAccreditation(t)
+	s.now = testNow.Add(2 * time.Hour)
+
+	_, err := s.invoke("ab2", "renew_signing_accreditation", "accr", "accr2", ts(2*time.Hour), ts(365*24*time.Hour))
+	expectError(t, err, "is not the issuer of this accreditation")
+	mustInvoke(t, s, "ab", "renew_signing_accreditation", "accr", "accr2", ts(2*time.Hour), ts(365*24*time.Hour))
+
+	predecessor := getTestAccreditation(t, s, "accr")
+	successor := getTestAccreditation(t, s, "accr2")
+	if predecessor.SupersededBy != "accr2" || successor.Supersedes != "accr" {
+		t.Fatalf("expected accr2 to renew accr, got %+v and %+v", predecessor, successor)
+	}
+	if successor.Description != predecessor.Description || successor.AccreditationBody != "ab" || successor.CertificationBody != "cb" {
+		t.Fatalf("expected successor to take over description and bodies, got %+v", successor)
+	}
+
+	_, err = s.invoke("ab", "renew_signing_accreditation", "accr", "accr3", ts(2*time.Hour), ts(365*24*time.Hour))
+	expectError(t, err, "already renewed by accr2")
+}
+
+func TestRenewAccreditationMigratesAuthorizations(t *testing.T) {
+	s := newTestNetwork(t)
+	accredit(t, s, "accr")
+	mustInvoke(t, s, "farm", "create_grapes", testUUID(1), ts(0), "100")
+
+	mustInvoke(t, s, "ab", "renew_signing_accreditation", "accr", "accr2", ts(0), ts(2*365*24*time.Hour), "true")
+	mustInvoke(t, s, "farm", "certify_grapes", testUUID(1), "accr2", ts(time.Minute))
+
+	// without the flag, authorizations stay with the predecessor
+	mustInvoke(t, s, "ab", "renew_signing_accreditation", "accr2", "accr3", ts(0), ts(2*365*24*time.Hour))
+	_, err := s.invoke("farm", "certify_grapes", testUUID(1), "accr3", ts(time.Minute))
+	expectError(t, err, "Unable to determine signing authorization")
+}
+
+// network of n farms calling as the last one, without certificate index when scan is set
+func newLookupNetwork(t testing.TB, n int, scan bool) *testStub {
+	s := newTestStub(t)
+	for i := 0; i < n; i++ {
+		id := fmt.Sprintf("farm%03d", i)
+		mustInvoke(t, s, "admin", "add_party", id, "Farm", encodeCert(id))
+	}
+	if scan {
+		s.transact("admin", func() ([]byte, error) {
+			for key := range s.State {
+				if strings.HasPrefix(key, certIndex) {
+					s.DelState(key)
+				}
+			}
+			return nil, nil
+		})
+	}
+	s.caller = []byte(fmt.Sprintf("farm%03d", n-1))
+	return s
+}
+
+func TestCallerPartyLookupVerifications(t *testing.T) {
+	for _, scan := range []bool{false, true} {
+		s := newLookupNetwork(t, 50, scan)
+		s.verifications = 0
+		party, err := s.cc.getCallerParty(s)
+		if err != nil || party.ID != "farm049" {
+			t.Fatalf("expected caller farm049, got %+v (%v)", party, err)
+		}
+		if expected := map[bool]int{false: 1, true: 50}[scan]; s.verifications != expected {
+			t.Fatalf("expected %d signature checks with scan %v, got %d", expected, scan, s.verifications)
+		}
+	}
+}
+
+func benchmarkCallerParty(b *testing.B, scan bool) {
+	s := newLookupNetwork(b, 200, scan)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := s.cc.getCallerParty(s); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkCallerPartyIndexed(b *testing.B) { benchmarkCallerParty(b, false) }
+func BenchmarkCallerPartyScan(b *testing.B)    { benchmarkCallerParty(b, true) }
+
+// new chaincode taking caller roles from certificate attributes, with one registered farm
+func newAttributeStub(t *testing.T) *testStub {
+	cc := new(AgrifoodChaincode)
+	s := &testStub{MockStub: shim.NewMockStub("agrifood", cc), cc: cc, now: testNow}
+	if _, err := s.init(encodeCert("admin"), "", "true"); err != nil {
+		t.Fatalf("Init failed: %s", err)
+	}
+	mustInvoke(t, s, "admin", "add_party", "farm", "Farm", encodeCert("farm"))
+	return s
+}
+
+func TestCertificateRolesFlag(t *testing.T) {
+	s := newTestNetwork(t)
+	s.attrs = map[string]string{roleAttribute: "Farm", partyAttribute: "farm9"}
+	_, err := s.invoke("farm9", "create_grapes", testUUID(1), ts(0), "100")
+	expectError(t, err, "Unknown caller")
+
+	s = newAttributeStub(t)
+	s.attrs = map[string]string{roleAttribute: "Farm", partyAttribute: "farm9"}
+	mustInvoke(t, s, "farm9", "create_grapes", testUUID(1), ts(0), "100")
+	if producer := getTestGrapes(t, s, testUUID(1)).Producer; producer != "farm9" {
+		t.Fatalf("expected grapes produced by farm9, got %s", producer)
+	}
+
+	// the setting is read back by a new instance
+	s.cc = new(AgrifoodChaincode)
+	mustInvoke(t, s, "farm9", "create_grapes", testUUID(2), ts(0), "100")
+
+	cc := new(AgrifoodChaincode)
+	s = &testStub{MockStub: shim.NewMockStub("agrifood", cc), cc: cc, now: testNow}
+	_, err = s.init(encodeCert("admin"), "", "maybe")
+	expectError(t, err, "Invalid certificate roles flag: maybe")
+}
+
+func TestCertificateRolesAttributes(t *testing.T) {
+	s := newAttributeStub(t)
+	s.attrs = map[string]string{partyAttribute: "farm"}
+	_, err := s.invoke("farm", "create_grapes", testUUID(1), ts(0), "100")
+	expectError(t, err, "Error reading role attribute")
+
+	s.attrs = map[string]string{roleAttribute: "Farm"}
+	_, err = s.invoke("farm", "create_grapes", testUUID(1), ts(0), "100")
+	expectError(t, err, "Error reading partyID attribute")
+
+	s.attrs = map[string]string{roleAttribute: "Vineyard", partyAttribute: "farm"}
+	_, err = s.invoke("farm", "create_grapes", testUUID(1), ts(0), "100")
+	expectError(t, err, "Unknown role Vineyard in caller certificate")
+}
+
+func TestCertificateRolesRegisteredParty(t *testing.T) {
+	s := newAttributeStub(t)
+
+	// the role of the certificate overrides the registered role
+	s.attrs = map[string]string{roleAttribute: "Trader", partyAttribute: "farm"}
+	_, err := s.invoke("farm", "create_grapes", testUUID(1), ts(0), "100")
+	expectError(t, err, "Caller (farm) is no Farm")
+
+	updateTestParty(t, s, "farm", func(party *Party) { party.Deactivated = true })
+	s.attrs = map[string]string{roleAttribute: "Farm", partyAttribute: "farm"}
+	_, err = s.invoke("farm", "create_grapes", testUUID(1), ts(0), "100")
+	expectError(t, err, "Party farm is deactivated")
+}
+
+func TestCanCertifyMatchesCertify(t *testing.T) {
+	s := newTestNetwork(t)
+	accredit(t, s, "accr")
+	accredit(t, s, "wine", `["wine"]`)
+	mustInvoke(t, s, "farm", "create_grapes", testUUID(1), ts(0), "100")
+	mustInvoke(t, s, "farm2", "create_grapes", testUUID(2), ts(0), "100")
+
+	cases := []struct {
+		caller, uuid, accreditation string
+	}{
+		{"farm", testUUID(1), "accr"},
+		{"trader", testUUID(1), "accr"},
+		{"farm", testUUID(3), "accr"},
+		{"farm", testUUID(1), "unknown"},
+		{"farm2", testUUID(2), "accr"},
+		{"farm", testUUID(1), "wine"},
+		// signed by the first case already
+		{"farm", testUUID(1), "accr"},
+	}
+	for _, c := range cases {
+		before := s.State[grapesKey(testUUID(1))]
+		var eligibility CertifyEligibility
+		result, err := s.query(c.caller, "can_certify", c.uuid, c.accreditation)
+		if err != nil {
+			t.Fatalf("can_certify failed: %s", err)
+		}
+		if err = json.Unmarshal(result, &eligibility); err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(s.State[grapesKey(testUUID(1))], before) {
+			t.Fatalf("expected can_certify not to change the grapes")
+		}
+
+		_, err = s.invoke(c.caller, "certify_grapes", c.uuid, c.accreditation, ts(time.Minute))
+		if eligibility.Eligible != (err == nil) {
+			t.Fatalf("%+v: can_certify says %+v, certify_grapes returned %v", c, eligibility, err)
+		}
+		if err != nil && (eligibility.Reason == "" || !strings.Contains(err.Error(), eligibility.Reason)) {
+			t.Fatalf("%+v: expected reason matching %q, got %q", c, err, eligibility.Reason)
+		}
+	}
+}
+
+func getTestSummary(t *testing.T, s *testStub, uuid string) CertificationSummary {
+	var summary CertificationSummary
+	if err := json.Unmarshal(mustQuery(t, s, "certification_summary", uuid), &summary); err != nil {
+		t.Fatal(err)
+	}
+	return summary
+}
+
+func TestCertificationSummary(t *testing.T) {
+	s := newTestNetwork(t)
+	accredit(t, s, "accr")
+	accredit(t, s, "accr2")
+	mustInvoke(t, s, "farm", "create_grapes", testUUID(1), ts(0), "100")
+
+	if summary := getTestSummary(t, s, testUUID(1)); summary != (CertificationSummary{}) {
+		t.Fatalf("expected empty summary without signatures, got %+v", summary)
+	}
+
+	mustInvoke(t, s, "farm", "certify_grapes", testUUID(1), "accr", ts(time.Minute))
+	summary := getTestSummary(t, s, testUUID(1))
+	if summary.TotalSignatures != 1 || summary.ActiveSignatures != 1 || !summary.LatestIssued.Equal(testNow.Add(time.Minute)) || summary.LatestIssuer != "farm" {
+		t.Fatalf("unexpected summary with one signature %+v", summary)
+	}
+
+	mustInvoke(t, s, "farm", "certify_grapes", testUUID(1), "accr2", ts(2*time.Minute))
+	mustInvoke(t, s, "auditor", "revoke_signature", testUUID(1), "accr", ts(3*time.Minute))
+	summary = getTestSummary(t, s, testUUID(1))
+	if summary.TotalSignatures != 2 || summary.ActiveSignatures != 1 || !summary.LatestIssued.Equal(testNow.Add(2*time.Minute)) {
+		t.Fatalf("unexpected summary with a revoked signature %+v", summary)
+	}
+
+	_, err := s.query("", "certification_summary", testUUID(2))
+	expectError(t, err, "Error determining grapesUnit")
+}
+
+func TestChangePartyRole(t *testing.T) {
+	s := newTestNetwork(t)
+	mustInvoke(t, s, "farm", "create_grapes", testUUID(1), ts(0), "100")
+
+	_, err := s.invoke("farm", "change_party_role", "farm", "Trader")
+	expectError(t, err, "The caller is not an admin")
+	_, err = s.invoke("admin", "change_party_role", "farm", "Retailer")
+	expectError(t, err, "Invalid role: Retailer")
+	_, err = s.invoke("admin", "change_party_role", "farm", "Farm")
+	expectError(t, err, "already has role Farm")
+
+	mustInvoke(t, s, "admin", "change_party_role", "farm", "Trader")
+
+	_, err = s.invoke("farm", "create_grapes", testUUID(2), ts(0), "100")
+	expectError(t, err, "Caller (farm) is no Farm")
+	mustInvoke(t, s, "farm", "create_wine", "wine", ts(time.Minute), fmt.Sprintf("[%q]", testUUID(1)))
+
+	party, err := s.cc.getParty(s, "farm")
+	if err != nil || len(party.Certs) != 1 || party.Certs[0] != encodeCert("farm") {
+		t.Fatalf("expected certificates to be kept, got %+v (%v)", party, err)
+	}
+}
+
+func TestGrantSigningAuthorityBeyondAccreditation(t *testing.T) {
+	s := newTestNetwork(t)
+	mustInvoke(t, s, "ab", "add_signing_accreditation", "accr", "Organic", ts(-time.Hour), ts(24*time.Hour))
+	mustInvoke(t, s, "ab", "issue_signing_accreditation", "accr", "cb")
+
+	_, err := s.invoke("cb", "grant_signing_authority", "accr", "farm", ts(25*time.Hour))
+	expectError(t, err, "is after expiration date "+ts(24*time.Hour)+" of accreditation accr")
+	_, err = s.invoke("cb", "grant_signing_authority_batch", "accr", `["farm"]`, ts(25*time.Hour))
+	expectError(t, err, "of accreditation accr")
+
+	mustInvoke(t, s, "cb", "grant_signing_authority", "accr", "farm", ts(24*time.Hour))
+}
+
+func TestRenewClampsMigratedAuthorizations(t *testing.T) {
+	s := newTestNetwork(t)
+	accredit(t, s, "accr")
+	mustInvoke(t, s, "ab", "renew_signing_accreditation", "accr", "accr2", ts(0), ts(30*24*time.Hour), "true")
+
+	var migrated SigningAuthorization
+	if err := json.Unmarshal(mustQuery(t, s, "get_granted_authorization", "accr2", "farm"), &migrated); err != nil {
+		t.Fatal(err)
+	}
+	if !migrated.Expires.Equal(testNow.Add(30 * 24 * time.Hour)) {
+		t.Fatalf("expected migrated authorization to expire with accr2, got %s", migrated.Expires)
+	}
+}
+
+func ownedGrapes(t *testing.T, s *testStub, partyID string) []string {
+	var uuids []string
+	if err := json.Unmarshal(mustQuery(t, s, "owned_grapes", partyID), &uuids); err != nil {
+		t.Fatal(err)
+	}
+	return uuids
+}
+
+func TestOwnedGrapes(t *testing.T) {
+	s := newTestNetwork(t)
+	for i := 1; i <= 3; i++ {
+		mustInvoke(t, s, "farm", "create_grapes", testUUID(i), ts(0), "100")
+	}
+	if uuids := ownedGrapes(t, s, "farm"); len(uuids) != 3 {
+		t.Fatalf("expected farm to own 3 units, got %v", uuids)
+	}
+	if uuids := ownedGrapes(t, s, "trader"); len(uuids) != 0 {
+		t.Fatalf("expected trader to own nothing, got %v", uuids)
+	}
+
+	mustInvoke(t, s, "farm", "transfer_grapes", testUUID(1), "trader", ts(time.Minute))
+	mustInvoke(t, s, "farm", "transfer_grapes", testUUID(3), "trader", ts(time.Minute))
+
+	if uuids := ownedGrapes(t, s, "farm"); len(uuids) != 1 || uuids[0] != testUUID(2) {
+		t.Fatalf("expected farm to keep %s, got %v", testUUID(2), uuids)
+	}
+	if uuids := ownedGrapes(t, s, "trader"); len(uuids) != 2 || uuids[0] != testUUID(1) || uuids[1] != testUUID(3) {
+		t.Fatalf("expected trader to own the transferred units, got %v", uuids)
+	}
+
+	// consumed grapes are no longer held
+	mustInvoke(t, s, "trader", "create_wine", "wine", ts(2*time.Minute), fmt.Sprintf("[%q]", testUUID(1)))
+	if uuids := ownedGrapes(t, s, "trader"); len(uuids) != 1 || uuids[0] != testUUID(3) {
+		t.Fatalf("expected trader to hold only %s, got %v", testUUID(3), uuids)
+	}
+}
+
+func getTestProvenance(t *testing.T, s *testStub, uuid string) ProvenanceVerification {
+	var verification ProvenanceVerification
+	if err := json.Unmarshal(mustQuery(t, s, "verify_provenance", uuid), &verification); err != nil {
+		t.Fatal(err)
+	}
+	return verification
+}
+
+func TestVerifyProvenance(t *testing.T) {
+	s := newTestNetwork(t)
+	mustInvoke(t, s, "farm", "create_grapes", testUUID(1), ts(0), "100")
+	mustInvoke(t, s, "farm", "transfer_grapes", testUUID(1), "trader", ts(time.Minute))
+	mustInvoke(t, s, "trader", "transfer_grapes", testUUID(1), "winery", ts(2*time.Minute))
+
+	if verification := getTestProvenance(t, s, testUUID(1)); !verification.Valid || len(verification.Problems) != 0 {
+		t.Fatalf("expected well-formed chain, got %+v", verification)
+	}
+
+	_, err := s.query("", "verify_provenance", testUUID(2))
+	expectError(t, err, "grapes not found")
+}
+
+func TestVerifyCorruptedProvenance(t *testing.T) {
+	s := newTestNetwork(t)
+	mustInvoke(t, s, "farm", "create_grapes", testUUID(1), ts(0), "100")
+	mustInvoke(t, s, "farm", "transfer_grapes", testUUID(1), "trader", ts(time.Minute))
+
+	grapes := getTestGrapes(t, s, testUUID(1))
+	grapes.Ownership[0].PartyID = "farm2"
+	grapes.Ownership[1].Timestamp = testNow.Add(-time.Minute)
+	grapes.Ownership = append(grapes.Ownership, OwnershipEntry{PartyID: "ghost", Timestamp: testNow.Add(time.Hour)})
+	_, err := s.transact("admin", func() ([]byte, error) { return nil, s.cc.saveGrapeUnit(s, grapes, false) })
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	verification := getTestProvenance(t, s, testUUID(1))
+	expected := []string{
+		"First owner farm2 is not producer farm",
+		"Entry 1 (" + ts(-time.Minute) + ") is not after entry 0 (" + ts(0) + ")",
+		"Entry 2 refers to unknown party ghost",
+	}
+	if verification.Valid || strings.Join(verification.Problems, "\n") != strings.Join(expected, "\n") {
+		t.Fatalf("expected problems %q, got %+v", expected, verification)
+	}
+}
+
+func TestRecallGrapes(t *testing.T) {
+	s := newTestNetwork(t)
+	mustInvoke(t, s, "farm", "create_grapes", testUUID(1), ts(0), "100")
+	mustInvoke(t, s, "farm", "transfer_grapes", testUUID(1), "trader", ts(time.Minute))
+
+	_, err := s.invoke("trader", "recall_grapes", testUUID(1), "Contaminated", ts(2*time.Minute))
+	expectError(t, err, "Caller (trader) is no Farm or Auditor")
+	_, err = s.invoke("farm2", "recall_grapes", testUUID(1), "Contaminated", ts(2*time.Minute))
+	expectError(t, err, "Caller is not producer of grapes")
+	_, err = s.invoke("farm", "recall_grapes", testUUID(1), " ", ts(2*time.Minute))
+	expectError(t, err, "recall reason can't be empty")
+
+	mustInvoke(t, s, "farm", "recall_grapes", testUUID(1), "Contaminated", ts(2*time.Minute))
+
+	grapes := getTestGrapes(t, s, testUUID(1))
+	if !grapes.Recalled || grapes.RecallReason != "Contaminated" || grapes.RecalledBy != "farm" || !grapes.RecallTimestamp.Equal(testNow.Add(2*time.Minute)) {
+		t.Fatalf("expected grapes to be recalled, got %+v", grapes)
+	}
+	if s.event != "grapes_recalled" {
+		t.Fatalf("expected event grapes_recalled, got %q", s.event)
+	}
+	var event GrapesRecalledEvent
+	if err = json.Unmarshal(s.payload, &event); err != nil {
+		t.Fatal(err)
+	}
+	if event.UUID != testUUID(1) || event.Producer != "farm" || event.RecalledBy != "farm" || event.Reason != "Contaminated" {
+		t.Fatalf("unexpected grapes_recalled payload %+v", event)
+	}
+
+	verification := getTestVerification(t, s, testUUID(1))
+	if !verification.Recalled || verification.RecallReason != "Contaminated" {
+		t.Fatalf("expected verify_grapes to report the recall, got %+v", verification)
+	}
+
+	_, err = s.invoke("auditor", "recall_grapes", testUUID(1), "Mislabelled", ts(3*time.Minute))
+	expectError(t, err, "already recalled: Contaminated")
+}
+
+func TestRecallAppliesToSplitGrapes(t *testing.T) {
+	s := newTestNetwork(t)
+	mustInvoke(t, s, "farm", "create_grapes", testUUID(1), ts(0), "100")
+	mustInvoke(t, s, "auditor", "recall_grapes", testUUID(1), "Contaminated", ts(time.Minute))
+
+	children := fmt.Sprintf(`[{"UUID":%q,"Amount":50},{"UUID":%q,"Amount":50}]`, testUUID(2), testUUID(3))
+	mustInvoke(t, s, "farm", "split_grapes", testUUID(1), children, ts(2*time.Minute))
+	if child := getTestGrapes(t, s, testUUID(2)); !child.Recalled || child.RecalledBy != "auditor" {
+		t.Fatalf("expected split grapes to stay recalled, got %+v", child)
+	}
+
+	mustInvoke(t, s, "farm", "create_grapes", testUUID(4), ts(0), "100")
+	mustInvoke(t, s, "farm", "merge_grapes", testUUID(5), fmt.Sprintf("[%q,%q]", testUUID(4), testUUID(3)), ts(3*time.Minute))
+	if merged := getTestGrapes(t, s, testUUID(5)); !merged.Recalled || merged.RecallReason != "Contaminated" {
+		t.Fatalf("expected merged grapes to be recalled, got %+v", merged)
+	}
+}
+
+func TestClearRecall(t *testing.T) {
+	s := newTestNetwork(t)
+	mustInvoke(t, s, "farm", "create_grapes", testUUID(1), ts(0), "100")
+
+	_, err := s.invoke("auditor", "clear_recall", testUUID(1), ts(time.Minute))
+	expectError(t, err, "are not recalled")
+
+	mustInvoke(t, s, "farm", "recall_grapes", testUUID(1), "Contaminated", ts(time.Minute))
+	_, err = s.invoke("farm", "clear_recall", testUUID(1), ts(2*time.Minute))
+	expectError(t, err, "Caller (farm) is no Auditor")
+	_, err = s.invoke("auditor", "clear_recall", testUUID(1), ts(time.Minute))
+	expectError(t, err, "is not after recall")
+
+	mustInvoke(t, s, "auditor", "clear_recall", testUUID(1), ts(2*time.Minute))
+
+	grapes := getTestGrapes(t, s, testUUID(1))
+	if grapes.Recalled || grapes.RecallClearedBy != "auditor" || !grapes.RecallCleared.Equal(testNow.Add(2*time.Minute)) || grapes.RecallReason != "Contaminated" {
+		t.Fatalf("expected recall to be cleared, got %+v", grapes)
+	}
+	if s.event != "recall_cleared" {
+		t.Fatalf("expected event recall_cleared, got %q", s.event)
+	}
+	var event RecallClearedEvent
+	if err = json.Unmarshal(s.payload, &event); err != nil {
+		t.Fatal(err)
+	}
+	if event.UUID != testUUID(1) || event.ClearedBy != "auditor" || !event.Timestamp.Equal(testNow.Add(2*time.Minute)) {
+		t.Fatalf("unexpected recall_cleared payload %+v", event)
+	}
+
+	_, err = s.invoke("auditor", "clear_recall", testUUID(1), ts(3*time.Minute))
+	expectError(t, err, "are not recalled")
+
+	// a new recall resets the cleared record
+	mustInvoke(t, s, "farm", "recall_grapes", testUUID(1), "Contaminated again", ts(3*time.Minute))
+	if grapes = getTestGrapes(t, s, testUUID(1)); grapes.RecallClearedBy != "" || !grapes.RecallCleared.IsZero() {
+		t.Fatalf("expected a new recall to reset the cleared record, got %+v", grapes)
+	}
+}
+
+func TestAssertCallerRoleFromAttributes(t *testing.T) {
+	s := newTestNetwork(t)
+	_, err := s.init(encodeCert("admin"), "", "true")
+	if err != nil {
+		t.Fatalf("Init failed: %s", err)
+	}
+
+	// the certificate role takes precedence over the registered role
+	s.caller = []byte("farm")
+	s.attrs = map[string]string{roleAttribute: "Trader", partyAttribute: "farm"}
+	party, err := s.cc.assertCallerRole(s, "Trader")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if party.ID != "farm" || party.Role != "Trader" {
+		t.Fatalf("expected farm as Trader, got %s as %s", party.ID, party.Role)
+	}
+
+	_, err = s.cc.assertCallerRole(s, "Farm")
+	expectError(t, err, "Caller (farm) is no Farm")
+
+	s.attrs = nil
+	_, err = s.cc.assertCallerRole(s, "Farm")
+	expectError(t, err, "Error determining party")
+}
+
+func TestRetireGrapesRoles(t *testing.T) {
+	s := newTestNetwork(t)
+	mustInvoke(t, s, "farm", "create_grapes", testUUID(1), ts(0), "100")
+
+	_, err := s.invoke("auditor", "retire_grapes", testUUID(1), statusDestroyed, ts(0))
+	expectError(t, err, "Caller (auditor) is no Farm or Trader or Winery")
+
+	_, err = s.invoke("unknown", "retire_grapes", testUUID(1), statusDestroyed, ts(0))
+	expectError(t, err, "Error determining party")
+
+	mustInvoke(t, s, "farm", "retire_grapes", testUUID(1), statusDestroyed, ts(0))
+	if status := getTestGrapes(t, s, testUUID(1)).Status; status != statusDestroyed {
+		t.Fatalf("expected status %s, got %s", statusDestroyed, status)
+	}
+}
+
+func TestRevokeExpiredAccreditationsRoles(t *testing.T) {
+	s := newTestNetwork(t)
+
+	mustInvoke(t, s, "admin", "revoke_expired_accreditations", ts(0))
+	mustInvoke(t, s, "auditor", "revoke_expired_accreditations", ts(0))
+
+	_, err := s.invoke("farm", "revoke_expired_accreditations", ts(0))
+	expectError(t, err, "Caller (farm) is no Auditor")
+}
+
+func TestCallerRoleFromAttributes(t *testing.T) {
+	s := newAttributeStub(t)
+	s.attrs = map[string]string{roleAttribute: "Trader", partyAttribute: "farm"}
+
+	var role CallerRole
+	result, err := s.query("farm", "get_caller_role")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = json.Unmarshal(result, &role); err != nil {
+		t.Fatal(err)
+	}
+	if role.Admin || role.Role != "Trader" {
+		t.Fatalf("expected the certificate role, got %+v", role)
+	}
+
+	// parties only known from their certificate attributes can split their grapes
+	s.attrs = map[string]string{roleAttribute: "Farm", partyAttribute: "farm9"}
+	mustInvoke(t, s, "farm9", "create_grapes", testUUID(1), ts(0), "100")
+	children := fmt.Sprintf(`[{"UUID":%q,"Amount":50},{"UUID":%q,"Amount":50}]`, testUUID(2), testUUID(3))
+	mustInvoke(t, s, "farm9", "split_grapes", testUUID(1), children, ts(time.Minute))
+}
+
+func TestGrapeHistoryEntryPerTransaction(t *testing.T) {
+	s := newTestNetwork(t)
+	accredit(t, s, "accr")
+	mustInvoke(t, s, "farm", "create_grapes", testUUID(1), ts(0), "100")
+	s.now = testNow.Add(time.Minute)
+	mustInvoke(t, s, "farm", "certify_grapes", testUUID(1), "accr", ts(time.Minute))
+
+	if _, ok := s.State[grapesHistoryKey(testUUID(1))]; ok {
+		t.Fatalf("history is stored under a single key")
+	}
+	entries_b, err := getStateByPrefix(s, grapesHistoryEntryKey(testUUID(1), ""))
+	if err != nil || len(entries_b) != 2 {
+		t.Fatalf("expected 2 history keys, got %d (%v)", len(entries_b), err)
+	}
+
+	var history []GrapesHistoryEntry
+	err = json.Unmarshal(mustQuery(t, s, "grape_history", testUUID(1)), &history)
+	if err != nil {
+		t.Fatalf("Error parsing history: %s", err)
+	}
+	if len(history) != 2 {
+		t.Fatalf("expected 2 history entries, got %d", len(history))
+	}
+	if len(history[0].Value.AccreditationSignatures) != 0 || len(history[1].Value.AccreditationSignatures) != 1 {
+		t.Fatalf("history entries are out of order")
+	}
+}
+
+func TestGrapeHistoryReadsSingleKeyHistory(t *testing.T) {
+	s := newTestNetwork(t)
+	mustInvoke(t, s, "farm", "create_grapes", testUUID(1), ts(0), "100")
+
+	// history written as a single array before entries got their own keys
+	legacy := []GrapesHistoryEntry{{TxID: "legacy", Timestamp: testNow.Add(-time.Hour), Value: ProduceUnit{UUID: testUUID(1)}}}
+	legacy_b, _ := json.Marshal(legacy)
+	_, err := s.transact("admin", func() ([]byte, error) { return nil, s.PutState(grapesHistoryKey(testUUID(1)), legacy_b) })
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var history []GrapesHistoryEntry
+	if err = json.Unmarshal(mustQuery(t, s, "grape_history", testUUID(1)), &history); err != nil {
+		t.Fatal(err)
+	}
+	if len(history) != 2 || history[0].TxID != "legacy" || history[1].Value.Producer != "farm" {
+		t.Fatalf("expected the single-key entry before the keyed entry, got %+v", history)
+	}
+}
+
+func TestCertifiedQuantityFollowsSignatures(t *testing.T) {
+	s := newTestNetwork(t)
+	accredit(t, s, "accr", `["grapes"]`, "150")
+	mustInvoke(t, s, "farm", "create_grapes", testUUID(1), ts(0), "100")
+	mustInvoke(t, s, "farm", "certify_grapes", testUUID(1), "accr", ts(0))
+
+	// a second signature would count the same grapes twice against the cap
+	_, err := s.invoke("farm", "certify_grapes", testUUID(1), "accr", ts(0))
+	expectError(t, err, "already certified under accreditation accr")
+	var eligibility CertifyEligibility
+	result, err := s.query("farm", "can_certify", testUUID(1), "accr")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = json.Unmarshal(result, &eligibility); err != nil {
+		t.Fatal(err)
+	}
+	if eligibility.Eligible || !strings.Contains(eligibility.Reason, "already certified under accreditation accr") {
+		t.Fatalf("expected can_certify to reject the second signature, got %+v", eligibility)
+	}
+
+	mustInvoke(t, s, "auditor", "revoke_signature", testUUID(1), "accr", ts(0))
+	if quantity := getTestAccreditation(t, s, "accr").CertifiedQuantity; quantity != 0 {
+		t.Fatalf("expected certified quantity 0 after revocation, got %d", quantity)
+	}
+
+	// the amount is released, so the unit can be certified again
+	mustInvoke(t, s, "farm", "certify_grapes", testUUID(1), "accr", ts(time.Minute))
+	if quantity := getTestAccreditation(t, s, "accr").CertifiedQuantity; quantity != 100 {
+		t.Fatalf("expected certified quantity 100, got %d", quantity)
+	}
+}
+
+func TestGrantSigningAuthorityToActiveFarm(t *testing.T) {
+	s := newTestNetwork(t)
+	accredit(t, s, "accr")
+
+	_, err := s.invoke("cb", "grant_signing_authority", "accr", "trader", ts(time.Hour))
+	expectError(t, err, "Party trader is no Farm")
+
+	mustInvoke(t, s, "admin", "remove_party", "farm2")
+	_, err = s.invoke("cb", "grant_signing_authority", "accr", "farm2", ts(time.Hour))
+	expectError(t, err, "Party farm2 is deactivated")
+}
+
+func TestGrantSigningAuthorityBatchSkipsDeactivated(t *testing.T) {
+	s := newTestNetwork(t)
+	accredit(t, s, "accr")
+	mustInvoke(t, s, "admin", "remove_party", "farm2")
+
+	var results []BatchResult
+	err := json.Unmarshal(mustInvoke(t, s, "cb", "grant_signing_authority_batch", "accr", `["farm","farm2","trader"]`, ts(time.Hour)), &results)
+	if err != nil {
+		t.Fatalf("Error parsing results: %s", err)
+	}
+	if len(results) != 3 || !results[0].Success || results[1].Success || results[2].Success {
+		t.Fatalf("expected only farm to be granted, got %+v", results)
+	}
+	if results[1].Message != "Party is deactivated" {
+		t.Fatalf("unexpected message for farm2: %s", results[1].Message)
+	}
+}
+
+func TestRenewMigrationRevokesOriginalAuthorizations(t *testing.T) {
+	s := newTestNetwork(t)
+	accredit(t, s, "accr")
+	mustInvoke(t, s, "ab", "renew_signing_accreditation", "accr", "accr2", ts(0), ts(2*365*24*time.Hour), "true")
+
+	var original, migrated SigningAuthorization
+	err := json.Unmarshal(mustQuery(t, s, "get_granted_authorization", "accr", "farm"), &original)
+	if err != nil {
+		t.Fatalf("Error parsing authorization: %s", err)
+	}
+	if !original.Revoked || original.RevocationReason != "Migrated to accr2" || !original.RevocationTimestamp.Equal(testNow) {
+		t.Fatalf("expected original authorization revoked by migration, got %+v", original)
+	}
+
+	err = json.Unmarshal(mustQuery(t, s, "get_granted_authorization", "accr2", "farm"), &migrated)
+	if err != nil {
+		t.Fatalf("Error parsing authorization: %s", err)
+	}
+	if migrated.Revoked {
+		t.Fatalf("expected migrated authorization to be active, got %+v", migrated)
+	}
+}
+
+func TestTransferAccreditationToActiveBody(t *testing.T) {
+	s := newTestNetwork(t)
+	accredit(t, s, "accr")
+
+	_, err := s.invoke("admin", "transfer_accreditation", "ab", "cb", `["accr"]`)
+	expectError(t, err, "supplied party is no AccreditationBody: cb")
+
+	mustInvoke(t, s, "admin", "remove_party", "ab2")
+	_, err = s.invoke("admin", "transfer_accreditation", "ab", "ab2", `["accr"]`)
+	expectError(t, err, "new accreditation body ab2 is deactivated")
+
+	if body := getTestAccreditation(t, s, "accr").AccreditationBody; body != "ab" {
+		t.Fatalf("expected accreditation to stay with ab, got %s", body)
+	}
+}
+
+func TestOwnershipTrailShowsRecall(t *testing.T) {
+	s := newTestNetwork(t)
+	mustInvoke(t, s, "farm", "create_grapes", testUUID(1), ts(0), "100")
+
+	mustInvoke(t, s, "auditor", "recall_grapes", testUUID(1), "Contaminated", ts(0))
+
+	var trail OwnershipTrail
+	err := json.Unmarshal(mustQuery(t, s, "grape_ownership_trail", testUUID(1)), &trail)
+	if err != nil {
+		t.Fatalf("Error parsing ownership trail: %s", err)
+	}
+	if !trail.Recalled || trail.RecallReason != "Contaminated" {
+		t.Fatalf("expected recall in ownership trail, got %+v", trail)
+	}
+	if len(trail.Ownership) != 1 || trail.Ownership[0].PartyID != "farm" {
+		t.Fatalf("expected farm as only owner, got %+v", trail.Ownership)
+	}
+}
+
+func TestAddRoleAdminDeduplicates(t *testing.T) {
+	s := newTestNetwork(t)
+	mustInvoke(t, s, "admin", "add_role_admin", "Farm", encodeCert("farmadmin"))
+	mustInvoke(t, s, "admin", "add_role_admin", "Farm", encodeCert("farmadmin"))
+
+	// the same certificate, encoded with line breaks
+	mustInvoke(t, s, "admin", "add_role_admin", "Farm", encodeCert("farmadmin")[:4]+"\n"+encodeCert("farmadmin")[4:])
+
+	roleAdmins, err := s.cc.getRoleAdmins(s)
+	if err != nil {
+		t.Fatalf("Error retrieving role admins: %s", err)
+	}
+	if n := len(roleAdmins["Farm"]); n != 1 {
+		t.Fatalf("expected 1 Farm admin, got %d", n)
+	}
+
+	_, err = s.invoke("admin", "add_role_admin", "Farm", "not base64!")
+	expectError(t, err, "Invalid certificate")
+}
+
+func TestCertOwnerFollowsIndex(t *testing.T) {
+	s := newTestNetwork(t)
+	mustInvoke(t, s, "farm", "add_cert", encodeCert("farm-tcert"))
+
+	_, err := s.invoke("farm2", "add_cert", encodeCert("farm-tcert"))
+	expectError(t, err, "already registered to another party")
+
+	// replacing the certificates of a party drops the index entries of the old ones
+	party, err := s.cc.getParty(s, "farm")
+	if err != nil {
+		t.Fatalf("Error retrieving party: %s", err)
+	}
+	party.Certs = []string{encodeCert("farm-tcert")}
+	_, err = s.transact("admin", func() ([]byte, error) { return nil, s.cc.saveParty(s, party, false) })
+	if err != nil {
+		t.Fatalf("Error saving party: %s", err)
+	}
+
+	owners := map[string]string{"farm": "", "farm-tcert": "farm"}
+	for cert, expected := range owners {
+		owner, err := s.cc.getCertOwner(s, encodeCert(cert))
+		if err != nil || owner != expected {
+			t.Fatalf("expected owner %q of %s, got %q (%v)", expected, cert, owner, err)
+		}
+	}
+}
+
+func TestAddAdminReportsFailure(t *testing.T) {
+	s := newTestNetwork(t)
+	s.failKey = adminCertsKey
+
+	_, err := s.invoke("admin", "add_admin", encodeCert("admin2"))
+	expectError(t, err, "Failed adding to AdminCerts array: Failed saving new AdminCerts: mock failure")
+}
+
+func TestCertHeldByAnotherPartyIsRejected(t *testing.T) {
+	s := newTestNetwork(t)
+	mustInvoke(t, s, "farm", "add_cert", encodeCert("farm-tcert"))
+
+	_, err := s.invoke("farm2", "add_cert", encodeCert("farm-tcert"))
+	expectError(t, err, "already registered to another party")
+
+	_, err = s.invoke("admin", "add_party", "farm3", "Farm", encodeCert("farm-tcert"))
+	expectError(t, err, "already registered to another party")
+	if _, err := s.cc.getParty(s, "farm3"); err == nil {
+		t.Fatalf("expected farm3 not to be stored")
+	}
+
+	// saving a party never takes over the index entry of another party
+	party, err := s.cc.getParty(s, "farm2")
+	if err != nil {
+		t.Fatalf("Error retrieving party: %s", err)
+	}
+	party.Certs = append(party.Certs, encodeCert("farm-tcert"))
+	_, err = s.transact("admin", func() ([]byte, error) { return nil, s.cc.saveParty(s, party, false) })
+	expectError(t, err, "already registered to party farm")
+
+	for _, cert := range []string{"farm", "farm-tcert"} {
+		if owner, err := s.cc.getCertOwner(s, encodeCert(cert)); err != nil || owner != "farm" {
+			t.Fatalf("expected farm to own %s, got %q (%v)", cert, owner, err)
+		}
+	}
+	if party, _ := s.cc.getParty(s, "farm2"); len(party.Certs) != 1 {
+		t.Fatalf("expected farm2 to keep one certificate, got %v", party.Certs)
+	}
+}
+
+func TestAddPartyLooksUpOwnKey(t *testing.T) {
+	s := newTestNetwork(t)
+
+	_, err := s.invoke("admin", "add_party", "farm", "Farm", encodeCert("farm-new"))
+	expectError(t, err, "Party ID must be unique")
+
+	s.failKey = partyKey("farm3")
+	_, err = s.invoke("admin", "add_party", "farm3", "Farm", encodeCert("farm3"))
+	expectError(t, err, "Error saving party: Error saving parties")
+}
+
+func TestPartiesBeyondRangeMarker(t *testing.T) {
+	s := newTestNetwork(t)
+
+	// IDs with characters sorting after "~" are still within the party range
+	for _, id := range []string{"~farm", "farmé", "ÿ"} {
+		mustInvoke(t, s, "admin", "add_party", id, "Farm", encodeCert(id))
+	}
+
+	var parties []PublicParty
+	if err := json.Unmarshal(mustAdminQuery(t, s, "list_parties"), &parties); err != nil {
+		t.Fatal(err)
+	}
+	found := make(map[string]bool)
+	for _, party := range parties {
+		found[party.ID] = true
+	}
+	for _, id := range []string{"farm", "~farm", "farmé", "ÿ"} {
+		if !found[id] {
+			t.Fatalf("expected %q to be listed, got %+v", id, parties)
+		}
+	}
+	if len(parties) != 12 {
+		t.Fatalf("expected 12 parties, got %d", len(parties))
+	}
+}
+
+func TestWineryPassesOnGrapes(t *testing.T) {
+	s := newTestNetwork(t)
+	for i := 1; i <= 3; i++ {
+		mustInvoke(t, s, "farm", "create_grapes", testUUID(i), ts(0), "100")
+	}
+	mustInvoke(t, s, "farm", "transfer_grapes_batch", fmt.Sprintf(`[%q,%q,%q]`, testUUID(1), testUUID(2), testUUID(3)), "winery", ts(time.Minute))
+
+	own_b, err := s.query("winery", "get_own_grapes")
+	if err != nil {
+		t.Fatalf("get_own_grapes failed: %s", err)
+	}
+	if uuids := grapesUUIDs(t, own_b); len(uuids) != 3 {
+		t.Fatalf("expected winery to own 3 grape units, got %v", uuids)
+	}
+
+	mustInvoke(t, s, "winery", "transfer_grapes", testUUID(1), "trader", ts(2*time.Minute))
+	mustInvoke(t, s, "winery", "transfer_grapes_batch", fmt.Sprintf(`[%q]`, testUUID(2)), "trader", ts(2*time.Minute))
+	mustInvoke(t, s, "winery", "transfer_share", testUUID(3), "trader", "50", ts(2*time.Minute))
+
+	if uuids := ownedGrapes(t, s, "trader"); len(uuids) != 3 {
+		t.Fatalf("expected trader to own 3 grape units, got %v", uuids)
+	}
+
+	_, err = s.query("auditor", "get_own_grapes")
+	expectError(t, err, "Caller (auditor) is no Farm or Trader or Winery")
+}
@@ -0,0 +1,95 @@
+package main
+
+// World-state keys of the chaincode. Collections that are rewritten as a whole
+// are stored under a single key, assets are stored one per key under a prefix
+// naming their type, so IDs of different asset types can't collide.
+
+// keys of collections and settings
+const (
+	adminCertsKey            = "AdminCerts"
+	roleAdminsKey            = "RoleAdmins"
+	rolesKey                 = "Roles"
+	certRolesKey             = "CertAttributeRoles"
+	signingAccreditationsKey = "SigningAccreditations"
+	signingAuthorizationsKey = "SigningAuthorizations"
+	minSignaturesKey         = "MinSignatures"
+	metricsKey               = "Metrics" // counters of all functions, written before they got their own keys
+)
+
+// prefixes of asset keys
+const (
+	grapesKeyPrefix  = "GrapesUnit_"
+	partyKeyPrefix   = "Party_"
+	historyKeyPrefix = "GrapesHistory_"
+	wineKeyPrefix    = "WineBatch_"
+	producerIndex    = "GrapesByProducer_"
+	certIndex        = "PartyByCert_"
+	metricKeyPrefix  = "CallCount_"
+)
+
+// separator between an ID and the rest of an index key, IDs can't contain it
+const keySeparator = "\x00"
+
+// end key of a range query over the keys starting with prefix: the shortest key
+// sorting after all of them, whatever characters follow the prefix
+func prefixEnd(prefix string) string {
+	end := []byte(prefix)
+	for i := len(end) - 1; i >= 0; i-- {
+		if end[i] < 0xff {
+			end[i]++
+			return string(end[:i+1])
+		}
+	}
+	return ""
+}
+
+// key of a grape unit
+func grapesKey(uuid string) string {
+	return grapesKeyPrefix + uuid
+}
+
+// key of the history of a grape unit
+func grapesHistoryKey(uuid string) string {
+	return historyKeyPrefix + uuid
+}
+
+// key of the history entry a transaction wrote for a grape unit
+func grapesHistoryEntryKey(uuid string, txID string) string {
+	return grapesHistoryKey(uuid) + "/" + txID
+}
+
+// key of a party
+func partyKey(partyID string) string {
+	return partyKeyPrefix + partyID
+}
+
+// key of a wine batch
+func wineKey(batchID string) string {
+	return wineKeyPrefix + batchID
+}
+
+// prefix of the producer index entries of a producer
+func producerIndexPrefix(producer string) string {
+	return producerIndex + producer + keySeparator
+}
+
+// prefix of the producer index entries of a producer written before the index
+// used keySeparator, it also matches producer IDs extending the producer ID
+func legacyProducerIndexPrefix(producer string) string {
+	return producerIndex + producer + "_"
+}
+
+// key of the producer index entry of a grape unit
+func producerIndexKey(producer string, uuid string) string {
+	return producerIndexPrefix(producer) + uuid
+}
+
+// key of the certificate index entry of a certificate fingerprint
+func certIndexKey(fingerprint string) string {
+	return certIndex + fingerprint
+}
+
+// key of the call counter of an invoke function
+func metricKey(function string) string {
+	return metricKeyPrefix + function
+}